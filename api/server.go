@@ -0,0 +1,304 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/distributer"
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/price"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+const (
+	explainPath  = "/explain"
+	vestingPath  = "/vesting"
+	referralPath = "/referral"
+	pricesPath   = "/prices"
+	statsPath    = "/stats"
+	yieldsPath   = "/yields"
+)
+
+const defaultStatsDays = 30
+
+var (
+	explainWindow      int64
+	explainWindowCount uint64
+	explainWindowLock  sync.Mutex
+)
+
+// Start start http API server if enabled in config
+func Start() {
+	apiCfg := params.GetConfig().API
+	if apiCfg == nil || !apiCfg.Enable {
+		log.Info("[api] api server is disabled")
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(explainPath, explainHandler)
+	mux.HandleFunc(vestingPath, vestingHandler)
+	mux.HandleFunc(referralPath, referralHandler)
+	mux.HandleFunc(pricesPath, pricesHandler)
+	mux.HandleFunc(statsPath, statsHandler)
+	mux.HandleFunc(yieldsPath, yieldsHandler)
+
+	server := &http.Server{
+		Addr:         apiCfg.ListenAddress,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 5 * time.Minute, // explain recomputes the whole cycle
+	}
+
+	go func() {
+		log.Info("[api] start api server", "address", apiCfg.ListenAddress)
+		if err := server.ListenAndServe(); err != nil {
+			log.Error("[api] api server stopped", "err", err)
+		}
+	}()
+}
+
+// explainHandler query params: account, type, start, end, [rewards], [sample], [program]
+func explainHandler(w http.ResponseWriter, r *http.Request) {
+	apiCfg := params.GetConfig().API
+	if !isAdminAuthorized(r, apiCfg.AdminToken) {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("explain requires admin token"))
+		return
+	}
+	if !allowExplain(apiCfg.GetExplainPerMinute()) {
+		writeError(w, http.StatusTooManyRequests, fmt.Errorf("too many explain requests, limit %v per minute", apiCfg.GetExplainPerMinute()))
+		return
+	}
+	args, err := parseExplainArgs(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	expl, err := distributer.ExplainReward(args)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, expl)
+}
+
+// vestingHandler query params: account
+func vestingHandler(w http.ResponseWriter, r *http.Request) {
+	account := r.URL.Query().Get("account")
+	if !common.IsHexAddress(account) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("wrong account '%v'", account))
+		return
+	}
+	balances, err := distributer.GetVestingBalances(common.HexToAddress(account))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, balances)
+}
+
+// referralHandler query params: account, [referrer]
+// GET returns referrer and referees of account,
+// POST registers referrer of account if registration by API is enabled.
+func referralHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	account := query.Get("account")
+	if !common.IsHexAddress(account) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("wrong account '%v'", account))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		refCfg := params.GetConfig().Referral
+		if refCfg == nil || !refCfg.EnableAPIRegistration {
+			writeError(w, http.StatusForbidden, fmt.Errorf("referral registration by API is disabled"))
+			return
+		}
+		referrer := query.Get("referrer")
+		if !common.IsHexAddress(referrer) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("wrong referrer '%v'", referrer))
+			return
+		}
+		added, err := distributer.RegisterReferral(common.HexToAddress(account), common.HexToAddress(referrer), mongodb.ReferralSourceAPI)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if !added {
+			writeError(w, http.StatusConflict, fmt.Errorf("account '%v' is already registered", account))
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method '%v' is not allowed", r.Method))
+		return
+	}
+	info, err := distributer.GetReferralInfo(common.HexToAddress(account))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// pricesHandler latest prices and TVL of configed exchanges
+func pricesHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, price.GetExchangePrices())
+}
+
+// statsHandler query params: exchange, [start], [end], [interval]
+// start and end are unix timestamps, default latest 30 days,
+// interval is seconds of liquidity snapshots and volume buckets, default a day
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	exchange := query.Get("exchange")
+	if !common.IsHexAddress(exchange) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("wrong exchange '%v'", exchange))
+		return
+	}
+	start, end, err := parseTimeRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	interval := params.SecondsPerDay
+	if intervalStr := query.Get("interval"); intervalStr != "" {
+		interval, err = strconv.ParseUint(intervalStr, 10, 64)
+		if err != nil || interval == 0 || params.SecondsPerDay%interval != 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("wrong interval '%v'", intervalStr))
+			return
+		}
+	}
+	stats, err := price.GetExchangeStats(exchange, start, end, interval)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// yieldsHandler query params: exchange, [account], [start], [end]
+// start and end are unix timestamps, default latest 30 days
+func yieldsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	exchange := query.Get("exchange")
+	if !common.IsHexAddress(exchange) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("wrong exchange '%v'", exchange))
+		return
+	}
+	account := query.Get("account")
+	if account != "" && !common.IsHexAddress(account) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("wrong account '%v'", account))
+		return
+	}
+	start, end, err := parseTimeRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	yields, err := mongodb.FindYields(exchange, account, start, end)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, yields)
+}
+
+// time range of query params start and end, default latest 30 days
+func parseTimeRange(r *http.Request) (start, end uint64, err error) {
+	query := r.URL.Query()
+	end = uint64(time.Now().Unix())
+	if endStr := query.Get("end"); endStr != "" {
+		if end, err = strconv.ParseUint(endStr, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("wrong end '%v'", endStr)
+		}
+	}
+	if end > defaultStatsDays*params.SecondsPerDay {
+		start = end - defaultStatsDays*params.SecondsPerDay
+	}
+	if startStr := query.Get("start"); startStr != "" {
+		if start, err = strconv.ParseUint(startStr, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("wrong start '%v'", startStr)
+		}
+	}
+	if start >= end {
+		return 0, 0, fmt.Errorf("wrong range [%v, %v)", start, end)
+	}
+	return start, end, nil
+}
+
+func parseExplainArgs(r *http.Request) (*distributer.ExplainArgs, error) {
+	query := r.URL.Query()
+	account := query.Get("account")
+	if !common.IsHexAddress(account) {
+		return nil, fmt.Errorf("wrong account '%v'", account)
+	}
+	args := &distributer.ExplainArgs{
+		Account: common.HexToAddress(account),
+		ByWhat:  query.Get("type"),
+		Program: query.Get("program"),
+	}
+	var err error
+	if args.Start, err = strconv.ParseUint(query.Get("start"), 10, 64); err != nil {
+		return nil, fmt.Errorf("wrong start '%v'", query.Get("start"))
+	}
+	if args.End, err = strconv.ParseUint(query.Get("end"), 10, 64); err != nil {
+		return nil, fmt.Errorf("wrong end '%v'", query.Get("end"))
+	}
+	if sample := query.Get("sample"); sample != "" {
+		if args.SampleHeight, err = strconv.ParseUint(sample, 10, 64); err != nil {
+			return nil, fmt.Errorf("wrong sample '%v'", sample)
+		}
+	}
+	if rewards := query.Get("rewards"); rewards != "" {
+		if args.TotalReward, err = tools.GetBigIntFromString(rewards); err != nil {
+			return nil, fmt.Errorf("wrong rewards '%v'", rewards)
+		}
+	}
+	return args, nil
+}
+
+func isAdminAuthorized(r *http.Request, adminToken string) bool {
+	if adminToken == "" {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// fixed window limit of explain requests per minute
+func allowExplain(perMinute uint64) bool {
+	explainWindowLock.Lock()
+	defer explainWindowLock.Unlock()
+	window := time.Now().Unix() / 60
+	if window != explainWindow {
+		explainWindow = window
+		explainWindowCount = 0
+	}
+	if explainWindowCount >= perMinute {
+		return false
+	}
+	explainWindowCount++
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, content interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(content); err != nil {
+		log.Warn("[api] write response failed", "err", err)
+	}
+}
@@ -60,7 +60,9 @@ func (c *APICaller) CloseClient() {
 // GetCoinBalance get coin balance
 func (c *APICaller) GetCoinBalance(account common.Address, blockNumber *big.Int) (balance *big.Int, err error) {
 	for i := 0; i < c.rpcRetryCount; i++ {
+		start := time.Now()
 		balance, err = c.client.BalanceAt(c.context, account, blockNumber)
+		observeRPC("eth_getBalance", start, err)
 		if err == nil {
 			break
 		}
@@ -72,6 +74,23 @@ func (c *APICaller) GetCoinBalance(account common.Address, blockNumber *big.Int)
 	return balance, nil
 }
 
+// GetCode get contract code of account, empty if account is not a contract
+func (c *APICaller) GetCode(account common.Address, blockNumber *big.Int) (code []byte, err error) {
+	for i := 0; i < c.rpcRetryCount; i++ {
+		start := time.Now()
+		code, err = c.client.CodeAt(c.context, account, blockNumber)
+		observeRPC("eth_getCode", start, err)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		log.Warn("[callapi] GetCode error", "account", account.String(), "blockNumber", blockNumber, "err", err)
+		return nil, err
+	}
+	return code, nil
+}
+
 // GetExchangeLiquidity get exchange liquidity
 func (c *APICaller) GetExchangeLiquidity(exchange common.Address, blockNumber *big.Int) (*big.Int, error) {
 	return c.GetTokenTotalSupply(exchange, blockNumber)
@@ -144,28 +163,66 @@ func (c *APICaller) GetExchangeFactoryAddress(exchange common.Address) common.Ad
 
 // GetAccountNonce get account nonce
 func (c *APICaller) GetAccountNonce(account common.Address) (uint64, error) {
-	return c.client.PendingNonceAt(c.context, account)
+	start := time.Now()
+	nonce, err := c.client.PendingNonceAt(c.context, account)
+	observeRPC("eth_getTransactionCount", start, err)
+	return nonce, err
+}
+
+// GetConfirmedNonce get account nonce of latest block
+func (c *APICaller) GetConfirmedNonce(account common.Address) (uint64, error) {
+	start := time.Now()
+	nonce, err := c.client.NonceAt(c.context, account, nil)
+	observeRPC("eth_getTransactionCount", start, err)
+	return nonce, err
 }
 
 // SendTransaction send signed tx
 func (c *APICaller) SendTransaction(tx *types.Transaction) error {
-	return c.client.SendTransaction(c.context, tx)
+	start := time.Now()
+	err := c.client.SendTransaction(c.context, tx)
+	observeRPC("eth_sendRawTransaction", start, err)
+	return err
+}
+
+// GetTransactionByHash get transaction by hash
+func (c *APICaller) GetTransactionByHash(txHash common.Hash) (tx *types.Transaction, isPending bool, err error) {
+	start := time.Now()
+	tx, isPending, err = c.client.TransactionByHash(c.context, txHash)
+	observeRPC("eth_getTransactionByHash", start, err)
+	return tx, isPending, err
+}
+
+// GetTransactionReceipt get transaction receipt
+func (c *APICaller) GetTransactionReceipt(txHash common.Hash) (*types.Receipt, error) {
+	start := time.Now()
+	receipt, err := c.client.TransactionReceipt(c.context, txHash)
+	observeRPC("eth_getTransactionReceipt", start, err)
+	return receipt, err
 }
 
 // GetChainID get chain ID, also known as network ID
 func (c *APICaller) GetChainID() (*big.Int, error) {
-	return c.client.NetworkID(c.context)
+	start := time.Now()
+	chainID, err := c.client.NetworkID(c.context)
+	observeRPC("net_version", start, err)
+	return chainID, err
 }
 
 // SuggestGasPrice suggest gas price
 func (c *APICaller) SuggestGasPrice() (*big.Int, error) {
-	return c.client.SuggestGasPrice(c.context)
+	start := time.Now()
+	gasPrice, err := c.client.SuggestGasPrice(c.context)
+	observeRPC("eth_gasPrice", start, err)
+	return gasPrice, err
 }
 
 // GetSyncProgress get full node syncing state
 func (c *APICaller) GetSyncProgress() *ethereum.SyncProgress {
 	for {
+		start := time.Now()
 		progress, err := c.client.SyncProgress(c.context)
+		observeRPC("eth_syncing", start, err)
 		if err == nil {
 			log.Info("call eth_syncing success", "progress", progress)
 			return progress
@@ -182,7 +239,9 @@ func (c *APICaller) CallContract(contract common.Address, data []byte, blockNumb
 		Data: data,
 	}
 	for i := 0; i < c.rpcRetryCount; i++ {
+		start := time.Now()
 		res, err = c.client.CallContract(c.context, msg, blockNumber)
+		observeRPC("eth_call", start, err)
 		if err == nil {
 			break
 		}
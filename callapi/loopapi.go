@@ -13,7 +13,9 @@ import (
 // LoopGetBlockHeader loop get block header
 func (c *APICaller) LoopGetBlockHeader(blockNumber *big.Int) *types.Header {
 	for {
+		start := time.Now()
 		header, err := c.client.HeaderByNumber(c.context, blockNumber)
+		observeRPC("eth_getBlockByNumber", start, err)
 		if err == nil {
 			return header
 		}
@@ -25,7 +27,9 @@ func (c *APICaller) LoopGetBlockHeader(blockNumber *big.Int) *types.Header {
 // LoopGetLatestBlockHeader loop get latest block header
 func (c *APICaller) LoopGetLatestBlockHeader() *types.Header {
 	for {
+		start := time.Now()
 		header, err := c.client.HeaderByNumber(c.context, nil)
+		observeRPC("eth_getBlockByNumber", start, err)
 		if err == nil {
 			log.Info("[callapi] get latest block header succeed.",
 				"number", header.Number,
@@ -74,6 +78,21 @@ func (c *APICaller) LoopGetCoinBalance(address common.Address, blockNumber *big.
 	return fsnBalance
 }
 
+// LoopGetCode get contract code of account
+func (c *APICaller) LoopGetCode(address common.Address, blockNumber *big.Int) []byte {
+	var code []byte
+	var err error
+	for {
+		code, err = c.GetCode(address, blockNumber)
+		if err == nil {
+			break
+		}
+		log.Error("[callapi] GetCode error", "address", address.String(), "err", err)
+		time.Sleep(c.rpcRetryInterval)
+	}
+	return code
+}
+
 // LoopGetExchangeTokenBalance get account token balance
 func (c *APICaller) LoopGetExchangeTokenBalance(exchange, token common.Address, blockNumber *big.Int) *big.Int {
 	return c.LoopGetTokenBalance(token, exchange, blockNumber)
@@ -129,7 +148,9 @@ func (c *APICaller) loopGetFactoryExcahngeOrToken(factory, address common.Addres
 		Data: data,
 	}
 	for {
+		start := time.Now()
 		res, err = c.client.CallContract(c.context, msg, nil)
+		observeRPC("eth_call", start, err)
 		if err == nil {
 			break
 		}
@@ -152,7 +173,9 @@ func (c *APICaller) LoopGetFactoryTokenCount(factory common.Address) uint64 {
 		Data: getTokenCountFuncHash,
 	}
 	for i := 0; i < c.rpcRetryCount; i++ {
+		start := time.Now()
 		res, err = c.client.CallContract(c.context, msg, nil)
+		observeRPC("eth_call", start, err)
 		if err == nil {
 			break
 		}
@@ -178,7 +201,9 @@ func (c *APICaller) LoopGetFactoryTokenWithID(factory common.Address, id uint64)
 		Data: data,
 	}
 	for i := 0; i < c.rpcRetryCount; i++ {
+		start := time.Now()
 		res, err = c.client.CallContract(c.context, msg, nil)
+		observeRPC("eth_call", start, err)
 		if err == nil {
 			break
 		}
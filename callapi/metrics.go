@@ -0,0 +1,22 @@
+package callapi
+
+import (
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/metrics"
+)
+
+var (
+	rpcCallCounter  = metrics.NewCounter("distribute_rpc_calls_total", "Number of RPC calls to the gateway.", "method")
+	rpcErrorCounter = metrics.NewCounter("distribute_rpc_errors_total", "Number of failed RPC calls to the gateway.", "method")
+	rpcLatency      = metrics.NewHistogram("distribute_rpc_duration_seconds", "Latency of RPC calls to the gateway.", metrics.DefaultLatencyBuckets, "method")
+)
+
+// observeRPC record count, error and latency of a single RPC call
+func observeRPC(method string, start time.Time, err error) {
+	rpcCallCounter.Inc(method)
+	rpcLatency.Observe(time.Since(start).Seconds(), method)
+	if err != nil {
+		rpcErrorCounter.Inc(method)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/cmd/utils"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	accountListDBFlags = []cli.Flag{
+		mongoURLFlag,
+		dbNameFlag,
+		dbUserFlag,
+		dbPassFlag,
+	}
+
+	accountListCommand = &cli.Command{
+		Name:  "accountlist",
+		Usage: "manage reward denylist and allowlist",
+		Description: `
+manage denylist and allowlist of reward accounts stored in database.
+entries take effect in cycles with start in [start, end), end 0 means forever,
+start and end are block heights, or unix timestamps if use time measurement.
+accounts in an active denylist entry are excluded from rewards,
+if any allowlist entry is active, only accounts in active allowlist entries are rewarded.
+`,
+		Subcommands: []*cli.Command{
+			{
+				Action:    addAccountListEntry,
+				Name:      "add",
+				Usage:     "add or update account list entry",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					utils.AccountListFlag,
+					utils.AccountFlag,
+					utils.ReasonFlag,
+					utils.StartHeightFlag,
+					utils.EndHeightFlag,
+				}, accountListDBFlags...),
+			},
+			{
+				Action:    removeAccountListEntry,
+				Name:      "remove",
+				Usage:     "remove account list entry",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					utils.AccountListFlag,
+					utils.AccountFlag,
+				}, accountListDBFlags...),
+			},
+			{
+				Action:    showAccountList,
+				Name:      "list",
+				Usage:     "list account list entries, list all if --list is not specified",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					utils.AccountListFlag,
+				}, accountListDBFlags...),
+			},
+		},
+	}
+)
+
+func getAccountListArgs(ctx *cli.Context) (list, account string, err error) {
+	list = strings.ToLower(ctx.String(utils.AccountListFlag.Name))
+	if list != mongodb.DenyList && list != mongodb.AllowList {
+		return "", "", fmt.Errorf("wrong list '%v', must be '%v' or '%v'", list, mongodb.DenyList, mongodb.AllowList)
+	}
+	account = ctx.String(utils.AccountFlag.Name)
+	if !common.IsHexAddress(account) {
+		return "", "", fmt.Errorf("wrong account '%v'", account)
+	}
+	return list, strings.ToLower(account), nil
+}
+
+func addAccountListEntry(ctx *cli.Context) error {
+	list, account, err := getAccountListArgs(ctx)
+	if err != nil {
+		return err
+	}
+	reason := ctx.String(utils.ReasonFlag.Name)
+	if reason == "" {
+		return fmt.Errorf("must specify reason")
+	}
+	start := ctx.Uint64(utils.StartHeightFlag.Name)
+	end := ctx.Uint64(utils.EndHeightFlag.Name)
+	if end != 0 && start >= end {
+		return fmt.Errorf("wrong range [%v, %v)", start, end)
+	}
+	initMongodb(ctx)
+	return mongodb.AddAccountListEntry(&mongodb.MgoAccountListEntry{
+		Key:       mongodb.GetKeyOfAccountListEntry(list, account),
+		List:      list,
+		Account:   account,
+		Reason:    reason,
+		Start:     start,
+		End:       end,
+		Timestamp: uint64(time.Now().Unix()),
+	})
+}
+
+func removeAccountListEntry(ctx *cli.Context) error {
+	list, account, err := getAccountListArgs(ctx)
+	if err != nil {
+		return err
+	}
+	initMongodb(ctx)
+	return mongodb.DeleteAccountListEntry(list, account)
+}
+
+func showAccountList(ctx *cli.Context) error {
+	list := strings.ToLower(ctx.String(utils.AccountListFlag.Name))
+	initMongodb(ctx)
+	entries, err := mongodb.FindAccountListEntries(list)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		fmt.Printf("%v %v start=%v end=%v reason=%v\n", entry.List, entry.Account, entry.Start, entry.End, entry.Reason)
+	}
+	return nil
+}
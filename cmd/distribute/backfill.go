@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/anyswap/ANYToken-distribution/cmd/utils"
+	"github.com/anyswap/ANYToken-distribution/distributer"
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/price"
+	"github.com/anyswap/ANYToken-distribution/worker"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	backfillCommand = &cli.Command{
+		Action:    backfill,
+		Name:      "backfill",
+		Usage:     "backfill liquidity snapshots and volume buckets by config file",
+		ArgsUsage: " ",
+		Description: `
+backfill liquidity snapshots and volume buckets of configed exchanges in time range [start, end)
+by Sync.SnapshotInterval and Sync.DayBoundaryOffset in config file.
+liquidity snapshots are queried from historical state, which requires 'archive' node.
+volumes of snapshot interval and days of Sync.DayBoundaryOffset are rebuilt from volume histories,
+where token to token swaps are not recorded, daily volumes of UTC days are never rebuilt.
+exist snapshots and volumes are kept if not --overwrite.
+`,
+		Flags: []cli.Flag{
+			backfillStartFlag,
+			backfillEndFlag,
+			utils.ExchangeSliceFlag,
+			utils.OverwriteFlag,
+			backfillTypeFlag,
+		},
+	}
+
+	backfillStartFlag = &cli.Uint64Flag{
+		Name:  "start",
+		Usage: "start timestamp (start inclusive)",
+	}
+
+	backfillEndFlag = &cli.Uint64Flag{
+		Name:  "end",
+		Usage: "end timestamp (end exclusive), 0 means now",
+	}
+
+	backfillTypeFlag = &cli.StringFlag{
+		Name:  "type",
+		Usage: "type value can be liquidity, volume, both",
+		Value: "both",
+	}
+)
+
+func backfill(ctx *cli.Context) error {
+	configFile := utils.GetConfigFilePath(ctx)
+	if configFile == "" {
+		log.Fatal("backfill: must specify config file path")
+	}
+
+	backfillType := ctx.String(backfillTypeFlag.Name)
+	withLiquidity := backfillType == "liquidity" || backfillType == "both"
+	withVolume := backfillType == "volume" || backfillType == "both"
+	if !withLiquidity && !withVolume {
+		return fmt.Errorf("wrong backfill type '%v'", backfillType)
+	}
+
+	capi := utils.InitApp(ctx, true)
+	defer capi.CloseClient()
+	distributer.SetAPICaller(capi)
+	price.SetAPICaller(capi)
+	worker.SetAPICaller(capi)
+
+	start := ctx.Uint64(backfillStartFlag.Name)
+	end := ctx.Uint64(backfillEndFlag.Name)
+	if end == 0 {
+		end = capi.LoopGetLatestBlockHeader().Time.Uint64()
+	}
+	if start >= end {
+		return fmt.Errorf("start %v is not lower than end %v", start, end)
+	}
+
+	exchanges := ctx.StringSlice(utils.ExchangeSliceFlag.Name)
+	if len(exchanges) == 0 {
+		for _, ex := range params.GetConfig().Exchanges {
+			exchanges = append(exchanges, ex.Exchange)
+		}
+	}
+	overwrite := ctx.Bool(utils.OverwriteFlag.Name)
+	for _, exchange := range exchanges {
+		exCfg := params.GetExchangeConfig(exchange)
+		if exCfg == nil {
+			return fmt.Errorf("exchange %v is not configed", exchange)
+		}
+		if withVolume {
+			if _, err := worker.BackfillVolumes(exCfg, start, end, overwrite); err != nil {
+				return err
+			}
+		}
+		if withLiquidity {
+			if _, err := worker.BackfillLiquidities(exCfg, start, end, overwrite); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
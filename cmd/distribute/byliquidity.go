@@ -29,14 +29,18 @@ distribute rewards by liquidity
 			utils.SenderFlag,
 			utils.KeyStoreFileFlag,
 			utils.PasswordFileFlag,
+			utils.SignerTypeFlag,
+			utils.SignerURLFlag,
 			utils.GasLimitFlag,
 			utils.GasPriceFlag,
 			utils.AccountNonceFlag,
 			utils.SampleFlag,
 			utils.SaveDBFlag,
 			utils.DryRunFlag,
+			utils.OverrideLimitsFlag,
 			utils.BatchCountFlag,
 			utils.BatchIntervalFlag,
+			utils.MaxInflightFlag,
 			utils.UseTimeMeasurementFlag,
 			utils.ArchiveModeFlag,
 		},
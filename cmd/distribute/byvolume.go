@@ -32,13 +32,17 @@ distribute rewards by volume
 			utils.SenderFlag,
 			utils.KeyStoreFileFlag,
 			utils.PasswordFileFlag,
+			utils.SignerTypeFlag,
+			utils.SignerURLFlag,
 			utils.GasLimitFlag,
 			utils.GasPriceFlag,
 			utils.AccountNonceFlag,
 			utils.SaveDBFlag,
 			utils.DryRunFlag,
+			utils.OverrideLimitsFlag,
 			utils.BatchCountFlag,
 			utils.BatchIntervalFlag,
+			utils.MaxInflightFlag,
 			utils.UseTimeMeasurementFlag,
 			utils.PercentageWeightFlag,
 		},
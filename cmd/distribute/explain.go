@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/anyswap/ANYToken-distribution/cmd/utils"
+	"github.com/anyswap/ANYToken-distribution/distributer"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	explainCommand = &cli.Command{
+		Action:    explain,
+		Name:      "explain",
+		Usage:     "explain reward derivation of an account",
+		ArgsUsage: " ",
+		Description: `
+explain how the reward of an account in cycle [start, end) is derived by config file.
+shows the counted swaps and stake bonus of every step cycle (volume rewards),
+or the liquidity balance and coin conversion at sample height (liquidity rewards),
+the exchange weight and share, dust exclusion, and the final reward and tx hash.
+total rewards and sample height are taken from config and database if not specified.
+rewards of a reward program are explained with its settings if program is specified.
+`,
+		Flags: []cli.Flag{
+			utils.GatewayFlag,
+			utils.AccountFlag,
+			utils.RewardTyepFlag,
+			utils.StartHeightFlag,
+			utils.EndHeightFlag,
+			utils.TotalRewardsFlag,
+			utils.SampleFlag,
+			utils.ProgramFlag,
+		},
+	}
+)
+
+func explain(ctx *cli.Context) (err error) {
+	account := ctx.String(utils.AccountFlag.Name)
+	if !common.IsHexAddress(account) {
+		return fmt.Errorf("wrong account '%v'", account)
+	}
+	args := &distributer.ExplainArgs{
+		Account:      common.HexToAddress(account),
+		ByWhat:       ctx.String(utils.RewardTyepFlag.Name),
+		Start:        ctx.Uint64(utils.StartHeightFlag.Name),
+		End:          ctx.Uint64(utils.EndHeightFlag.Name),
+		SampleHeight: ctx.Uint64(utils.SampleFlag.Name),
+		Program:      ctx.String(utils.ProgramFlag.Name),
+	}
+	if ctx.IsSet(utils.TotalRewardsFlag.Name) {
+		args.TotalReward, err = tools.GetBigIntFromString(ctx.String(utils.TotalRewardsFlag.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	capi := utils.InitAppWithURL(ctx, ctx.String(utils.GatewayFlag.Name), true)
+	distributer.SetAPICaller(capi)
+	defer capi.CloseClient()
+
+	expl, err := distributer.ExplainReward(args)
+	if err != nil {
+		return err
+	}
+	fmt.Println(tools.ToJSONString(expl, true))
+	return nil
+}
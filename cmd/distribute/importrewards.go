@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/anyswap/ANYToken-distribution/cmd/utils"
+	"github.com/anyswap/ANYToken-distribution/distributer"
 	"github.com/anyswap/ANYToken-distribution/log"
 	"github.com/anyswap/ANYToken-distribution/mongodb"
 	"github.com/anyswap/ANYToken-distribution/params"
@@ -195,8 +196,9 @@ func verifyFile() {
 // or: <account>,<reward>,<txhash>
 // or: <account>,<reward>,<share>,<number>
 // or: <account>,<reward>,<share>,<number>,<txhash>
+// parts starting with '@' are annotations and ignored
 func processLine(line string, addToDB bool) {
-	parts := blankOrCommaSepRegexp.Split(line, -1)
+	parts := distributer.SplitLineParts(line)
 	if len(parts) < 2 {
 		log.Fatalf("wrong parts of input line: %v", line)
 	}
@@ -26,6 +26,17 @@ func initApp() {
 		byVolumeCommand,
 		calcRewardsCommand,
 		sendRewardsCommand,
+		buildTxsCommand,
+		signTxsCommand,
+		broadcastCommand,
+		safeBatchCommand,
+		reconcileCommand,
+		verifyCommand,
+		explainCommand,
+		simulateCommand,
+		accountListCommand,
+		referralCommand,
+		backfillCommand,
 		importRewardsCommand,
 		insertAccountCommand,
 		utils.LicenseCommand,
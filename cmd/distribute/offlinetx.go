@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/anyswap/ANYToken-distribution/cmd/utils"
+	"github.com/anyswap/ANYToken-distribution/distributer"
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	buildTxsCommand = &cli.Command{
+		Action:    buildTxs,
+		Name:      "buildtxs",
+		Usage:     "build unsigned reward transactions",
+		ArgsUsage: " ",
+		Description: `
+build unsigned reward transactions from verified input file with line format: <address> <rewards>
+and write them to output json file, which can be signed offline by 'signtxs' command
+`,
+		Flags: []cli.Flag{
+			utils.GatewayFlag,
+			utils.RewardTyepFlag,
+			utils.DustRewardFlag,
+			utils.ExchangeSliceFlag,
+			utils.RewardTokenFlag,
+			utils.StartHeightFlag,
+			utils.EndHeightFlag,
+			utils.InputFileSliceFlag,
+			utils.OutputFileSliceFlag,
+			utils.SenderFlag,
+			utils.GasLimitFlag,
+			utils.GasPriceFlag,
+			utils.AccountNonceFlag,
+			utils.ScalingValueFlag,
+		},
+	}
+
+	signTxsCommand = &cli.Command{
+		Action:    signTxs,
+		Name:      "signtxs",
+		Usage:     "sign reward transactions offline",
+		ArgsUsage: " ",
+		Description: `
+sign reward transactions built by 'buildtxs' command with keystore or external signer,
+no gateway access is needed
+`,
+		Flags: []cli.Flag{
+			utils.InputFileSliceFlag,
+			utils.OutputFileSliceFlag,
+			utils.KeyStoreFileFlag,
+			utils.PasswordFileFlag,
+			utils.SignerTypeFlag,
+			utils.SignerURLFlag,
+		},
+	}
+
+	broadcastCommand = &cli.Command{
+		Action:    broadcast,
+		Name:      "broadcast",
+		Usage:     "broadcast signed reward transactions",
+		ArgsUsage: " ",
+		Description: `
+broadcast reward transactions signed by 'signtxs' command, and record results to output file and database.
+it's safe to rerun this command, transactions already known by the node will not be sent again.
+`,
+		Flags: []cli.Flag{
+			utils.GatewayFlag,
+			utils.InputFileSliceFlag,
+			utils.OutputFileSliceFlag,
+			utils.SaveDBFlag,
+			utils.BatchCountFlag,
+			utils.BatchIntervalFlag,
+		},
+	}
+)
+
+func buildTxs(ctx *cli.Context) error {
+	serverURL := ctx.String(utils.GatewayFlag.Name)
+	if serverURL == "" {
+		return fmt.Errorf("must specify gateway URL")
+	}
+	rewardType := ctx.String(utils.RewardTyepFlag.Name)
+	if rewardType == "" {
+		return fmt.Errorf("must specify rewardType")
+	}
+
+	withConfigFile := !distributer.IsCustomMethod(rewardType)
+	capi := utils.InitAppWithURL(ctx, serverURL, withConfigFile)
+	distributer.SetAPICaller(capi)
+	defer capi.CloseClient()
+
+	args, err := newBuildTxArgs(ctx)
+	if err != nil {
+		log.Fatalf("get build tx args error: %v", err)
+	}
+	if args.Sender == "" {
+		return fmt.Errorf("must specify sender")
+	}
+	// no keystore is needed to build unsigned transactions
+	if err = args.Check(true); err != nil {
+		log.Fatalf("check build tx args error: %v", err)
+	}
+
+	opt, err := getOption(ctx, args, nil)
+	if err != nil {
+		log.Fatalf("get option error: %v", err)
+	}
+
+	opt.ScalingNumerator, opt.ScalingDenominator = getScalingValue(ctx.String(utils.ScalingValueFlag.Name))
+
+	return opt.BuildRewardTxsFromFile()
+}
+
+func signTxs(ctx *cli.Context) error {
+	utils.SetLogger(ctx)
+
+	inputFiles := ctx.StringSlice(utils.InputFileSliceFlag.Name)
+	outputFiles := ctx.StringSlice(utils.OutputFileSliceFlag.Name)
+	if len(inputFiles) != len(outputFiles) {
+		return fmt.Errorf("count of input and output files is not equal")
+	}
+
+	args := &distributer.BuildTxArgs{
+		SignerType:   ctx.String(utils.SignerTypeFlag.Name),
+		SignerURL:    ctx.String(utils.SignerURLFlag.Name),
+		KeystoreFile: ctx.String(utils.KeyStoreFileFlag.Name),
+		PasswordFile: ctx.String(utils.PasswordFileFlag.Name),
+	}
+	for i, inputFile := range inputFiles {
+		err := args.SignRewardTxsFile(inputFile, outputFiles[i])
+		if err != nil {
+			log.Error("sign reward txs file failed", "input", inputFile, "output", outputFiles[i], "err", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func broadcast(ctx *cli.Context) error {
+	serverURL := ctx.String(utils.GatewayFlag.Name)
+	if serverURL == "" {
+		return fmt.Errorf("must specify gateway URL")
+	}
+
+	saveDB := ctx.Bool(utils.SaveDBFlag.Name)
+	capi := utils.InitAppWithURL(ctx, serverURL, saveDB)
+	distributer.SetAPICaller(capi)
+	defer capi.CloseClient()
+
+	opt := &distributer.Option{
+		InputFiles:    ctx.StringSlice(utils.InputFileSliceFlag.Name),
+		OutputFiles:   ctx.StringSlice(utils.OutputFileSliceFlag.Name),
+		SaveDB:        saveDB,
+		BatchCount:    ctx.Uint64(utils.BatchCountFlag.Name),
+		BatchInterval: ctx.Uint64(utils.BatchIntervalFlag.Name),
+	}
+	return opt.BroadcastRewardTxsFromFile()
+}
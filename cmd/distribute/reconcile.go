@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/anyswap/ANYToken-distribution/cmd/utils"
+	"github.com/anyswap/ANYToken-distribution/distributer"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	reconcileCommand = &cli.Command{
+		Action:    reconcile,
+		Name:      "reconcile",
+		Usage:     "reconcile recorded rewards with on-chain transfers",
+		ArgsUsage: " ",
+		Description: `
+reconcile reward results recorded in database of cycles start in range [start, end)
+with reward transfers on chain. report missing, reverted, duplicated or mismatched payments
+and accounts that never got a reward tx, and write corrective rewards to output file
+with line format: <address>,<rewards>, which can be sent by 'sendrewards' command.
+`,
+		Flags: []cli.Flag{
+			utils.GatewayFlag,
+			utils.RewardTyepFlag,
+			utils.ExchangeSliceFlag,
+			utils.RewardTokenFlag,
+			utils.StartHeightFlag,
+			utils.EndHeightFlag,
+			utils.OutputFileFlag,
+			utils.ProgramFlag,
+		},
+	}
+)
+
+func reconcile(ctx *cli.Context) error {
+	rewardType := ctx.String(utils.RewardTyepFlag.Name)
+	if rewardType == "" {
+		return fmt.Errorf("must specify rewardType")
+	}
+	outputFile := ctx.String(utils.OutputFileFlag.Name)
+	if outputFile == "" {
+		return fmt.Errorf("must specify output file")
+	}
+	start := ctx.Uint64(utils.StartHeightFlag.Name)
+	end := ctx.Uint64(utils.EndHeightFlag.Name)
+	if start >= end {
+		return fmt.Errorf("wrong cycle range [%v, %v)", start, end)
+	}
+
+	capi := utils.InitAppWithURL(ctx, ctx.String(utils.GatewayFlag.Name), true)
+	distributer.SetAPICaller(capi)
+	defer capi.CloseClient()
+
+	opt := &distributer.Option{
+		Exchanges:   ctx.StringSlice(utils.ExchangeSliceFlag.Name),
+		RewardToken: ctx.String(utils.RewardTokenFlag.Name),
+		StartHeight: start,
+		EndHeight:   end,
+		Program:     ctx.String(utils.ProgramFlag.Name),
+	}
+	if err := opt.SetByWhat(rewardType); err != nil {
+		return err
+	}
+	return opt.ReconcileRewards(outputFile)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/anyswap/ANYToken-distribution/cmd/utils"
+	"github.com/anyswap/ANYToken-distribution/distributer"
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	referralCommand = &cli.Command{
+		Name:  "referral",
+		Usage: "manage referral registrations",
+		Description: `
+manage referral registrations stored in database.
+the first registration of a referee wins, registrations of file and command
+take effect in cycles ending after the latest synced block or current time.
+`,
+		Subcommands: []*cli.Command{
+			{
+				Action:    importReferrals,
+				Name:      "import",
+				Usage:     "import referrals from file of lines 'referee referrer'",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					utils.InputFileFlag,
+				}, accountListDBFlags...),
+			},
+			{
+				Action:    addReferral,
+				Name:      "add",
+				Usage:     "add referral of referee",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					utils.AccountFlag,
+					utils.ReferrerFlag,
+				}, accountListDBFlags...),
+			},
+			{
+				Action:    showReferrals,
+				Name:      "list",
+				Usage:     "list referrals of referrer, list all if --referrer is not specified",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					utils.ReferrerFlag,
+				}, accountListDBFlags...),
+			},
+		},
+	}
+)
+
+func importReferrals(ctx *cli.Context) error {
+	ifile := ctx.String(utils.InputFileFlag.Name)
+	if ifile == "" {
+		return fmt.Errorf("must specify input file")
+	}
+	initMongodb(ctx)
+	added, skipped, err := distributer.ImportReferralsFromFile(ifile)
+	log.Info("import referrals finished", "file", ifile, "added", added, "skipped", skipped)
+	return err
+}
+
+func addReferral(ctx *cli.Context) error {
+	referee := ctx.String(utils.AccountFlag.Name)
+	if !common.IsHexAddress(referee) {
+		return fmt.Errorf("wrong referee account '%v'", referee)
+	}
+	referrer := ctx.String(utils.ReferrerFlag.Name)
+	if !common.IsHexAddress(referrer) {
+		return fmt.Errorf("wrong referrer '%v'", referrer)
+	}
+	initMongodb(ctx)
+	added, err := distributer.RegisterReferral(common.HexToAddress(referee), common.HexToAddress(referrer), mongodb.ReferralSourceFile)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return fmt.Errorf("referee %v is already registered", referee)
+	}
+	return nil
+}
+
+func showReferrals(ctx *cli.Context) error {
+	referrer := ctx.String(utils.ReferrerFlag.Name)
+	initMongodb(ctx)
+	referrals, err := mongodb.FindReferralsOfReferrer(referrer)
+	if err != nil {
+		return err
+	}
+	for _, ref := range referrals {
+		fmt.Printf("%v %v source=%v blockNumber=%v timestamp=%v\n", ref.Referee, ref.Referrer, ref.Source, ref.BlockNumber, ref.Timestamp)
+	}
+	return nil
+}
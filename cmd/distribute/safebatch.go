@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/anyswap/ANYToken-distribution/cmd/utils"
+	"github.com/anyswap/ANYToken-distribution/distributer"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	safeBatchCommand = &cli.Command{
+		Action:    safeBatch,
+		Name:      "safebatch",
+		Usage:     "build gnosis safe batch proposals",
+		ArgsUsage: " ",
+		Description: `
+build gnosis safe batch proposals according to verified input file with line format: <address> <rewards>
+output format is Safe Transaction Builder json (txbuilder), or MultiSend encoded safe transaction (multisend).
+if there are more than one proposal for an input file, index is appended to output file name.
+`,
+		Flags: []cli.Flag{
+			utils.GatewayFlag,
+			utils.DustRewardFlag,
+			utils.RewardTokenFlag,
+			utils.TotalRewardsFlag,
+			utils.InputFileSliceFlag,
+			utils.OutputFileSliceFlag,
+			utils.SafeAddressFlag,
+			utils.MultiSendAddressFlag,
+			utils.ProposalSizeFlag,
+			utils.SafeBatchFormatFlag,
+			utils.ScalingValueFlag,
+		},
+	}
+)
+
+func safeBatch(ctx *cli.Context) error {
+	serverURL := ctx.String(utils.GatewayFlag.Name)
+	if serverURL == "" {
+		return fmt.Errorf("must specify gateway URL")
+	}
+
+	capi := utils.InitAppWithURL(ctx, serverURL, false)
+	distributer.SetAPICaller(capi)
+	defer capi.CloseClient()
+
+	err := setConfigParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	opt := &distributer.Option{
+		RewardToken: ctx.String(utils.RewardTokenFlag.Name),
+		InputFiles:  ctx.StringSlice(utils.InputFileSliceFlag.Name),
+		OutputFiles: ctx.StringSlice(utils.OutputFileSliceFlag.Name),
+	}
+	if ctx.IsSet(utils.TotalRewardsFlag.Name) {
+		opt.TotalValue, err = tools.GetBigIntFromString(ctx.String(utils.TotalRewardsFlag.Name))
+		if err != nil {
+			return err
+		}
+	}
+	opt.ScalingNumerator, opt.ScalingDenominator = getScalingValue(ctx.String(utils.ScalingValueFlag.Name))
+
+	args := &distributer.SafeBatchArgs{
+		Safe:         ctx.String(utils.SafeAddressFlag.Name),
+		MultiSend:    ctx.String(utils.MultiSendAddressFlag.Name),
+		ProposalSize: ctx.Uint64(utils.ProposalSizeFlag.Name),
+		Format:       ctx.String(utils.SafeBatchFormatFlag.Name),
+	}
+	return opt.BuildSafeBatchFromFile(args)
+}
@@ -32,13 +32,17 @@ send rewards batchly according to verified input file with line format: <address
 			utils.SenderFlag,
 			utils.KeyStoreFileFlag,
 			utils.PasswordFileFlag,
+			utils.SignerTypeFlag,
+			utils.SignerURLFlag,
 			utils.GasLimitFlag,
 			utils.GasPriceFlag,
 			utils.AccountNonceFlag,
 			utils.SaveDBFlag,
 			utils.DryRunFlag,
+			utils.OverrideLimitsFlag,
 			utils.BatchCountFlag,
 			utils.BatchIntervalFlag,
+			utils.MaxInflightFlag,
 			utils.ScalingValueFlag,
 		},
 	}
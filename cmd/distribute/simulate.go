@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/anyswap/ANYToken-distribution/cmd/utils"
+	"github.com/anyswap/ANYToken-distribution/distributer"
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	simulateCommand = &cli.Command{
+		Action:    simulate,
+		Name:      "simulate",
+		Usage:     "simulate past cycles with alternative reward params",
+		ArgsUsage: " ",
+		Description: `
+replay past cycles in range [start, end) from database with overridden params,
+and compare with the recorded rewards. it never sends any transaction.
+overridable params are exchanges and weights, liquidity rewards per cycle,
+volume rewards per step, stake points and percents, percentage weight and dust threshold.
+per account comparison is written to output file, and per exchange summary is logged.
+`,
+		Flags: []cli.Flag{
+			utils.GatewayFlag,
+			utils.RewardTyepFlag,
+			utils.StartHeightFlag,
+			utils.EndHeightFlag,
+			utils.ExchangeSliceFlag,
+			utils.WeightSliceFlag,
+			utils.PercentageWeightFlag,
+			utils.TotalRewardsFlag,
+			utils.StepRewardFlag,
+			utils.StakePointsFlag,
+			utils.StakePercentsFlag,
+			utils.DustRewardFlag,
+			utils.ChangeThresholdFlag,
+			utils.OutputFileFlag,
+		},
+	}
+)
+
+func simulate(ctx *cli.Context) (err error) {
+	args := &distributer.SimulateArgs{
+		ByWhat:          ctx.String(utils.RewardTyepFlag.Name),
+		Start:           ctx.Uint64(utils.StartHeightFlag.Name),
+		End:             ctx.Uint64(utils.EndHeightFlag.Name),
+		Exchanges:       ctx.StringSlice(utils.ExchangeSliceFlag.Name),
+		ChangeThreshold: ctx.Uint64(utils.ChangeThresholdFlag.Name),
+		OutputFile:      ctx.String(utils.OutputFileFlag.Name),
+	}
+	if args.OutputFile == "" {
+		return fmt.Errorf("must specify output file")
+	}
+	if args.Weights, err = toUint64Slice(ctx.Int64Slice(utils.WeightSliceFlag.Name)); err != nil {
+		return err
+	}
+	if ctx.IsSet(utils.PercentageWeightFlag.Name) {
+		isPercentage := ctx.Bool(utils.PercentageWeightFlag.Name)
+		args.IsPercentage = &isPercentage
+	}
+	if ctx.IsSet(utils.TotalRewardsFlag.Name) {
+		if args.TotalReward, err = tools.GetBigIntFromString(ctx.String(utils.TotalRewardsFlag.Name)); err != nil {
+			return err
+		}
+	}
+	if ctx.IsSet(utils.StepRewardFlag.Name) {
+		if args.StepReward, err = tools.GetBigIntFromString(ctx.String(utils.StepRewardFlag.Name)); err != nil {
+			return err
+		}
+	}
+
+	capi := utils.InitAppWithURL(ctx, ctx.String(utils.GatewayFlag.Name), true)
+	distributer.SetAPICaller(capi)
+	defer capi.CloseClient()
+
+	if err = setConfigParams(ctx); err != nil {
+		return err
+	}
+	if ctx.IsSet(utils.StakePointsFlag.Name) {
+		points, errf := toUint64Slice(ctx.Int64Slice(utils.StakePointsFlag.Name))
+		if errf != nil {
+			return errf
+		}
+		percents, errf := toUint64Slice(ctx.Int64Slice(utils.StakePercentsFlag.Name))
+		if errf != nil {
+			return errf
+		}
+		params.SetStakePoints(points, percents)
+	}
+
+	results, err := distributer.Simulate(args)
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		exchange := res.Exchange
+		if exchange == "" {
+			exchange = "all"
+		}
+		log.Info("[simulate] result", "exchange", exchange, "accounts", res.Accounts,
+			"actualTotal", res.ActualTotal, "simulatedTotal", res.SimulatedTotal,
+			"actualGini", fmt.Sprintf("%.4f", res.ActualGini), "simulatedGini", fmt.Sprintf("%.4f", res.SimulatedGini),
+			"changeThreshold", fmt.Sprintf("%v%%", args.ChangeThreshold), "changedAccounts", res.ChangedCount)
+	}
+	return nil
+}
+
+func toUint64Slice(values []int64) ([]uint64, error) {
+	result := make([]uint64, len(values))
+	for i, v := range values {
+		if v < 0 {
+			return nil, fmt.Errorf("negative value %v", v)
+		}
+		result[i] = uint64(v)
+	}
+	return result, nil
+}
@@ -30,7 +30,11 @@ func getOptionAndTxArgs(ctx *cli.Context) (*distributer.Option, error) {
 		return nil, err
 	}
 
-	err = setConfigParams(ctx)
+	return getOption(ctx, args, rewards)
+}
+
+func getOption(ctx *cli.Context, args *distributer.BuildTxArgs, rewards *big.Int) (*distributer.Option, error) {
+	err := setConfigParams(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +73,7 @@ func getOptionAndTxArgs(ctx *cli.Context) (*distributer.Option, error) {
 		SampleHeight:       ctx.Uint64(utils.SampleFlag.Name),
 		SaveDB:             ctx.Bool(utils.SaveDBFlag.Name),
 		DryRun:             ctx.Bool(utils.DryRunFlag.Name),
+		OverrideLimits:     ctx.Bool(utils.OverrideLimitsFlag.Name),
 		BatchCount:         ctx.Uint64(utils.BatchCountFlag.Name),
 		BatchInterval:      ctx.Uint64(utils.BatchIntervalFlag.Name),
 		UseTimeMeasurement: ctx.Bool(utils.UseTimeMeasurementFlag.Name),
@@ -93,6 +98,20 @@ func getOptionAndTxArgs(ctx *cli.Context) (*distributer.Option, error) {
 }
 
 func getBuildTxArgs(ctx *cli.Context) (*distributer.BuildTxArgs, error) {
+	args, err := newBuildTxArgs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dryRun := ctx.Bool(utils.DryRunFlag.Name)
+	if err := args.Check(dryRun); err != nil {
+		return nil, err
+	}
+
+	return args, nil
+}
+
+func newBuildTxArgs(ctx *cli.Context) (*distributer.BuildTxArgs, error) {
 	var (
 		gasPrice    *big.Int
 		gasLimitPtr *uint64
@@ -127,16 +146,21 @@ func getBuildTxArgs(ctx *cli.Context) (*distributer.BuildTxArgs, error) {
 
 	args := &distributer.BuildTxArgs{
 		Sender:       ctx.String(utils.SenderFlag.Name),
+		SignerType:   ctx.String(utils.SignerTypeFlag.Name),
+		SignerURL:    ctx.String(utils.SignerURLFlag.Name),
 		KeystoreFile: ctx.String(utils.KeyStoreFileFlag.Name),
 		PasswordFile: ctx.String(utils.PasswordFileFlag.Name),
 		Nonce:        noncePtr,
 		GasLimit:     gasLimitPtr,
 		GasPrice:     gasPrice,
+		MaxInflight:  ctx.Uint64(utils.MaxInflightFlag.Name),
 	}
 
-	dryRun := ctx.Bool(utils.DryRunFlag.Name)
-	if err := args.Check(dryRun); err != nil {
-		return nil, err
+	// use signer of config if not specified in command line
+	distCfg := params.GetConfig().Distribute
+	if distCfg != nil && !ctx.IsSet(utils.SignerTypeFlag.Name) && !ctx.IsSet(utils.SignerURLFlag.Name) {
+		args.SignerType = distCfg.SignerType
+		args.SignerURL = distCfg.SignerURL
 	}
 
 	return args, nil
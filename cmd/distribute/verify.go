@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/anyswap/ANYToken-distribution/cmd/utils"
+	"github.com/anyswap/ANYToken-distribution/distributer"
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	verifyCommand = &cli.Command{
+		Action:    verify,
+		Name:      "verify",
+		Usage:     "verify rewards file by recomputing",
+		ArgsUsage: " ",
+		Description: `
+verify rewards file written by 'byliquidity', 'byvolume' or distribute service.
+parse cycle info and parameters from the title line, recompute every account's share and reward
+independently from database and chain with those parameters, and print diff of each account.
+files without recorded parameters are verified by config file.
+exit with error if any mismatch is beyond rounding.
+`,
+		Flags: []cli.Flag{
+			utils.GatewayFlag,
+			utils.InputFileSliceFlag,
+		},
+	}
+)
+
+func verify(ctx *cli.Context) error {
+	inputFiles := ctx.StringSlice(utils.InputFileSliceFlag.Name)
+	if len(inputFiles) == 0 {
+		return fmt.Errorf("must specify input file")
+	}
+
+	capi := utils.InitAppWithURL(ctx, ctx.String(utils.GatewayFlag.Name), true)
+	distributer.SetAPICaller(capi)
+	defer capi.CloseClient()
+
+	var failed int
+	for _, inputFile := range inputFiles {
+		if err := distributer.VerifyRewardsFile(inputFile); err != nil {
+			log.Error("verify rewards file failed", "input", inputFile, "err", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("verify failed, %v of %v files mismatch", failed, len(inputFiles))
+	}
+	log.Info("verify success", "files", len(inputFiles))
+	return nil
+}
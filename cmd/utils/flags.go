@@ -82,6 +82,17 @@ var (
 		Name:  "password",
 		Usage: "password file path",
 	}
+	// SignerTypeFlag --signer
+	SignerTypeFlag = &cli.StringFlag{
+		Name:  "signer",
+		Usage: "signer type (ie. keystore,clef,http)",
+		Value: "keystore",
+	}
+	// SignerURLFlag --signerURL
+	SignerURLFlag = &cli.StringFlag{
+		Name:  "signerURL",
+		Usage: "remote signer URL of clef or http signer",
+	}
 	// GasLimitFlag --gas
 	GasLimitFlag = &cli.StringFlag{
 		Name:  "gasLimit",
@@ -228,6 +239,16 @@ var (
 		Usage: "batch interval of milli seconds",
 		Value: 13000,
 	}
+	// MaxInflightFlag --maxInflight
+	MaxInflightFlag = &cli.Uint64Flag{
+		Name:  "maxInflight",
+		Usage: "max count of pipelined in-flight transactions (if set, batchCount and batchInterval are ignored)",
+	}
+	// OverrideLimitsFlag --overrideLimits
+	OverrideLimitsFlag = &cli.BoolFlag{
+		Name:  "overrideLimits",
+		Usage: "send rewards even if configed spending limits are breached",
+	}
 	// OnlySyncAccountFlag --onlySyncAccount
 	OnlySyncAccountFlag = &cli.BoolFlag{
 		Name:  "onlySyncAccount",
@@ -248,11 +269,74 @@ var (
 		Name:  "percentWeight",
 		Usage: "weight is percentage",
 	}
+	// SafeAddressFlag --safe
+	SafeAddressFlag = &cli.StringFlag{
+		Name:  "safe",
+		Usage: "gnosis safe address",
+	}
+	// MultiSendAddressFlag --multiSend
+	MultiSendAddressFlag = &cli.StringFlag{
+		Name:  "multiSend",
+		Usage: "gnosis safe MultiSend (or MultiSendCallOnly) contract address",
+	}
+	// ProposalSizeFlag --proposalSize
+	ProposalSizeFlag = &cli.Uint64Flag{
+		Name:  "proposalSize",
+		Usage: "transfer count per safe proposal",
+		Value: 100,
+	}
+	// SafeBatchFormatFlag --format
+	SafeBatchFormatFlag = &cli.StringFlag{
+		Name:  "format",
+		Usage: "safe batch format (ie. txbuilder,multisend)",
+		Value: "txbuilder",
+	}
 	// ScalingValueFlag --scaling
 	ScalingValueFlag = &cli.StringFlag{
 		Name:  "scaling",
 		Usage: "scaling value, comma separated interger of numerator and denominator. eg. 80,100 is scaling 80%",
 	}
+	// StakePointsFlag --stakePoints
+	StakePointsFlag = &cli.Int64SliceFlag{
+		Name:  "stakePoints",
+		Usage: "stake points (whole unit of stake token)",
+	}
+	// StakePercentsFlag --stakePercents
+	StakePercentsFlag = &cli.Int64SliceFlag{
+		Name:  "stakePercents",
+		Usage: "added percents of stake points",
+	}
+	// ChangeThresholdFlag --changeThreshold
+	ChangeThresholdFlag = &cli.Uint64Flag{
+		Name:  "changeThreshold",
+		Usage: "count accounts whose reward changes more than this percentage",
+		Value: 10,
+	}
+	// AccountFlag --account
+	AccountFlag = &cli.StringFlag{
+		Name:  "account",
+		Usage: "account address",
+	}
+	// AccountListFlag --list
+	AccountListFlag = &cli.StringFlag{
+		Name:  "list",
+		Usage: "account list name, 'deny' or 'allow'",
+	}
+	// ReasonFlag --reason
+	ReasonFlag = &cli.StringFlag{
+		Name:  "reason",
+		Usage: "reason of account list entry",
+	}
+	// ProgramFlag --program
+	ProgramFlag = &cli.StringFlag{
+		Name:  "program",
+		Usage: "reward program id, empty for the default distribution",
+	}
+	// ReferrerFlag --referrer
+	ReferrerFlag = &cli.StringFlag{
+		Name:  "referrer",
+		Usage: "referrer address",
+	}
 )
 
 // SyncArguments command line arguments
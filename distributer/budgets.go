@@ -0,0 +1,194 @@
+package distributer
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+)
+
+// exchange budget in cycle
+type exchangeBudget struct {
+	active bool
+	fixed  *big.Int // nil means sharing by weight
+}
+
+func (opt *Option) getExchangeBudgets() []*exchangeBudget {
+	budgets := make([]*exchangeBudget, len(opt.Exchanges))
+	for i, exchange := range opt.Exchanges {
+		budget := &exchangeBudget{active: true}
+		if exCfg := params.GetExchangeConfig(exchange); exCfg != nil {
+			budget.active = exCfg.IsActiveIn(opt.StartHeight, opt.EndHeight, opt.UseTimeMeasurement)
+			if budget.active {
+				budget.fixed = opt.getFixedExchangeRewards(exCfg)
+			}
+			if budget.fixed != nil {
+				budget.fixed = opt.cutNoVolumeSteps(budget.fixed)
+			}
+		}
+		budgets[i] = budget
+	}
+	return budgets
+}
+
+// fixed rewards of exchange in range of option, only for the default distribution
+func (opt *Option) getFixedExchangeRewards(exCfg *params.ExchangeConfig) *big.Int {
+	if opt.Program != "" {
+		return nil
+	}
+	switch opt.byWhat {
+	case byLiquidMethodID:
+		return exCfg.GetLiquidRewardsPerCycle()
+	case byVolumeMethodID:
+		rewards := exCfg.GetTradeRewardsPerCycle()
+		cycleLen := params.GetConfig().Distribute.GetByLiquidCycleLen()
+		if rewards == nil || cycleLen == 0 || opt.EndHeight-opt.StartHeight == cycleLen {
+			return rewards
+		}
+		// settle volume rewards of steps
+		rewards.Mul(rewards, new(big.Int).SetUint64(opt.EndHeight-opt.StartHeight))
+		return rewards.Div(rewards, new(big.Int).SetUint64(cycleLen))
+	}
+	return nil
+}
+
+// fixed volume rewards of steps without volumes are cut as the shared rewards,
+// unless the unspent rewards are redistributed in the same cycle.
+func (opt *Option) cutNoVolumeSteps(rewards *big.Int) *big.Int {
+	if opt.byWhat != byVolumeMethodID || opt.noVolumes == 0 || opt.StepCount == 0 ||
+		opt.unspentPolicy() == params.UnspentPolicyRedistribute {
+		return rewards
+	}
+	steps := (opt.EndHeight - opt.StartHeight) / opt.StepCount
+	if opt.noVolumes >= steps {
+		return big.NewInt(0)
+	}
+	rewards = new(big.Int).Mul(rewards, new(big.Int).SetUint64(steps-opt.noVolumes))
+	return rewards.Div(rewards, new(big.Int).SetUint64(steps))
+}
+
+// fixed rewards of exchanges can not exceed the emission of cycle
+func (opt *Option) checkExchangeBudgets() error {
+	if opt.Program != "" {
+		return nil
+	}
+	distCfg := params.GetConfig().Distribute
+	sumFixed := big.NewInt(0)
+	for _, exchange := range opt.Exchanges {
+		exCfg := params.GetExchangeConfig(exchange)
+		if exCfg == nil || !exCfg.IsActiveIn(opt.StartHeight, opt.EndHeight, opt.UseTimeMeasurement) {
+			continue
+		}
+		if fixed := opt.getFixedExchangeRewards(exCfg); fixed != nil {
+			sumFixed.Add(sumFixed, fixed)
+		}
+	}
+	if sumFixed.Sign() == 0 {
+		return nil
+	}
+	var cycleRewards *big.Int
+	if opt.byWhat == byVolumeMethodID && opt.StepReward == nil {
+		// rewards of volume steps are not specified, use steps of config
+		cycleRewards = distCfg.GetByVolumeRewardsAt(opt.StartHeight)
+		if stepLen := distCfg.GetByVolumeCycleLen(); stepLen > 0 {
+			cycleRewards.Mul(cycleRewards, new(big.Int).SetUint64((opt.EndHeight-opt.StartHeight)/stepLen))
+		}
+	} else {
+		cycleRewards = opt.getConfigedCycleRewards(distCfg)
+	}
+	if sumFixed.Cmp(cycleRewards) > 0 {
+		return fmt.Errorf("sum of fixed %v rewards %v exceeds emission %v of cycle [%v, %v)", opt.byWhat, sumFixed, cycleRewards, opt.StartHeight, opt.EndHeight)
+	}
+	return nil
+}
+
+func (opt *Option) hasFixedExchangeRewards(exchange string) bool {
+	exCfg := params.GetExchangeConfig(exchange)
+	return exCfg != nil && opt.getFixedExchangeRewards(exCfg) != nil
+}
+
+// divide total rewards to exchanges by budgets of cycle.
+// inactive exchanges get nothing and their stats are cleared,
+// exchanges of fixed rewards get their budgets,
+// the rest is divided among other exchanges by 'weightedDivide'.
+func (opt *Option) divideByExchangeBudgets(
+	accountStats []mongodb.AccountStatSlice,
+	totalReward *big.Int,
+	weightedDivide func(sub *Option, stats []mongodb.AccountStatSlice, sharedReward *big.Int) []*big.Int,
+) []*big.Int {
+	budgets := opt.getExchangeBudgets()
+	isAllShared := true
+	for _, budget := range budgets {
+		if !budget.active || budget.fixed != nil {
+			isAllShared = false
+			break
+		}
+	}
+	if isAllShared {
+		return weightedDivide(opt, accountStats, totalReward)
+	}
+
+	rewards := make([]*big.Int, len(opt.Exchanges))
+	var fixedIndexes, sharedIndexes []int
+	var fixedRewards []*big.Int
+	sumFixed := big.NewInt(0)
+	for i, budget := range budgets {
+		rewards[i] = big.NewInt(0)
+		switch {
+		case !budget.active:
+			log.Info("exchange is inactive in cycle", "exchange", opt.Exchanges[i], "bywhat", opt.byWhat, "start", opt.StartHeight, "end", opt.EndHeight)
+			accountStats[i] = nil
+		case budget.fixed != nil:
+			fixedIndexes = append(fixedIndexes, i)
+			fixedRewards = append(fixedRewards, budget.fixed)
+			sumFixed.Add(sumFixed, budget.fixed)
+		default:
+			sharedIndexes = append(sharedIndexes, i)
+		}
+	}
+
+	sharedReward := new(big.Int).Sub(totalReward, sumFixed)
+	if sharedReward.Sign() < 0 {
+		log.Warn("fixed exchange rewards exceed total rewards, divide total rewards by fixed rewards", "sumFixed", sumFixed, "totalReward", totalReward)
+		fixedRewards = mongodb.DivideRewards(totalReward, fixedRewards)
+		sharedReward = big.NewInt(0)
+	}
+	for j, i := range fixedIndexes {
+		rewards[i] = fixedRewards[j]
+	}
+
+	if len(sharedIndexes) != 0 && sharedReward.Sign() > 0 {
+		sub := *opt
+		sub.Exchanges = make([]string, len(sharedIndexes))
+		sub.Weights = make([]uint64, len(sharedIndexes))
+		sub.exchangeShares = nil
+		subStats := make([]mongodb.AccountStatSlice, len(sharedIndexes))
+		for j, i := range sharedIndexes {
+			sub.Exchanges[j] = opt.Exchanges[i]
+			sub.Weights[j] = opt.Weights[i]
+			subStats[j] = accountStats[i]
+		}
+		subRewards := weightedDivide(&sub, subStats, sharedReward)
+		if len(subRewards) != len(sharedIndexes) {
+			return nil
+		}
+		if sub.exchangeShares != nil {
+			opt.exchangeShares = make([]*ExchangeShare, len(opt.Exchanges))
+		}
+		for j, i := range sharedIndexes {
+			rewards[i] = subRewards[j]
+			if sub.exchangeShares != nil {
+				opt.exchangeShares[i] = sub.exchangeShares[j]
+			}
+		}
+	}
+
+	for i, exchange := range opt.Exchanges {
+		log.Info("divide rewards by exchange budgets", "exchange", strings.ToLower(exchange), "bywhat", opt.byWhat,
+			"start", opt.StartHeight, "end", opt.EndHeight, "active", budgets[i].active, "fixed", budgets[i].fixed != nil, "reward", rewards[i])
+	}
+	return rewards
+}
@@ -21,39 +21,71 @@ func ByLiquidity(opt *Option) error {
 		return errTotalRewardsIsZero
 	}
 	opt.CalcSampleHeight()
+	// carried in rewards are included in the sender balance check
+	opt.carryInUnspentRewards()
 	err := opt.checkAndInit()
 	defer opt.deinit()
 	if err != nil {
 		log.Error("[byliquid] check option error", "option", opt.String(), "err", err)
 		return errCheckOptionFailed
 	}
+	accountStats, err := opt.calcLiquidityRewards()
+	if err != nil {
+		return err
+	}
+	opt.saveRewardExclusions()
+	opt.applyUnspentPolicy(accountStats)
+	err = opt.dispatchRewards(accountStats)
+	if err != nil {
+		return err
+	}
+	opt.saveUnspentRewards()
+	return nil
+}
+
+// calc liquidity rewards of all exchanges without sending
+func (opt *Option) calcLiquidityRewards() ([]mongodb.AccountStatSlice, error) {
+	if err := opt.checkExchangeBudgets(); err != nil {
+		log.Error("[byliquid] check exchange budgets error", "err", err)
+		return nil, err
+	}
 	accountStats, err := opt.GetAccountsAndShares()
 	if err != nil {
 		log.Error("[byliquid] GetAccountsAndShares error", "err", err)
-		return errGetAccountsSharesFailed
+		return nil, errGetAccountsSharesFailed
 	}
 	if len(accountStats) == 0 {
 		accounts, err := opt.getAccounts()
 		if err != nil {
 			log.Error("[byliquid] get accounts error", "err", err)
-			return errGetAccountListFailed
+			return nil, errGetAccountListFailed
+		}
+		accountStats, err = opt.getLiquidityBalances(accounts)
+		if err != nil {
+			log.Error("[byliquid] get liquidity balances error", "err", err)
+			return nil, err
 		}
-		accountStats = opt.getLiquidityBalances(accounts)
 	}
 	if len(accountStats) != len(opt.Exchanges) {
 		log.Warn("[byliquid] account list is not complete. " + opt.String())
-		return errAccountsNotComplete
+		return nil, errAccountsNotComplete
 	}
-	mongodb.CalcWeightedRewards(accountStats, opt.TotalValue, opt.Weights)
-	return opt.dispatchRewards(accountStats)
+	rewards := opt.divideByExchangeBudgets(accountStats, opt.TotalValue, func(sub *Option, stats []mongodb.AccountStatSlice, sharedReward *big.Int) []*big.Int {
+		return mongodb.DivideWeightedRewards(stats, sharedReward, sub.Weights)
+	})
+	mongodb.CalcRewardsInBatch(accountStats, rewards)
+	return accountStats, nil
 }
 
-func (opt *Option) getLiquidityBalances(accountsSlice [][]common.Address) (accountStats []mongodb.AccountStatSlice) {
+func (opt *Option) getLiquidityBalances(accountsSlice [][]common.Address) (accountStats []mongodb.AccountStatSlice, err error) {
 	accountStats = make([]mongodb.AccountStatSlice, len(opt.Exchanges))
 	for i, exchange := range opt.Exchanges {
 		accounts := accountsSlice[i]
 		WriteLiquiditySubject(exchange, opt.StartHeight, opt.EndHeight, len(accounts))
-		stats, _ := opt.getLiquidityBalancesOfExchange(exchange, accounts)
+		stats, _, err := opt.getLiquidityBalancesOfExchange(exchange, accounts)
+		if err != nil {
+			return nil, err
+		}
 		totalLiquids := stats.CalcTotalShare()
 		WriteLiquiditySummary(exchange, opt.StartHeight, opt.EndHeight, len(stats), totalLiquids, opt.TotalValue)
 		for _, stat := range stats {
@@ -61,10 +93,10 @@ func (opt *Option) getLiquidityBalances(accountsSlice [][]common.Address) (accou
 		}
 		accountStats[i] = stats
 	}
-	return accountStats
+	return accountStats, nil
 }
 
-func (opt *Option) getLiquidityBalancesOfExchange(exchange string, accounts []common.Address) (accountStats mongodb.AccountStatSlice, complete bool) {
+func (opt *Option) getLiquidityBalancesOfExchange(exchange string, accounts []common.Address) (accountStats mongodb.AccountStatSlice, complete bool, err error) {
 	exchangeAddr := common.HexToAddress(exchange)
 
 	finStatMap := make(map[common.Address]*mongodb.AccountStat)
@@ -115,6 +147,10 @@ func (opt *Option) getLiquidityBalancesOfExchange(exchange string, accounts []co
 		if params.IsExcludedRewardAccount(account) {
 			continue
 		}
+		if reason, detail := mongodb.CheckRewardAccount(account, opt.StartHeight); reason != "" {
+			opt.addRewardExclusion(exchange, account, reason, coinBalance, detail)
+			continue
+		}
 		totalCoinBalance.Add(totalCoinBalance, coinBalance)
 		finStat, exist := finStatMap[account]
 		if exist {
@@ -130,6 +166,12 @@ func (opt *Option) getLiquidityBalancesOfExchange(exchange string, accounts []co
 			}
 		}
 	}
+	err = applyContractPolicy(finStatMap, blockNumber, func(account common.Address, reason string, amount *big.Int, detail string) {
+		opt.addRewardExclusion(exchange, account, reason, amount, detail)
+	})
+	if err != nil {
+		return nil, false, err
+	}
 	diffLiquid := new(big.Int).Sub(totalSupply, totalLiquid)
 	diffLiquid = diffLiquid.Abs(diffLiquid)
 	if new(big.Int).Mul(diffLiquid, big.NewInt(20)).Cmp(totalLiquid) <= 0 { // allow 5% diff
@@ -137,7 +179,9 @@ func (opt *Option) getLiquidityBalancesOfExchange(exchange string, accounts []co
 	}
 	log.Info("[byliquid] check if account list is complete", "exchange", exchange, "smaple", height, "totalsupply", totalSupply, "totalLiquid", totalLiquid, "diffLiquid", diffLiquid)
 
-	return mongodb.ConvertToSortedSlice(finStatMap), complete
+	accountStats = mongodb.ConvertToSortedSlice(finStatMap)
+	opt.boostSharesByStake(exchange, opt.StartHeight, accountStats, blockNumber)
+	return accountStats, complete, nil
 }
 
 // CalcSampleHeight calc sample height
@@ -17,42 +17,80 @@ func ByVolume(opt *Option) error {
 		log.Warn("no volume rewards", "option", opt.String())
 		return errTotalRewardsIsZero
 	}
+	// carried in rewards are included in the sender balance check
+	opt.carryInUnspentRewards()
 	err := opt.checkAndInit()
 	defer opt.deinit()
 	if err != nil {
 		log.Error("[byvolume] check option error", "option", opt.String(), "err", err)
 		return errCheckOptionFailed
 	}
+	accountStats, err := opt.calcVolumeRewards()
+	if err != nil {
+		return err
+	}
+	opt.saveRewardExclusions()
+	if accountStats != nil {
+		// referral rewards are cut from volume rewards of this cycle before the unspent pass
+		referralStats := opt.calcReferralRewards(accountStats)
+		opt.applyUnspentPolicy(accountStats)
+		err = opt.dispatchRewards(accountStats)
+		if err != nil {
+			return err
+		}
+		err = opt.dispatchReferralRewards(referralStats)
+		if err != nil {
+			return err
+		}
+	}
+	opt.saveUnspentRewards()
+	return nil
+}
+
+// calc volume rewards of all exchanges without sending,
+// return nil stats if there is no rewards to send.
+func (opt *Option) calcVolumeRewards() ([]mongodb.AccountStatSlice, error) {
+	if err := opt.checkExchangeBudgets(); err != nil {
+		log.Error("[byvolume] check exchange budgets error", "err", err)
+		return nil, err
+	}
 	accountStats, err := opt.GetAccountsAndRewards()
 	if err != nil {
 		log.Error("[byvolume] GetAccountsAndRewards error", "err", err)
-		return errGetAccountsRewardsFailed
+		return nil, errGetAccountsRewardsFailed
 	}
 	if len(accountStats) != len(opt.Exchanges) {
 		log.Warn("[byvolume] account list is not complete. " + opt.String())
-		return errAccountsNotComplete
+		return nil, errAccountsNotComplete
 	}
 	totalReward := opt.TotalValue
 	if opt.noVolumes > 0 && opt.StepReward.Sign() > 0 {
 		subReward := new(big.Int).Mul(opt.StepReward, new(big.Int).SetUint64(opt.noVolumes))
-		log.Info("[byvolume] has novolums", "novolumes", opt.noVolumes, "subReward", subReward)
-		totalReward = new(big.Int).Sub(totalReward, subReward)
+		log.Info("[byvolume] has novolums", "novolumes", opt.noVolumes, "subReward", subReward, "unspentPolicy", opt.unspentPolicy())
+		if opt.unspentPolicy() != params.UnspentPolicyRedistribute {
+			if subReward.Cmp(totalReward) > 0 {
+				subReward = totalReward
+			}
+			opt.addUnspentReward("", unspentReasonNoVolume, opt.unspentStatus(), subReward, 0)
+			totalReward = new(big.Int).Sub(totalReward, subReward)
+		}
 	}
 	if totalReward.Sign() <= 0 {
-		return nil
-	}
-	var rewards []*big.Int
-	if opt.WeightIsPercentage {
-		rewards = opt.divideVolumeRewardsByPercentage(totalReward)
-	} else {
-		rewards = opt.divideVolumeRewardsByExchange(accountStats, totalReward)
+		return nil, nil
 	}
+	rewards := opt.divideByExchangeBudgets(accountStats, totalReward, func(sub *Option, stats []mongodb.AccountStatSlice, sharedReward *big.Int) []*big.Int {
+		if sub.WeightIsPercentage {
+			return sub.divideVolumeRewardsByPercentage(sharedReward)
+		}
+		return sub.divideVolumeRewardsByExchange(stats, sharedReward)
+	})
 	if len(rewards) != len(accountStats) {
 		log.Warn("[byvolume] divided rewards by exchange liquidity failed")
-		return nil
+		opt.addUnspentReward("", unspentReasonUnallocated, opt.unspentStatus(), totalReward, 0)
+		return nil, nil
 	}
 	mongodb.CalcRewardsInBatch(accountStats, rewards)
-	return opt.dispatchRewards(accountStats)
+	return accountStats, nil
 }
 
 func (opt *Option) divideVolumeRewardsByPercentage(totalReward *big.Int) []*big.Int {
@@ -86,6 +124,16 @@ func calcPrevCycleSttEnd(height uint64, useTimeMeasurement bool) (preCycleStart,
 	return preCycleStart, preCycleEnd
 }
 
+// ExchangeShare exchange level share of volume rewards
+type ExchangeShare struct {
+	Exchange   string
+	Weight     uint64
+	SumShare   *big.Int // sum of account shares
+	UpperLimit *big.Int // exchange liquidity (represent by coin) as upper limit
+	Truncated  bool     // is truncated to upper limit
+	Share      *big.Int // weighted share after truncated
+}
+
 func (opt *Option) divideVolumeRewardsByExchange(accountStats []mongodb.AccountStatSlice, totalReward *big.Int) []*big.Int {
 	if len(opt.Exchanges) == 1 {
 		return []*big.Int{totalReward}
@@ -112,10 +160,12 @@ func (opt *Option) divideVolumeRewardsByExchange(accountStats []mongodb.AccountS
 		"preCycleStart", preCycleStart, "preCycleEnd", preCycleEnd, "sampleHeight", sampleHeight)
 
 	exchangeShares := make([]*big.Int, len(opt.Exchanges))
+	opt.exchangeShares = make([]*ExchangeShare, len(opt.Exchanges))
 	for i, exchange := range opt.Exchanges {
 		sumShare := accountStats[i].CalcTotalShare()
 		if sumShare.Sign() == 0 {
 			exchangeShares[i] = big.NewInt(0)
+			opt.exchangeShares[i] = &ExchangeShare{Exchange: exchange, SumShare: sumShare, Share: exchangeShares[i]}
 			continue
 		}
 
@@ -139,6 +189,14 @@ func (opt *Option) divideVolumeRewardsByExchange(accountStats []mongodb.AccountS
 		}
 
 		exchangeShares[i] = totalShare
+		opt.exchangeShares[i] = &ExchangeShare{
+			Exchange:   exchange,
+			Weight:     weight,
+			SumShare:   sumShare,
+			UpperLimit: exCoinBalance,
+			Truncated:  truncated,
+			Share:      totalShare,
+		}
 		log.Info("divide volume rewards by exchange", "archiveMode", opt.ArchiveMode,
 			"start", opt.StartHeight, "end", opt.EndHeight, "sampleHeight", sampleHeight,
 			"exchange", exchange, "weight", weight, "totalShare", totalShare, "sumShare", sumShare,
@@ -0,0 +1,189 @@
+package distributer
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+// contract accounts exclusion reasons
+const (
+	ExcludeContract   = "contract"   // contract account is excluded
+	ExcludeRedirected = "redirected" // rewards of contract account is redirected to beneficiary
+)
+
+// output annotations, parsers ignore parts starting with '@'
+const (
+	annotationPrefix   = "@"
+	annotationContract = "@contract"
+	annotationRedirect = "@redirect="
+	annotationPending  = "@pending="
+	annotationUSD      = "@usd="
+)
+
+type contractAccountKey struct {
+	account     common.Address
+	blockNumber uint64 // 0 means latest
+}
+
+// cache of eth_getCode results
+var (
+	contractAccounts     = make(map[contractAccountKey]bool)
+	contractAccountsLock sync.Mutex
+)
+
+// isContractAccount check if account has code at block number (nil means latest),
+// eth_getCode is retried in bounded times and error is returned to caller.
+func isContractAccount(account common.Address, blockNumber *big.Int) (bool, error) {
+	key := contractAccountKey{account: account}
+	if blockNumber != nil {
+		key.blockNumber = blockNumber.Uint64()
+	}
+	contractAccountsLock.Lock()
+	isContract, exist := contractAccounts[key]
+	contractAccountsLock.Unlock()
+	if exist {
+		return isContract, nil
+	}
+	code, err := capi.GetCode(account, blockNumber)
+	if err != nil {
+		return false, err
+	}
+	isContract = len(code) > 0
+	if blockNumber != nil { // code of latest block may change
+		contractAccountsLock.Lock()
+		contractAccounts[key] = isContract
+		contractAccountsLock.Unlock()
+	}
+	return isContract, nil
+}
+
+func getContractAccountsConfig() *params.ContractAccountsConfig {
+	contractsCfg := params.GetConfig().Contracts
+	if contractsCfg == nil || contractsCfg.Policy == "" {
+		return nil
+	}
+	return contractsCfg
+}
+
+// apply contract accounts policy on account stats with code at block number,
+// excluded or redirected shares are reported by the exclude callback.
+func applyContractPolicy(statMap map[common.Address]*mongodb.AccountStat, blockNumber *big.Int, exclude func(account common.Address, reason string, amount *big.Int, detail string)) error {
+	contractsCfg := getContractAccountsConfig()
+	if contractsCfg == nil {
+		return nil
+	}
+	var contracts []common.Address
+	for account := range statMap {
+		isContract, err := isContractAccount(account, blockNumber)
+		if err != nil {
+			return err
+		}
+		if isContract {
+			contracts = append(contracts, account)
+		}
+	}
+	for _, contract := range contracts {
+		stat := statMap[contract]
+		if contractsCfg.Policy != params.ContractPolicyExclude && contractsCfg.IsAllowedContract(contract) {
+			continue
+		}
+		delete(statMap, contract)
+		if contractsCfg.Policy != params.ContractPolicyRedirect {
+			log.Info("exclude contract account", "account", contract.String(), "share", stat.Share, "policy", contractsCfg.Policy)
+			exclude(contract, ExcludeContract, stat.Share, "")
+			continue
+		}
+		beneficiary := contractsCfg.GetBeneficiary(contract)
+		log.Info("redirect contract account", "account", contract.String(), "share", stat.Share, "beneficiary", beneficiary.String())
+		exclude(contract, ExcludeRedirected, stat.Share, fmt.Sprintf("beneficiary=%v", strings.ToLower(beneficiary.String())))
+		if benStat, exist := statMap[beneficiary]; exist {
+			benStat.Share = new(big.Int).Add(benStat.Share, stat.Share)
+		} else {
+			statMap[beneficiary] = &mongodb.AccountStat{
+				Account: beneficiary,
+				Share:   stat.Share,
+				Number:  stat.Number,
+			}
+		}
+	}
+	return nil
+}
+
+// apply contract accounts policy on account shares read from file
+func applyContractPolicyToStats(stats mongodb.AccountStatSlice, blockNumber *big.Int, exclude func(account common.Address, reason string, amount *big.Int, detail string)) (mongodb.AccountStatSlice, error) {
+	if getContractAccountsConfig() == nil {
+		return stats, nil
+	}
+	statMap := make(map[common.Address]*mongodb.AccountStat, len(stats))
+	for _, stat := range stats {
+		if exist, ok := statMap[stat.Account]; ok {
+			exist.Share = new(big.Int).Add(exist.Share, stat.Share)
+			continue
+		}
+		statMap[stat.Account] = stat
+	}
+	err := applyContractPolicy(statMap, blockNumber, exclude)
+	if err != nil {
+		return nil, err
+	}
+	return mongodb.ConvertToSortedSlice(statMap), nil
+}
+
+// annotations of output line of account
+func (opt *Option) getOutputAnnotations(exchange string, account common.Address) (annotations []string) {
+	if pending := opt.getPendingIn(exchange, account.String()); pending != "" {
+		annotations = append(annotations, annotationPending+pending)
+	}
+	if getContractAccountsConfig() == nil {
+		return annotations
+	}
+	isContract, err := isContractAccount(account, opt.getContractCheckBlock())
+	if err != nil {
+		log.Warn("check contract account failed, no annotation", "account", account.String(), "err", err)
+	} else if isContract {
+		annotations = append(annotations, annotationContract)
+	}
+	detail := fmt.Sprintf("beneficiary=%v", strings.ToLower(account.String()))
+	redirected := make(map[string]struct{})
+	for _, exclusion := range opt.getRewardExclusions() {
+		if exclusion.Reason != ExcludeRedirected || exclusion.Detail != detail || !strings.EqualFold(exclusion.Exchange, exchange) {
+			continue
+		}
+		if _, exist := redirected[exclusion.Account]; !exist {
+			redirected[exclusion.Account] = struct{}{}
+			annotations = append(annotations, annotationRedirect+exclusion.Account)
+		}
+	}
+	return annotations
+}
+
+// contract accounts are checked at the sample height of liquidity cycle,
+// or the end of volume cycle measured in block heights, otherwise the latest block.
+func (opt *Option) getContractCheckBlock() *big.Int {
+	if opt.SampleHeight != 0 && opt.ArchiveMode {
+		return new(big.Int).SetUint64(opt.SampleHeight)
+	}
+	if opt.byWhat == byVolumeMethodID && !opt.UseTimeMeasurement && opt.EndHeight > 0 {
+		return new(big.Int).SetUint64(opt.EndHeight - 1)
+	}
+	return nil
+}
+
+// SplitLineParts split blank or comma separated line, ignore annotations
+func SplitLineParts(line string) []string {
+	parts := blankOrCommaSepRegexp.Split(line, -1)
+	result := parts[:0]
+	for _, part := range parts {
+		if !strings.HasPrefix(part, annotationPrefix) {
+			result = append(result, part)
+		}
+	}
+	return result
+}
@@ -9,10 +9,15 @@ import (
 
 	"github.com/anyswap/ANYToken-distribution/log"
 	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/notify"
 	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
 )
 
 func (opt *Option) dispatchRewards(accountStats []mongodb.AccountStatSlice) error {
+	if err := opt.checkSpendingLimits(accountStats...); err != nil {
+		return err
+	}
 	for i, exchange := range opt.Exchanges {
 		rewardsSended, err := opt.sendRewards(i, exchange, accountStats[i])
 		if err != nil {
@@ -26,11 +31,13 @@ func (opt *Option) dispatchRewards(accountStats []mongodb.AccountStatSlice) erro
 				Exchange:     strings.ToLower(exchange),
 				Pairs:        params.GetExchangePairs(exchange),
 				ByWhat:       opt.byWhat,
+				Program:      opt.Program,
 				Start:        opt.StartHeight,
 				End:          opt.EndHeight,
 				RewardToken:  opt.RewardToken,
 				Rewards:      rewardsSended.String(),
 				SampleHeight: opt.SampleHeight,
+				RewardsUSD:   opt.rewardToUSD(rewardsSended),
 				Timestamp:    uint64(time.Now().Unix()),
 			}
 			_ = mongodb.TryDoTimes("AddDistributeInfo "+mdist.Pairs, func() error {
@@ -47,11 +54,15 @@ func (opt *Option) writeSendRewardTitleLine(outputFile io.Writer, exchange strin
 	case byLiquidMethodID:
 		keyShare = byLiquidMethodID
 		keyNumber = "height"
-		extraInfo = fmt.Sprintf("sampleHeight=%v", opt.SampleHeight)
+		extraInfo = fmt.Sprintf("sampleHeight=%v", opt.SampleHeight) + opt.getCycleParamsInfo()
 	case byVolumeMethodID:
 		keyShare = byVolumeMethodID
 		keyNumber = "txcount"
-		extraInfo = fmt.Sprintf("novolumes=%d", opt.noVolumes)
+		extraInfo = fmt.Sprintf("novolumes=%d", opt.noVolumes) + opt.getCycleParamsInfo()
+	case referralMethodID:
+		keyShare = "refereeReward"
+		keyNumber = "referees"
+		extraInfo = fmt.Sprintf("percent=%d", params.GetConfig().Referral.Percent)
 	default:
 		err = fmt.Errorf("unknown byWhat '%v'", opt.byWhat)
 		return
@@ -61,6 +72,12 @@ func (opt *Option) writeSendRewardTitleLine(outputFile io.Writer, exchange strin
 		"&&start=%v&&end=%v&&totalReward=%v&&exchange=%v&&rewardToken=%v",
 		opt.StartHeight, opt.EndHeight, opt.TotalValue,
 		strings.ToLower(exchange), strings.ToLower(opt.RewardToken))
+	if opt.Program != "" {
+		extraInfo += "&&program=" + opt.Program
+	}
+	if totalRewardUSD := opt.rewardToUSD(opt.TotalValue); totalRewardUSD != "" {
+		extraInfo += "&&totalRewardUSD=" + totalRewardUSD
+	}
 	// write title
 	if opt.DryRun {
 		err = WriteOutput(outputFile, "#account", "reward", keyShare, keyNumber, extraInfo)
@@ -81,6 +98,14 @@ func (opt *Option) sendRewards(idx int, exchange string, accountStats mongodb.Ac
 		return nil, err
 	}
 
+	depositsFile, err := opt.openVestingDeposits(exchange)
+	if err != nil {
+		return nil, err
+	}
+	if depositsFile != nil {
+		defer depositsFile.Close()
+	}
+
 	rewardsSended := big.NewInt(0)
 	totalDustReward := big.NewInt(0)
 	totalDustRewardCount := 0
@@ -91,7 +116,12 @@ func (opt *Option) sendRewards(idx int, exchange string, accountStats mongodb.Ac
 			continue
 		}
 		log.Info("sendRewards begin", "account", stat.Account.String(), "reward", stat.Reward, keyShare, stat.Share, keyNumber, stat.Number, "dryrun", opt.DryRun)
-		txHash, err := opt.SendRewardsTransaction(stat.Account, stat.Reward)
+		var txHash *common.Hash
+		if opt.isVesting() {
+			err = opt.grantVestingRewards(exchange, stat, depositsFile)
+		} else {
+			txHash, err = opt.SendRewardsTransaction(stat.Account, stat.Reward)
+		}
 		switch err {
 		case nil:
 		case errDustReward:
@@ -99,19 +129,28 @@ func (opt *Option) sendRewards(idx int, exchange string, accountStats mongodb.Ac
 			totalDustRewardCount++
 		default:
 			log.Error("[sendRewards] send tx failed", "account", stat.Account.String(), "reward", stat.Reward, "dryrun", opt.DryRun, "err", err)
+			failedPayoutCounter.Inc(opt.byWhat)
+			observeCycleRewards(opt.byWhat, exchange, accountStats.CalcTotalReward(), rewardsSended, totalDustReward, totalDustRewardCount)
+			notify.Notify(notify.EventTransferFailed, "send reward transaction failed",
+				"bywhat", opt.byWhat, "exchange", exchange, "start", opt.StartHeight, "end", opt.EndHeight,
+				"account", stat.Account.String(), "reward", stat.Reward, "err", err)
 			return rewardsSended, errSendTransactionFailed
 		}
 		rewardsSended.Add(rewardsSended, stat.Reward)
-		if opt.DryRun || txHash != nil {
+		if txHash != nil || (opt.isVesting() && err == nil) {
+			opt.markRewardsApplied(stat.Account)
+		}
+		if opt.DryRun || txHash != nil || (opt.isVesting() && err == nil) {
 			// write body
 			_ = opt.WriteSendRewardResult(outputFile, exchange, stat, txHash)
 			i++
 		}
-		if !opt.DryRun && opt.BatchCount > 0 && i%opt.BatchCount == 0 {
-			time.Sleep(time.Duration(opt.BatchInterval) * time.Millisecond)
-		}
+		opt.sleepAfterBatch(i)
 	}
 
+	observeCycleRewards(opt.byWhat, exchange, accountStats.CalcTotalReward(), rewardsSended, totalDustReward, totalDustRewardCount)
+	opt.notifyRewardsSent(exchange, rewardsSended, totalDustReward, totalDustRewardCount)
+
 	log.Info("[sendRewards] rewards sended",
 		"exchange", exchange,
 		"totalRewards", opt.TotalValue,
@@ -122,3 +161,14 @@ func (opt *Option) sendRewards(idx int, exchange string, accountStats mongodb.Ac
 	)
 	return rewardsSended, nil
 }
+
+func (opt *Option) notifyRewardsSent(exchange string, rewardsSended, totalDustReward *big.Int, totalDustRewardCount int) {
+	notify.Notify(notify.EventRewardsSent, "rewards sended",
+		"bywhat", opt.byWhat, "exchange", exchange, "start", opt.StartHeight, "end", opt.EndHeight,
+		"totalRewards", opt.TotalValue, "rewardsSended", rewardsSended, "dryrun", opt.DryRun)
+	if totalDustRewardCount > 0 {
+		notify.Notify(notify.EventDustSkipped, "dust rewards skipped",
+			"bywhat", opt.byWhat, "exchange", exchange, "start", opt.StartHeight, "end", opt.EndHeight,
+			"totalDustReward", totalDustReward, "totalDustRewardCount", totalDustRewardCount)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/anyswap/ANYToken-distribution/callapi"
 	"github.com/anyswap/ANYToken-distribution/log"
 	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/notify"
 	"github.com/anyswap/ANYToken-distribution/params"
 	"github.com/anyswap/ANYToken-distribution/syncer"
 	"github.com/anyswap/ANYToken-distribution/tools"
@@ -23,6 +24,7 @@ const (
 	byVolumeMethodID      = "volume"
 	byVolumeMethodAliasID = "trade"
 	customMethodID        = "custom"
+	referralMethodID      = "referral"
 )
 
 // Calc rewards type
@@ -68,6 +70,8 @@ func Start(apiCaller *callapi.APICaller) {
 
 	log.Info("[distribute] start job", "config", distCfg)
 
+	StartPrograms(distCfg)
+
 	runner, err := initDistributer(distCfg)
 	if err != nil {
 		log.Error("[distribute] start failed", "err", err)
@@ -75,9 +79,13 @@ func Start(apiCaller *callapi.APICaller) {
 	}
 
 	go runner.run()
+
+	StartVestingReleaser()
 }
 
 type distributeRunner struct {
+	program string // empty for the default distribution
+
 	liquidExchanges []string
 	liquidWeights   []uint64
 
@@ -87,6 +95,7 @@ type distributeRunner struct {
 
 	rewardToken string
 	start       uint64
+	end         uint64 // exclusive, 0 means no end
 	stable      uint64
 
 	byLiquidCycleLen     uint64
@@ -97,6 +106,9 @@ type distributeRunner struct {
 	totalVolumeCycles    uint64
 	totalVolumeRewards   *big.Int
 
+	// resolves rewards of every cycle by emission schedule, nil means fixed rewards
+	emissionCfg *params.DistributeConfig
+
 	quickSettleVolumeRewards bool
 	useTimeMeasurement       bool
 	isArchiveMode            bool
@@ -104,6 +116,10 @@ type distributeRunner struct {
 
 	byLiquidArgs *BuildTxArgs
 	byVolumeArgs *BuildTxArgs
+
+	// custom rewards of every cycle are read from input dir
+	customInputDir string
+	customArgs     *BuildTxArgs
 }
 
 func initDistributer(distCfg *params.DistributeConfig) (*distributeRunner, error) {
@@ -112,12 +128,12 @@ func initDistributer(distCfg *params.DistributeConfig) (*distributeRunner, error
 
 	runner.isArchiveMode = distCfg.ArchiveMode
 
-	runner.byLiquidArgs, err = getBuildTxArgs(distCfg)
+	runner.byLiquidArgs, err = getBuildTxArgs(distCfg, "")
 	if err != nil {
 		return nil, err
 	}
 
-	runner.byVolumeArgs, err = getBuildTxArgs(distCfg)
+	runner.byVolumeArgs, err = getBuildTxArgs(distCfg, "")
 	if err != nil {
 		return nil, err
 	}
@@ -152,14 +168,18 @@ func initDistributer(distCfg *params.DistributeConfig) (*distributeRunner, error
 	runner.quickSettleVolumeRewards = distCfg.QuickSettleVolumeRewards
 	runner.totalVolumeCycles = runner.byLiquidCycleLen / runner.byVolumeCycleLen
 	runner.totalVolumeRewards = new(big.Int).Mul(runner.byVolumeCycleRewards, new(big.Int).SetUint64(runner.totalVolumeCycles))
+	if distCfg.Emission != nil {
+		runner.emissionCfg = distCfg
+		runner.end = distCfg.Emission.End
+	}
 
 	runner.tradeWeightIsPercentage = distCfg.TradeWeightIsPercentage
 	for _, exchange := range params.GetConfig().Exchanges {
-		if exchange.LiquidWeight > 0 {
+		if exchange.LiquidWeight > 0 || exchange.LiquidRewardsPerCycle != "" {
 			runner.liquidExchanges = append(runner.liquidExchanges, exchange.Exchange)
 			runner.liquidWeights = append(runner.liquidWeights, exchange.LiquidWeight)
 		}
-		if exchange.TradeWeight > 0 {
+		if exchange.TradeWeight > 0 || exchange.TradeRewardsPerCycle != "" {
 			runner.tradeExchanges = append(runner.tradeExchanges, exchange.Exchange)
 			runner.tradeWeights = append(runner.tradeWeights, exchange.TradeWeight)
 		}
@@ -176,6 +196,7 @@ func initDistributer(distCfg *params.DistributeConfig) (*distributeRunner, error
 		"liquidExchanges", len(runner.liquidExchanges),
 		"tradeExchanges", len(runner.tradeExchanges),
 		"quickSettleVolumeRewards", runner.quickSettleVolumeRewards,
+		"hasEmissionSchedule", runner.emissionCfg != nil,
 		"useTimeMeasurement", runner.useTimeMeasurement,
 		"archiveMode", runner.isArchiveMode,
 	)
@@ -205,20 +226,51 @@ func (runner *distributeRunner) run() {
 	curCycleStart := calcCurCycleStart(runner.start, runner.stable, runner.byLiquidCycleLen, runner.useTimeMeasurement)
 
 	wg := new(sync.WaitGroup)
-	wg.Add(2)
+	wg.Add(3)
 	go runner.runVolumeDistribute(wg, curCycleStart)
 	go runner.runLiquidDistribute(wg, curCycleStart)
+	go runner.runCustomDistribute(wg, curCycleStart)
 	wg.Wait()
+	log.Info("distribute runner finished", "program", runner.program, "end", runner.end)
+}
+
+func (runner *distributeRunner) isEnded(cycleStart uint64) bool {
+	return runner.end != 0 && cycleStart >= runner.end
+}
+
+// rewards of liquid cycle starting at start
+func (runner *distributeRunner) getLiquidCycleRewards(start uint64) *big.Int {
+	if runner.emissionCfg == nil {
+		return runner.byLiquidCycleRewards
+	}
+	return runner.emissionCfg.GetByLiquidRewardsAt(start)
+}
+
+// rewards of volume step starting at start
+func (runner *distributeRunner) getVolumeStepRewards(start uint64) *big.Int {
+	if runner.emissionCfg == nil {
+		return runner.byVolumeCycleRewards
+	}
+	return runner.emissionCfg.GetByVolumeRewardsAt(start)
+}
+
+// rewards of whole volume cycle starting at start
+func (runner *distributeRunner) getVolumeCycleRewards(start uint64) *big.Int {
+	if runner.emissionCfg == nil {
+		return runner.totalVolumeRewards
+	}
+	stepRewards := runner.getVolumeStepRewards(start)
+	return new(big.Int).Mul(stepRewards, new(big.Int).SetUint64(runner.totalVolumeCycles))
 }
 
 func (runner *distributeRunner) runVolumeDistribute(wg *sync.WaitGroup, curCycleStart uint64) {
 	defer wg.Done()
-	if len(runner.tradeExchanges) == 0 || runner.byVolumeCycleRewards.Sign() <= 0 {
+	if len(runner.tradeExchanges) == 0 || (runner.emissionCfg == nil && runner.byVolumeCycleRewards.Sign() <= 0) {
 		log.Info("stop volume reward distribution as no exchange or rewards")
 		return
 	}
-	log.Info("start volume reward distribution", "start", curCycleStart)
-	for {
+	log.Info("start volume reward distribution", "program", runner.program, "start", curCycleStart)
+	for !runner.isEnded(curCycleStart) {
 		curCycleEnd := curCycleStart + runner.byLiquidCycleLen
 		_, _ = runner.settleVolumeRewards(curCycleStart, curCycleEnd)
 		// start next cycle
@@ -229,16 +281,16 @@ func (runner *distributeRunner) runVolumeDistribute(wg *sync.WaitGroup, curCycle
 
 func (runner *distributeRunner) runLiquidDistribute(wg *sync.WaitGroup, curCycleStart uint64) {
 	defer wg.Done()
-	if len(runner.liquidExchanges) == 0 || runner.byLiquidCycleRewards.Sign() <= 0 {
+	if len(runner.liquidExchanges) == 0 || (runner.emissionCfg == nil && runner.byLiquidCycleRewards.Sign() <= 0) {
 		log.Info("stop liquid reward distribution as no exchange or rewards")
 		return
 	}
-	log.Info("start liquid reward distribution", "start", curCycleStart)
-	for {
+	log.Info("start liquid reward distribution", "program", runner.program, "start", curCycleStart)
+	for !runner.isEnded(curCycleStart) {
 		curCycleEnd := curCycleStart + runner.byLiquidCycleLen
 		sampleHeight := CalcRandomSample(curCycleStart, curCycleEnd, runner.useTimeMeasurement)
 		waitCycleEnd("liquid", curCycleStart, sampleHeight, runner.stable, 60*time.Second, runner.useTimeMeasurement)
-		_ = runner.sendLiquidRewards(runner.byLiquidCycleRewards, curCycleStart, curCycleEnd, nil)
+		_ = runner.sendLiquidRewards(runner.getLiquidCycleRewards(curCycleStart), curCycleStart, curCycleEnd, nil)
 		waitCycleEnd("liquid", curCycleStart, curCycleEnd, runner.stable, 60*time.Second, runner.useTimeMeasurement)
 		// start next cycle
 		curCycleStart = curCycleEnd
@@ -249,7 +301,7 @@ func (runner *distributeRunner) runLiquidDistribute(wg *sync.WaitGroup, curCycle
 func (runner *distributeRunner) settleVolumeRewards(cycleStart, cycleEnd uint64) (uint64, error) {
 	if !runner.quickSettleVolumeRewards {
 		waitCycleEnd("trade", cycleStart, cycleEnd, runner.stable, 60*time.Second, runner.useTimeMeasurement)
-		return runner.sendVolumeRewards(runner.totalVolumeRewards, cycleStart, cycleEnd)
+		return runner.sendVolumeRewards(runner.getVolumeCycleRewards(cycleStart), cycleStart, cycleEnd)
 	}
 	latest := calcLatestBlockNumberOrTimestamp(runner.useTimeMeasurement)
 	var missVolumeCycles uint64
@@ -259,7 +311,7 @@ func (runner *distributeRunner) settleVolumeRewards(cycleStart, cycleEnd uint64)
 			continue
 		}
 		waitCycleEnd("trade", start, start+step, runner.stable, 20*time.Second, runner.useTimeMeasurement)
-		missing, err := runner.sendVolumeRewards(runner.byVolumeCycleRewards, start, start+step)
+		missing, err := runner.sendVolumeRewards(runner.getVolumeStepRewards(start), start, start+step)
 		if err != nil {
 			continue
 		}
@@ -280,7 +332,7 @@ func (runner *distributeRunner) sendVolumeRewards(rewards *big.Int, start, end u
 		EndHeight:          end,
 		StableHeight:       runner.stable,
 		StepCount:          runner.byVolumeCycleLen,
-		StepReward:         runner.byVolumeCycleRewards,
+		StepReward:         runner.getVolumeStepRewards(start),
 		Exchanges:          runner.tradeExchanges,
 		Weights:            runner.tradeWeights,
 		RewardToken:        runner.rewardToken,
@@ -288,14 +340,18 @@ func (runner *distributeRunner) sendVolumeRewards(rewards *big.Int, start, end u
 		UseTimeMeasurement: runner.useTimeMeasurement,
 		ArchiveMode:        runner.isArchiveMode,
 		WeightIsPercentage: runner.tradeWeightIsPercentage,
+		Program:            runner.program,
 	}
 	log.Info("start send volume reward", "option", opt.String())
+	notify.Notify(notify.EventCycleStart, "volume reward cycle start", "start", start, "end", end, "rewards", rewards)
 	err = ByVolume(opt)
 	if err != nil {
 		log.Error("send volume reward failed", start, "end", end, "rewards", rewards, "err", err)
+		notify.Notify(notify.EventCycleFinish, "volume reward cycle failed", "start", start, "end", end, "rewards", rewards, "err", err)
 		return 0, err
 	}
 	log.Info("send volume reward success", "start", start, "end", end, "rewards", rewards)
+	notify.Notify(notify.EventCycleFinish, "volume reward cycle success", "start", start, "end", end, "rewards", rewards, "novolumes", opt.noVolumes)
 	return opt.noVolumes, err
 }
 
@@ -317,14 +373,18 @@ func (runner *distributeRunner) sendLiquidRewards(rewards *big.Int, start, end u
 		UseTimeMeasurement: runner.useTimeMeasurement,
 		ArchiveMode:        runner.isArchiveMode,
 		InputFiles:         inputFiles,
+		Program:            runner.program,
 	}
 	log.Info("start send liquid reward", "option", opt.String())
+	notify.Notify(notify.EventCycleStart, "liquid reward cycle start", "start", start, "end", end, "rewards", rewards)
 	err := ByLiquidity(opt)
 	if err != nil {
 		log.Error("send liquid reward failed", "start", start, "end", end, "rewards", rewards, "err", err)
+		notify.Notify(notify.EventCycleFinish, "liquid reward cycle failed", "start", start, "end", end, "rewards", rewards, "err", err)
 		return err
 	}
 	log.Info("send liquid reward success", "start", start, "end", end, "rewards", rewards)
+	notify.Notify(notify.EventCycleFinish, "liquid reward cycle success", "start", start, "end", end, "rewards", rewards, "sampleHeight", opt.SampleHeight)
 	return nil
 }
 
@@ -365,13 +425,40 @@ func calcLatestBlockNumberOrTimestamp(useTimeMeasurement bool) uint64 {
 
 func calcCurCycleStart(start, stable, cycleLen uint64, useTimeMeasurement bool) uint64 {
 	latest := calcLatestBlockNumberOrTimestamp(useTimeMeasurement)
+	if latest < start+stable {
+		return start // not started yet
+	}
 	cycles := (latest - start - stable) / cycleLen
 	curCycleStart := start + cycles*cycleLen
 	log.Info("calcCurCycleStart", "start", curCycleStart, "latest", latest)
 	return curCycleStart
 }
 
-func getBuildTxArgs(distCfg *params.DistributeConfig) (*BuildTxArgs, error) {
+func getBuildTxArgs(distCfg *params.DistributeConfig, sender string) (*BuildTxArgs, error) {
+	args := newBuildTxArgs(distCfg, sender)
+	err := args.Check(true)
+	if err != nil {
+		log.Error("check build tx args failed", "err", err)
+		return nil, err
+	}
+	return args, nil
+}
+
+// build tx args with signer loaded for sending transactions,
+// keystore files are used if signer type is keystore.
+func getSendTxArgs(distCfg *params.DistributeConfig, sender, keystoreFile, passwordFile string) (*BuildTxArgs, error) {
+	args := newBuildTxArgs(distCfg, sender)
+	args.KeystoreFile = keystoreFile
+	args.PasswordFile = passwordFile
+	err := args.Check(false)
+	if err != nil {
+		log.Error("check send tx args failed", "sender", sender, "err", err)
+		return nil, err
+	}
+	return args, nil
+}
+
+func newBuildTxArgs(distCfg *params.DistributeConfig, sender string) *BuildTxArgs {
 	var (
 		gasLimitPtr *uint64
 		gasPrice    *big.Int
@@ -384,15 +471,14 @@ func getBuildTxArgs(distCfg *params.DistributeConfig) (*BuildTxArgs, error) {
 	}
 
 	args := &BuildTxArgs{
-		GasLimit: gasLimitPtr,
-		GasPrice: gasPrice,
-	}
-	err := args.Check(true)
-	if err != nil {
-		log.Error("check build tx args failed", "err", err)
-		return nil, err
-	}
-	return args, nil
+		Sender:      sender,
+		SignerType:  distCfg.SignerType,
+		SignerURL:   distCfg.SignerURL,
+		GasLimit:    gasLimitPtr,
+		GasPrice:    gasPrice,
+		MaxInflight: distCfg.MaxInflight,
+	}
+	return args
 }
 
 // CalcRewards calc rewards
@@ -448,14 +534,14 @@ func (runner *distributeRunner) calcLiquidRewards(startHeight, endHeight uint64,
 	if len(inputs) != 0 && len(inputs) != len(runner.liquidExchanges) {
 		return fmt.Errorf("count of input files %v and liquid exchanges %v are not equal", len(inputs), len(runner.liquidExchanges))
 	}
-	return runner.sendLiquidRewards(runner.byLiquidCycleRewards, startHeight, endHeight, inputs)
+	return runner.sendLiquidRewards(runner.getLiquidCycleRewards(startHeight), startHeight, endHeight, inputs)
 }
 
 func (runner *distributeRunner) calcVolumeRewards(startHeight, endHeight uint64) (err error) {
 	missingCycles := uint64(0)
 	if !runner.quickSettleVolumeRewards {
 		waitCycleEnd("tradeWhole", startHeight, endHeight, runner.stable, 60*time.Second, runner.useTimeMeasurement)
-		missingCycles, err = runner.sendVolumeRewards(runner.totalVolumeRewards, startHeight, endHeight)
+		missingCycles, err = runner.sendVolumeRewards(runner.getVolumeCycleRewards(startHeight), startHeight, endHeight)
 		if err != nil {
 			return err
 		}
@@ -464,7 +550,7 @@ func (runner *distributeRunner) calcVolumeRewards(startHeight, endHeight uint64)
 		var missing uint64
 		for start := startHeight; start < endHeight; start += step {
 			waitCycleEnd("trade", start, start+step, runner.stable, 20*time.Second, runner.useTimeMeasurement)
-			missing, err = runner.sendVolumeRewards(runner.byVolumeCycleRewards, start, start+step)
+			missing, err = runner.sendVolumeRewards(runner.getVolumeStepRewards(start), start, start+step)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,108 @@
+package distributer
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+// add excluded amount of account in cycle, accumulate if the same reason exists
+func addRewardExclusion(exclusions map[string]*mongodb.MgoRewardExclusion, exchange string, account common.Address, start, end uint64, reason string, amount *big.Int, swaps int, detail string) {
+	exchange = strings.ToLower(exchange)
+	accountStr := strings.ToLower(account.String())
+	key := mongodb.GetKeyOfRewardExclusion(exchange, accountStr, start, reason)
+	if exclusion, exist := exclusions[key]; exist {
+		excluded, _ := tools.GetBigIntFromString(exclusion.Volume)
+		exclusion.Volume = new(big.Int).Add(excluded, amount).String()
+		exclusion.Swaps += swaps
+		return
+	}
+	exclusions[key] = &mongodb.MgoRewardExclusion{
+		Key:      key,
+		Exchange: exchange,
+		Account:  accountStr,
+		Start:    start,
+		End:      end,
+		Reason:   reason,
+		Volume:   amount.String(),
+		Swaps:    swaps,
+		Detail:   detail,
+	}
+}
+
+func (opt *Option) addRewardExclusion(exchange string, account common.Address, reason string, amount *big.Int, detail string) {
+	if opt.exclusions == nil {
+		opt.exclusions = make(map[string]*mongodb.MgoRewardExclusion)
+	}
+	addRewardExclusion(opt.exclusions, exchange, account, opt.StartHeight, opt.EndHeight, reason, amount, 0, detail)
+}
+
+// get reward exclusions sorted by key
+func (opt *Option) getRewardExclusions() []*mongodb.MgoRewardExclusion {
+	result := make([]*mongodb.MgoRewardExclusion, 0, len(opt.exclusions))
+	for _, exclusion := range opt.exclusions {
+		result = append(result, exclusion)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+func (opt *Option) getAccountExclusions(exchange string, account common.Address) (result []*mongodb.MgoRewardExclusion) {
+	accountStr := strings.ToLower(account.String())
+	for _, exclusion := range opt.getRewardExclusions() {
+		if exclusion.Account == accountStr && strings.EqualFold(exclusion.Exchange, exchange) {
+			result = append(result, exclusion)
+		}
+	}
+	return result
+}
+
+// report exclusions and summary per cycle and reason, save to database if SaveDB
+func (opt *Option) saveRewardExclusions() {
+	type exclusionSummary struct {
+		accounts int
+		amount   *big.Int
+	}
+	var summaryKeys []string
+	summaries := make(map[string]*exclusionSummary)
+	for _, exclusion := range opt.getRewardExclusions() {
+		line := fmt.Sprintf("rewardExclusion %v %v start=%v end=%v reason=%v amount=%v swaps=%v %v",
+			exclusion.Exchange, exclusion.Account, exclusion.Start, exclusion.End,
+			exclusion.Reason, exclusion.Volume, exclusion.Swaps, exclusion.Detail)
+		log.Println(line)
+
+		summaryKey := fmt.Sprintf("exchange=%v start=%v end=%v reason=%v", exclusion.Exchange, exclusion.Start, exclusion.End, exclusion.Reason)
+		summary, exist := summaries[summaryKey]
+		if !exist {
+			summary = &exclusionSummary{amount: big.NewInt(0)}
+			summaries[summaryKey] = summary
+			summaryKeys = append(summaryKeys, summaryKey)
+		}
+		summary.accounts++
+		if amount, _ := tools.GetBigIntFromString(exclusion.Volume); amount != nil {
+			summary.amount.Add(summary.amount, amount)
+		}
+
+		if !opt.SaveDB {
+			continue
+		}
+		exclusion.Timestamp = uint64(time.Now().Unix())
+		_ = mongodb.TryDoTimes("AddRewardExclusion "+exclusion.Key, func() error {
+			return mongodb.AddRewardExclusion(exclusion)
+		})
+	}
+	sort.Strings(summaryKeys)
+	for _, summaryKey := range summaryKeys {
+		summary := summaries[summaryKey]
+		log.Printf("rewardExclusionSummary %v accounts=%v amount=%v\n", summaryKey, summary.accounts, summary.amount)
+	}
+}
@@ -0,0 +1,370 @@
+package distributer
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+// ExplainArgs explain args
+type ExplainArgs struct {
+	Account      common.Address
+	ByWhat       string
+	Start        uint64
+	End          uint64
+	TotalReward  *big.Int // use configed cycle rewards if nil
+	SampleHeight uint64   // use recorded sample height if zero
+	Program      string   // reward program id, empty for the default distribution
+}
+
+// RewardExplanation reward derivation of an account in a cycle
+type RewardExplanation struct {
+	Account      string
+	ByWhat       string
+	Program      string `json:",omitempty"`
+	Start        uint64
+	End          uint64
+	RewardToken  string
+	TotalReward  *big.Int
+	SampleHeight uint64 `json:",omitempty"`
+	NoVolumes    uint64 `json:",omitempty"`
+	Reward       *big.Int
+	Exchanges    []*ExchangeExplanation
+
+	// dust rewards of accumulate policy are pending and paid once passing dust threshold
+	UnspentPolicy  string
+	PendingBalance *big.Int `json:",omitempty"` // current pending rewards of account
+}
+
+// ExchangeExplanation reward derivation of an account in an exchange
+type ExchangeExplanation struct {
+	Exchange        string
+	Pairs           string
+	Weight          uint64
+	ExchangeShare   *ExchangeShare `json:",omitempty"`
+	ExchangeReward  *big.Int
+	Steps           []*VolumeStepExplanation      `json:",omitempty"`
+	Exclusions      []*mongodb.MgoRewardExclusion `json:",omitempty"`
+	Liquidity       *LiquidityExplanation         `json:",omitempty"`
+	Share           *big.Int
+	TotalShare      *big.Int
+	Reward          *big.Int
+	IsDust          bool
+	DustThreshold   *big.Int
+	RecordedReward  string `json:",omitempty"`
+	RecordedPending string `json:",omitempty"` // paid pending rewards included in recorded reward
+	RewardTx        string `json:",omitempty"`
+}
+
+// VolumeStepExplanation volume share of an account in a step cycle
+type VolumeStepExplanation struct {
+	Start      uint64
+	End        uint64
+	Swaps      []*mongodb.MgoVolumeHistory
+	Volume     *big.Int
+	Stake      *StakeExplanation `json:",omitempty"`
+	Share      *big.Int          // volume plus stake bonus
+	TotalShare *big.Int
+	StepReward *big.Int
+	Reward     *big.Int
+}
+
+// StakeExplanation stake bonus of liquidity or volume share
+type StakeExplanation struct {
+	OriginShare      *big.Int
+	BlockNumber      *big.Int
+	StakeAmount      *big.Int
+	StakeWholeAmount uint64
+	Curve            string
+	AddPercent       uint64
+}
+
+// LiquidityExplanation liquidity share of an account at sample height
+type LiquidityExplanation struct {
+	SampleHeight        uint64
+	LiquidityBalance    *big.Int
+	TotalSupply         *big.Int
+	ExchangeCoinBalance *big.Int
+	CoinBalance         *big.Int
+	Stake               *StakeExplanation `json:",omitempty"` // share is coin balance plus stake bonus
+}
+
+const explainCacheTTL = 10 * time.Minute
+
+// recomputed rewards of cycle, shared by explanations of accounts
+type explainCycle struct {
+	opt          *Option
+	accountStats []mongodb.AccountStatSlice
+	timestamp    time.Time
+}
+
+var (
+	// explanations are serialized, and recomputed cycles are cached by cycle key
+	explainCycles = make(map[string]*explainCycle)
+	explainLock   sync.Mutex
+)
+
+// ExplainReward explain reward derivation of account in cycle
+func ExplainReward(args *ExplainArgs) (*RewardExplanation, error) {
+	explainLock.Lock()
+	defer explainLock.Unlock()
+
+	cycle, err := getExplainCycle(args)
+	if err != nil {
+		return nil, err
+	}
+	opt, accountStats := cycle.opt, cycle.accountStats
+
+	expl := &RewardExplanation{
+		Account:      strings.ToLower(args.Account.String()),
+		ByWhat:       opt.byWhat,
+		Program:      opt.Program,
+		Start:        opt.StartHeight,
+		End:          opt.EndHeight,
+		RewardToken:  opt.RewardToken,
+		TotalReward:  opt.TotalValue,
+		SampleHeight: opt.SampleHeight,
+		NoVolumes:    opt.noVolumes,
+		Reward:       big.NewInt(0),
+	}
+	for i, exchange := range opt.Exchanges {
+		var stats mongodb.AccountStatSlice
+		if accountStats != nil {
+			stats = accountStats[i]
+		}
+		exExpl, err := opt.explainExchange(i, exchange, args.Account, stats)
+		if err != nil {
+			return nil, err
+		}
+		if exExpl == nil {
+			continue
+		}
+		if !exExpl.IsDust {
+			expl.Reward.Add(expl.Reward, exExpl.Reward)
+		}
+		expl.Exchanges = append(expl.Exchanges, exExpl)
+	}
+	expl.UnspentPolicy = opt.unspentPolicy()
+	if expl.UnspentPolicy == params.UnspentPolicyAccumulate {
+		pending, err := mongodb.FindPendingReward(opt.Program, opt.RewardToken, expl.Account)
+		if err == nil {
+			expl.PendingBalance, _ = tools.GetBigIntFromString(pending.Amount)
+		}
+	}
+	log.Info("[explain] explain reward success", "account", expl.Account, "bywhat", expl.ByWhat, "program", expl.Program, "start", expl.Start, "end", expl.End, "reward", expl.Reward)
+	return expl, nil
+}
+
+// get recomputed rewards of cycle from cache, or recompute and cache it
+func getExplainCycle(args *ExplainArgs) (*explainCycle, error) {
+	byWhat := GetStandardByWhat(args.ByWhat)
+	var prog *params.ProgramConfig
+	if args.Program != "" {
+		if prog = params.GetProgramConfig(args.Program); prog == nil {
+			return nil, fmt.Errorf("unknown program '%v'", args.Program)
+		}
+	}
+	sampleHeight := args.SampleHeight
+	if sampleHeight == 0 && byWhat == byLiquidMethodID {
+		info, err := mongodb.FindDistributeInfo(args.Program, byWhat, args.Start)
+		if err != nil {
+			return nil, fmt.Errorf("no recorded sample height of cycle start %v, %v", args.Start, err)
+		}
+		sampleHeight = info.SampleHeight
+	}
+
+	now := time.Now()
+	for key, cycle := range explainCycles {
+		if now.Sub(cycle.timestamp) > explainCacheTTL {
+			delete(explainCycles, key)
+		}
+	}
+	key := fmt.Sprintf("%v/%v:%v:%v:%v:%v", args.Program, byWhat, args.Start, args.End, args.TotalReward, sampleHeight)
+	if cycle, exist := explainCycles[key]; exist {
+		return cycle, nil
+	}
+
+	opt, err := newCycleOption(byWhat, args.Start, args.End, args.TotalReward, sampleHeight, nil)
+	if err != nil {
+		return nil, err
+	}
+	if prog != nil {
+		if err = opt.applyProgram(prog, args.TotalReward); err != nil {
+			return nil, err
+		}
+	}
+	if err = opt.CheckBasic(); err != nil {
+		return nil, err
+	}
+
+	var accountStats []mongodb.AccountStatSlice
+	switch opt.byWhat {
+	case byLiquidMethodID:
+		accountStats, err = opt.calcLiquidityRewards()
+	case byVolumeMethodID:
+		accountStats, err = opt.calcVolumeRewards()
+	}
+	if err != nil {
+		return nil, err
+	}
+	cycle := &explainCycle{
+		opt:          opt,
+		accountStats: accountStats,
+		timestamp:    now,
+	}
+	explainCycles[key] = cycle
+	return cycle, nil
+}
+
+func (opt *Option) explainExchange(index int, exchange string, account common.Address, stats mongodb.AccountStatSlice) (*ExchangeExplanation, error) {
+	exExpl := &ExchangeExplanation{
+		Exchange:       strings.ToLower(exchange),
+		Pairs:          params.GetExchangePairs(exchange),
+		Weight:         opt.Weights[index],
+		ExchangeReward: stats.CalcTotalReward(),
+		TotalShare:     stats.CalcTotalShare(),
+		Share:          big.NewInt(0),
+		Reward:         big.NewInt(0),
+		DustThreshold:  params.GetDustRewardThreshold(),
+	}
+	for _, stat := range stats {
+		if stat.Account == account && stat.Reward != nil {
+			exExpl.Share = stat.Share
+			exExpl.Reward = stat.Reward
+			break
+		}
+	}
+	exExpl.IsDust = exExpl.Reward.Cmp(exExpl.DustThreshold) < 0
+
+	accountStr := strings.ToLower(account.String())
+	key := mongodb.GetKeyOfProgramRewardResult(opt.Program, exchange, accountStr, opt.StartHeight)
+	switch opt.byWhat {
+	case byLiquidMethodID:
+		exExpl.Liquidity = opt.explainLiquidity(exchange, account)
+		exExpl.Liquidity.Stake = opt.getStakeBoost(exchange, account, opt.StartHeight)
+		if exExpl.Liquidity.LiquidityBalance.Sign() == 0 && exExpl.Reward.Sign() == 0 {
+			return nil, nil
+		}
+		exExpl.Exclusions = opt.getAccountExclusions(exchange, account)
+		if res, err := mongodb.FindLiquidRewardResult(key); err == nil {
+			exExpl.RecordedReward, exExpl.RecordedPending, exExpl.RewardTx = res.Reward, res.Pending, res.RewardTx
+		}
+	case byVolumeMethodID:
+		if index < len(opt.exchangeShares) {
+			exExpl.ExchangeShare = opt.exchangeShares[index]
+		}
+		steps, err := opt.explainVolumeSteps(exchange, account)
+		if err != nil {
+			return nil, err
+		}
+		exclusions := opt.getAccountExclusions(exchange, account)
+		if len(steps) == 0 && len(exclusions) == 0 && exExpl.Reward.Sign() == 0 {
+			return nil, nil
+		}
+		exExpl.Steps = steps
+		exExpl.Exclusions = exclusions
+		if res, err := mongodb.FindVolumeRewardResult(key); err == nil {
+			exExpl.RecordedReward, exExpl.RecordedPending, exExpl.RewardTx = res.Reward, res.Pending, res.RewardTx
+		}
+	}
+	return exExpl, nil
+}
+
+// same steps as 'GetAccountsAndRewardsFromDB'
+func (opt *Option) explainVolumeSteps(exchange string, account common.Address) (steps []*VolumeStepExplanation, err error) {
+	step := opt.StepCount
+	stepRewards := opt.TotalValue
+	if step == 0 {
+		step = opt.EndHeight - opt.StartHeight
+	} else {
+		stepRewards = new(big.Int).Div(opt.TotalValue, new(big.Int).SetUint64((opt.EndHeight-opt.StartHeight)/step))
+	}
+	for start := opt.StartHeight; start < opt.EndHeight; start += step {
+		end := start + step
+		swaps, err := mongodb.FindAccountVolumeHistories(exchange, account.String(), start, end, opt.UseTimeMeasurement)
+		if err != nil {
+			return nil, err
+		}
+		if len(swaps) == 0 {
+			continue
+		}
+		stepExpl := &VolumeStepExplanation{
+			Start:      start,
+			End:        end,
+			Swaps:      swaps,
+			Volume:     big.NewInt(0),
+			StepReward: stepRewards,
+			Share:      big.NewInt(0),
+			Reward:     big.NewInt(0),
+		}
+		for _, swap := range swaps {
+			volume, _ := tools.GetBigIntFromString(swap.CoinAmount)
+			if volume != nil && volume.Sign() > 0 {
+				stepExpl.Volume.Add(stepExpl.Volume, volume)
+			}
+		}
+		cycleStats, err := opt.getExplainStepStats(stepRewards, exchange, start, end)
+		if err != nil {
+			return nil, err
+		}
+		stepExpl.Stake = opt.getStakeBoost(exchange, account, start)
+		stepExpl.TotalShare = cycleStats.CalcTotalShare()
+		for _, stat := range cycleStats {
+			if stat.Account == account {
+				stepExpl.Share = stat.Share
+				stepExpl.Reward = stat.Reward
+				break
+			}
+		}
+		steps = append(steps, stepExpl)
+	}
+	return steps, nil
+}
+
+// step rewards are the same for all accounts of cached cycle
+func (opt *Option) getExplainStepStats(stepRewards *big.Int, exchange string, start, end uint64) (mongodb.AccountStatSlice, error) {
+	key := fmt.Sprintf("%v:%v:%v", strings.ToLower(exchange), start, end)
+	if stats, exist := opt.stepStats[key]; exist {
+		return stats, nil
+	}
+	stats, err := opt.getSingleCycleRewardsFromDB(stepRewards, exchange, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if opt.stepStats == nil {
+		opt.stepStats = make(map[string]mongodb.AccountStatSlice)
+	}
+	opt.stepStats[key] = stats
+	return stats, nil
+}
+
+// same as 'getLiquidityBalancesOfExchange' in archive mode
+func (opt *Option) explainLiquidity(exchange string, account common.Address) *LiquidityExplanation {
+	exchangeAddr := common.HexToAddress(exchange)
+	blockNumber := new(big.Int).SetUint64(opt.SampleHeight)
+	liqExpl := &LiquidityExplanation{
+		SampleHeight:        opt.SampleHeight,
+		TotalSupply:         capi.LoopGetExchangeLiquidity(exchangeAddr, blockNumber),
+		ExchangeCoinBalance: capi.LoopGetCoinBalance(exchangeAddr, blockNumber),
+	}
+	liquidStr, err := mongodb.FindLiquidityBalance(exchange, strings.ToLower(account.String()), opt.SampleHeight)
+	if err == nil {
+		liqExpl.LiquidityBalance, _ = tools.GetBigIntFromString(liquidStr)
+	}
+	if liqExpl.LiquidityBalance == nil {
+		liqExpl.LiquidityBalance = capi.LoopGetLiquidityBalance(exchangeAddr, account, blockNumber)
+	}
+	liqExpl.CoinBalance = new(big.Int).Mul(liqExpl.LiquidityBalance, liqExpl.ExchangeCoinBalance)
+	if liqExpl.TotalSupply.Sign() > 0 {
+		liqExpl.CoinBalance.Div(liqExpl.CoinBalance, liqExpl.TotalSupply)
+	}
+	return liqExpl
+}
@@ -0,0 +1,175 @@
+package distributer
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/notify"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+const spendingLimitDayPeriod = 24 * 60 * 60
+
+var (
+	// rewards sent in this process but not recorded in database
+	unrecordedSpendings     []*spendingRecord
+	unrecordedSpendingsLock sync.Mutex
+)
+
+type spendingRecord struct {
+	program   string
+	token     string
+	amount    *big.Int
+	timestamp uint64
+}
+
+// sent rewards are recorded in reward results except custom rewards and vesting releases
+func (opt *Option) isSpendingInRewardResults() bool {
+	switch opt.byWhat {
+	case byLiquidMethodID, byVolumeMethodID:
+		return !opt.isVesting()
+	case referralMethodID:
+		return true
+	}
+	return false
+}
+
+func (opt *Option) recordSpending(account common.Address, amount *big.Int, txHash common.Hash) {
+	if opt.SaveDB && opt.isSpendingInRewardResults() {
+		return // recorded in reward results of database
+	}
+	if mongodb.HasSession() {
+		ms := &mongodb.MgoSpending{
+			Key:         txHash.Hex(),
+			ByWhat:      opt.byWhat,
+			Program:     opt.Program,
+			Account:     strings.ToLower(account.String()),
+			RewardToken: strings.ToLower(opt.RewardToken),
+			Reward:      amount.String(),
+			Timestamp:   uint64(time.Now().Unix()),
+		}
+		err := mongodb.TryDoTimes("AddSpending "+ms.Key, func() error {
+			return mongodb.AddSpending(ms)
+		})
+		if err == nil {
+			return
+		}
+	}
+	unrecordedSpendingsLock.Lock()
+	defer unrecordedSpendingsLock.Unlock()
+	unrecordedSpendings = append(unrecordedSpendings, &spendingRecord{
+		program:   opt.Program,
+		token:     strings.ToLower(opt.RewardToken),
+		amount:    new(big.Int).Set(amount),
+		timestamp: uint64(time.Now().Unix()),
+	})
+}
+
+func sumUnrecordedSpendingsSince(program, rewardToken string, since uint64) *big.Int {
+	unrecordedSpendingsLock.Lock()
+	defer unrecordedSpendingsLock.Unlock()
+	total := big.NewInt(0)
+	rewardToken = strings.ToLower(rewardToken)
+	for _, record := range unrecordedSpendings {
+		if record.program == program && record.token == rewardToken && record.timestamp >= since {
+			total.Add(total, record.amount)
+		}
+	}
+	return total
+}
+
+func (opt *Option) getSpentInLastDay() (*big.Int, error) {
+	since := uint64(time.Now().Unix()) - spendingLimitDayPeriod
+	spent := sumUnrecordedSpendingsSince(opt.Program, opt.RewardToken, since)
+	if mongodb.HasSession() {
+		recorded, err := mongodb.SumRewardsSentSince(opt.Program, opt.RewardToken, since)
+		if err != nil {
+			return nil, fmt.Errorf("sum rewards sent in last day failed, %v", err)
+		}
+		spent.Add(spent, recorded)
+	}
+	return spent, nil
+}
+
+// checkSpendingLimits check configed spending limits before sending the first transaction.
+// all rewards in accountStats are treated as one cycle, accounts are aggregated across exchanges.
+func (opt *Option) checkSpendingLimits(accountStats ...mongodb.AccountStatSlice) error {
+	limits := params.GetConfig().Limits
+	if limits == nil {
+		return nil
+	}
+
+	cycleTotal := big.NewInt(0)
+	recipients := make(map[common.Address]*big.Int)
+	for _, stats := range accountStats {
+		for _, stat := range stats {
+			if stat.Reward == nil || stat.Reward.Sign() <= 0 {
+				continue
+			}
+			cycleTotal.Add(cycleTotal, stat.Reward)
+			if reward, exist := recipients[stat.Account]; exist {
+				reward.Add(reward, stat.Reward)
+			} else {
+				recipients[stat.Account] = new(big.Int).Set(stat.Reward)
+			}
+		}
+	}
+
+	var breaches []string
+	if maxPerCycle := limits.GetMaxPerCycle(); maxPerCycle != nil && cycleTotal.Cmp(maxPerCycle) > 0 {
+		breaches = append(breaches, fmt.Sprintf("cycle total %v exceeds max per cycle %v", cycleTotal, maxPerCycle))
+	}
+	if maxPerDay := limits.GetMaxPerDay(); maxPerDay != nil {
+		spent, err := opt.getSpentInLastDay()
+		if err != nil {
+			return err
+		}
+		dayTotal := new(big.Int).Add(spent, cycleTotal)
+		if dayTotal.Cmp(maxPerDay) > 0 {
+			breaches = append(breaches, fmt.Sprintf("sent %v in last 24 hours plus cycle total %v exceeds max per day %v", spent, cycleTotal, maxPerDay))
+		}
+	}
+	maxPerRecipient := limits.GetMaxPerRecipient()
+	var maxShareReward *big.Int
+	if limits.MaxRecipientShare > 0 {
+		maxShareReward = new(big.Int).Mul(cycleTotal, new(big.Int).SetUint64(limits.MaxRecipientShare))
+		maxShareReward.Div(maxShareReward, big.NewInt(100))
+	}
+	for _, stats := range accountStats {
+		for _, stat := range stats {
+			reward, exist := recipients[stat.Account]
+			if !exist {
+				continue // reported already or empty reward
+			}
+			delete(recipients, stat.Account)
+			if maxPerRecipient != nil && reward.Cmp(maxPerRecipient) > 0 {
+				breaches = append(breaches, fmt.Sprintf("account %v reward %v exceeds max per recipient %v", stat.Account.String(), reward, maxPerRecipient))
+			}
+			if maxShareReward != nil && reward.Cmp(maxShareReward) > 0 {
+				breaches = append(breaches, fmt.Sprintf("account %v reward %v exceeds max recipient share %v%% of cycle total %v", stat.Account.String(), reward, limits.MaxRecipientShare, cycleTotal))
+			}
+		}
+	}
+
+	if len(breaches) == 0 {
+		log.Info("check spending limits success", "bywhat", opt.byWhat, "rewardToken", opt.RewardToken, "cycleTotal", cycleTotal)
+		return nil
+	}
+
+	report := strings.Join(breaches, "\n\t")
+	log.Warn("spending limits breached", "bywhat", opt.byWhat, "start", opt.StartHeight, "end", opt.EndHeight, "breaches", len(breaches), "override", opt.OverrideLimits, "dryrun", opt.DryRun)
+	log.Printf("spending limits report:\n\t%v\n", report)
+	if opt.DryRun || opt.OverrideLimits {
+		return nil
+	}
+	notify.Notify(notify.EventSpendingLimit, "spending limits breached, sending is aborted",
+		"bywhat", opt.byWhat, "start", opt.StartHeight, "end", opt.EndHeight,
+		"rewardToken", opt.RewardToken, "cycleTotal", cycleTotal, "report", report)
+	return fmt.Errorf("spending limits breached (use '--overrideLimits' to force sending):\n\t%v", report)
+}
@@ -0,0 +1,27 @@
+package distributer
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/anyswap/ANYToken-distribution/metrics"
+)
+
+var (
+	rewardsComputedGauge = metrics.NewGauge("distribute_cycle_rewards_computed", "Rewards computed in the last cycle (unit wei).", "bywhat", "exchange")
+	rewardsSentGauge     = metrics.NewGauge("distribute_cycle_rewards_sent", "Rewards sent in the last cycle (unit wei).", "bywhat", "exchange")
+	dustRewardsGauge     = metrics.NewGauge("distribute_cycle_dust_rewards", "Dust rewards skipped in the last cycle (unit wei).", "bywhat", "exchange")
+	dustCountGauge       = metrics.NewGauge("distribute_cycle_dust_reward_count", "Number of dust rewards skipped in the last cycle.", "bywhat", "exchange")
+	failedPayoutCounter  = metrics.NewCounter("distribute_failed_payouts_total", "Number of failed reward payouts.", "bywhat")
+
+	senderTokenBalanceGauge = metrics.NewGauge("distribute_sender_reward_token_balance", "Reward token balance of sender (unit wei).", "sender", "token")
+	senderCoinBalanceGauge  = metrics.NewGauge("distribute_sender_coin_balance", "Coin balance of sender (unit wei).", "sender")
+)
+
+func observeCycleRewards(byWhat, exchange string, computed, sent, dust *big.Int, dustCount int) {
+	exchange = strings.ToLower(exchange)
+	rewardsComputedGauge.SetBigInt(computed, byWhat, exchange)
+	rewardsSentGauge.SetBigInt(sent, byWhat, exchange)
+	dustRewardsGauge.SetBigInt(dust, byWhat, exchange)
+	dustCountGauge.Set(float64(dustCount), byWhat, exchange)
+}
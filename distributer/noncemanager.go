@@ -0,0 +1,398 @@
+package distributer
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	ethereum "github.com/fsn-dev/fsn-go-sdk/efsn"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common/hexutil"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/core/types"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/rlp"
+)
+
+const (
+	inflightCheckInterval = 3 * time.Second
+	fillGapGasLimit       = 21000
+)
+
+var (
+	nonceManagers     = make(map[common.Address]*NonceManager)
+	nonceManagersLock sync.Mutex
+)
+
+// NonceManager manage nonce of one sender, it's safe across goroutines.
+// sent but unconfirmed transactions are kept in memory and database,
+// so they can be resent if they are dropped by the node.
+type NonceManager struct {
+	lock sync.Mutex
+	cond *sync.Cond // signaled when in-flight or reserved nonces change
+
+	sender      common.Address
+	signer      Signer
+	chainID     *big.Int
+	gasPrice    *big.Int
+	maxInflight uint64
+
+	nextNonce uint64
+	inflight  map[uint64]*types.Transaction
+	reserved  map[uint64]struct{} // acquired but not sent yet
+	needCheck bool                // nonce state is unknown after failed sending
+	checking  bool                // in-flight transactions are being checked without lock
+}
+
+// snapshot of nonce manager to check in-flight transactions without lock
+type nonceSnapshot struct {
+	sender    common.Address
+	signer    Signer
+	chainID   *big.Int
+	gasPrice  *big.Int
+	nextNonce uint64
+	inflight  map[uint64]*types.Transaction
+	reserved  map[uint64]struct{}
+}
+
+// result of checking in-flight transactions, applied to nonce manager with lock
+type nonceCheckResult struct {
+	confirmedNonce uint64
+	pendingNonce   uint64 // zero if not queried
+	filled         []*types.Transaction
+}
+
+// getNonceManager get or create the nonce manager of sender
+func (args *BuildTxArgs) getNonceManager() (*NonceManager, error) {
+	nonceManagersLock.Lock()
+	defer nonceManagersLock.Unlock()
+
+	nm, exist := nonceManagers[args.fromAddr]
+	if !exist {
+		nm = &NonceManager{
+			sender:   args.fromAddr,
+			inflight: make(map[uint64]*types.Transaction),
+			reserved: make(map[uint64]struct{}),
+		}
+		nm.cond = sync.NewCond(&nm.lock)
+		if err := nm.init(); err != nil {
+			return nil, err
+		}
+		nonceManagers[args.fromAddr] = nm
+	}
+
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+	nm.signer = args.signer
+	nm.chainID = args.chainID
+	nm.gasPrice = args.GasPrice
+	if args.MaxInflight > nm.maxInflight {
+		nm.maxInflight = args.MaxInflight
+	}
+	if args.Nonce != nil && *args.Nonce > nm.nextNonce {
+		nm.nextNonce = *args.Nonce
+	}
+	return nm, nil
+}
+
+// load unconfirmed transactions from database
+func (nm *NonceManager) init() error {
+	pendingNonce, err := capi.GetAccountNonce(nm.sender)
+	if err != nil {
+		return fmt.Errorf("get nonce failed, %v", err)
+	}
+	nm.nextNonce = pendingNonce
+
+	if !mongodb.HasSession() {
+		return nil
+	}
+	confirmedNonce, err := capi.GetConfirmedNonce(nm.sender)
+	if err != nil {
+		return fmt.Errorf("get confirmed nonce failed, %v", err)
+	}
+	_ = mongodb.DeleteNonceRecordsBelow(nm.sender.String(), confirmedNonce)
+	records, err := mongodb.FindNonceRecords(nm.sender.String())
+	if err != nil {
+		return fmt.Errorf("find nonce records failed, %v", err)
+	}
+	for _, record := range records {
+		tx := new(types.Transaction)
+		if err = rlp.DecodeBytes(common.FromHex(record.RawTx), tx); err != nil {
+			log.Warn("[nonce] decode nonce record failed", "sender", record.Sender, "nonce", record.Nonce, "err", err)
+			continue
+		}
+		nm.inflight[record.Nonce] = tx
+		if record.Nonce >= nm.nextNonce {
+			nm.nextNonce = record.Nonce + 1
+		}
+	}
+	log.Info("[nonce] init nonce manager success", "sender", nm.sender.String(), "nextNonce", nm.nextNonce, "inflight", len(nm.inflight))
+	return nil
+}
+
+// AcquireNonce acquire next nonce, block if there are too many in-flight transactions.
+// caller must call Commit, Release or ReleaseSent with the acquired nonce.
+// in-flight transactions are checked without lock, so slow gateway does not block other calls.
+func (nm *NonceManager) AcquireNonce() uint64 {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+
+	for nm.maxInflight != 0 || nm.needCheck {
+		if nm.checking {
+			nm.wait(inflightCheckInterval)
+			continue
+		}
+		nm.checking = true
+		needCheck := nm.needCheck
+		nm.needCheck = false
+		snapshot := nm.snapshot()
+
+		nm.lock.Unlock()
+		result, err := snapshot.checkInflight()
+		nm.lock.Lock()
+
+		nm.checking = false
+		nm.applyCheckResult(result, err == nil)
+		nm.cond.Broadcast()
+		if err != nil {
+			log.Warn("[nonce] check in-flight transactions failed", "sender", nm.sender.String(), "err", err)
+			nm.needCheck = nm.needCheck || needCheck
+		} else if nm.maxInflight == 0 || uint64(len(nm.inflight)) < nm.maxInflight {
+			break
+		}
+		nm.wait(inflightCheckInterval)
+	}
+
+	nonce := nm.nextNonce
+	nm.nextNonce++
+	nm.reserved[nonce] = struct{}{}
+	return nonce
+}
+
+// snapshot must be called with lock held
+func (nm *NonceManager) snapshot() *nonceSnapshot {
+	snapshot := &nonceSnapshot{
+		sender:    nm.sender,
+		signer:    nm.signer,
+		chainID:   nm.chainID,
+		gasPrice:  nm.gasPrice,
+		nextNonce: nm.nextNonce,
+		inflight:  make(map[uint64]*types.Transaction, len(nm.inflight)),
+		reserved:  make(map[uint64]struct{}, len(nm.reserved)),
+	}
+	for nonce, tx := range nm.inflight {
+		snapshot.inflight[nonce] = tx
+	}
+	for nonce := range nm.reserved {
+		snapshot.reserved[nonce] = struct{}{}
+	}
+	return snapshot
+}
+
+// applyCheckResult must be called with lock held,
+// gap filling transactions are recorded even if the check is not complete.
+func (nm *NonceManager) applyCheckResult(result *nonceCheckResult, complete bool) {
+	for nonce := range nm.inflight {
+		if nonce < result.confirmedNonce {
+			delete(nm.inflight, nonce)
+		}
+	}
+	for _, tx := range result.filled {
+		nm.addInflight(tx)
+	}
+	if complete && result.pendingNonce > nm.nextNonce {
+		// sender is used by others
+		log.Warn("[nonce] pending nonce is ahead of managed nonce", "sender", nm.sender.String(), "pendingNonce", result.pendingNonce, "nextNonce", nm.nextNonce)
+		nm.nextNonce = result.pendingNonce
+	}
+}
+
+// wait releases the lock until signaled or timeout, must be called with lock held
+func (nm *NonceManager) wait(timeout time.Duration) {
+	timer := time.AfterFunc(timeout, nm.cond.Broadcast)
+	nm.cond.Wait()
+	timer.Stop()
+}
+
+// Commit record sent transaction as in-flight
+func (nm *NonceManager) Commit(signedTx *types.Transaction) {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+	defer nm.cond.Broadcast()
+
+	delete(nm.reserved, signedTx.Nonce())
+	nm.addInflight(signedTx)
+}
+
+func (nm *NonceManager) addInflight(signedTx *types.Transaction) {
+	nonce := signedTx.Nonce()
+	nm.inflight[nonce] = signedTx
+	if !mongodb.HasSession() {
+		return
+	}
+	rawTx, err := rlp.EncodeToBytes(signedTx)
+	if err != nil {
+		return
+	}
+	mr := &mongodb.MgoNonceRecord{
+		Key:       mongodb.GetKeyOfNonceRecord(nm.sender.String(), nonce),
+		Sender:    strings.ToLower(nm.sender.String()),
+		Nonce:     nonce,
+		TxHash:    signedTx.Hash().Hex(),
+		RawTx:     hexutil.Encode(rawTx),
+		Timestamp: uint64(time.Now().Unix()),
+	}
+	_ = mongodb.TryDoTimes("AddNonceRecord "+mr.Key, func() error {
+		return mongodb.AddNonceRecord(mr)
+	})
+}
+
+// Release give back nonce which is not sent,
+// if it is not the last acquired one, the gap is filled in next check.
+func (nm *NonceManager) Release(nonce uint64) {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+	defer nm.cond.Broadcast()
+
+	nm.release(nonce)
+}
+
+func (nm *NonceManager) release(nonce uint64) {
+	delete(nm.reserved, nonce)
+	if nonce+1 == nm.nextNonce {
+		nm.nextNonce--
+	} else {
+		nm.needCheck = true
+	}
+}
+
+// ReleaseSent give back nonce of transaction whose sending returns error.
+// the node may have accepted it anyway (eg. timeout or 'already known'),
+// so the nonce is reused only if the node has neither the transaction nor the nonce.
+// returns true if the transaction is accepted and recorded as in-flight.
+func (nm *NonceManager) ReleaseSent(signedTx *types.Transaction) bool {
+	nonce := signedTx.Nonce()
+	accepted, pendingNonce, err := nm.querySent(signedTx)
+
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+	defer nm.cond.Broadcast()
+
+	switch {
+	case accepted:
+		delete(nm.reserved, nonce)
+		nm.addInflight(signedTx)
+		return true
+	case err != nil:
+		// do not reuse the nonce, next acquiring checks and fills the gap
+		log.Warn("[nonce] query failed sending failed", "sender", nm.sender.String(), "nonce", nonce, "err", err)
+		delete(nm.reserved, nonce)
+		nm.needCheck = true
+	case pendingNonce > nonce:
+		// nonce is used by other transaction of the sender
+		log.Warn("[nonce] nonce of failed sending is used", "sender", nm.sender.String(), "nonce", nonce, "pendingNonce", pendingNonce)
+		delete(nm.reserved, nonce)
+	default:
+		nm.release(nonce)
+	}
+	return false
+}
+
+// query whether failed sending transaction is accepted by the node, without lock.
+// if its nonce is used, check by hash and receipt again as it may be mined meanwhile.
+func (nm *NonceManager) querySent(signedTx *types.Transaction) (accepted bool, pendingNonce uint64, err error) {
+	txHash := signedTx.Hash()
+	known, _, err := capi.GetTransactionByHash(txHash)
+	if known != nil {
+		return true, 0, nil
+	}
+	if err != nil && err != ethereum.NotFound {
+		return false, 0, err
+	}
+	pendingNonce, err = capi.GetAccountNonce(nm.sender)
+	if err != nil || pendingNonce <= signedTx.Nonce() {
+		return false, pendingNonce, err
+	}
+	if receipt, _ := capi.GetTransactionReceipt(txHash); receipt != nil {
+		return true, pendingNonce, nil
+	}
+	known, _, err = capi.GetTransactionByHash(txHash)
+	if known != nil {
+		return true, pendingNonce, nil
+	}
+	if err != nil && err != ethereum.NotFound {
+		return false, pendingNonce, err
+	}
+	return false, pendingNonce, nil
+}
+
+// remove confirmed transactions, and resend dropped ones or fill gaps.
+// it is called without lock, the result is applied by applyCheckResult.
+func (snapshot *nonceSnapshot) checkInflight() (result *nonceCheckResult, err error) {
+	result = &nonceCheckResult{}
+	confirmedNonce, err := capi.GetConfirmedNonce(snapshot.sender)
+	if err != nil {
+		return result, err
+	}
+	result.confirmedNonce = confirmedNonce
+	for nonce := range snapshot.inflight {
+		if nonce < confirmedNonce && mongodb.HasSession() {
+			_ = mongodb.DeleteNonceRecordsBelow(snapshot.sender.String(), confirmedNonce)
+			break
+		}
+	}
+
+	pendingNonce, err := capi.GetAccountNonce(snapshot.sender)
+	if err != nil {
+		return result, err
+	}
+	result.pendingNonce = pendingNonce
+	for nonce := pendingNonce; nonce < snapshot.nextNonce; nonce++ {
+		filled, err := snapshot.fillGap(nonce)
+		if err != nil {
+			return result, err
+		}
+		if filled != nil {
+			result.filled = append(result.filled, filled)
+		}
+	}
+	return result, nil
+}
+
+// resend in-flight transaction of nonce, or send a self transfer to fill the nonce gap,
+// returns the self transfer if it is sent.
+func (snapshot *nonceSnapshot) fillGap(nonce uint64) (*types.Transaction, error) {
+	if _, exist := snapshot.reserved[nonce]; exist {
+		return nil, nil
+	}
+	tx, exist := snapshot.inflight[nonce]
+	if exist {
+		if known, _, _ := capi.GetTransactionByHash(tx.Hash()); known != nil {
+			return nil, nil
+		}
+		log.Warn("[nonce] resend dropped transaction", "sender", snapshot.sender.String(), "nonce", nonce, "txHash", tx.Hash().String())
+		return nil, capi.SendTransaction(tx)
+	}
+	if snapshot.signer == nil || snapshot.chainID == nil {
+		return nil, fmt.Errorf("can not fill nonce gap %v without signer", nonce)
+	}
+	gasPrice := snapshot.gasPrice
+	if gasPrice == nil {
+		var err error
+		if gasPrice, err = capi.SuggestGasPrice(); err != nil {
+			return nil, err
+		}
+	}
+	rawTx := types.NewTransaction(nonce, snapshot.sender, big.NewInt(0), fillGapGasLimit, gasPrice, nil)
+	signedTx, err := snapshot.signer.SignTx(rawTx, snapshot.chainID)
+	if err != nil {
+		return nil, err
+	}
+	log.Warn("[nonce] fill nonce gap", "sender", snapshot.sender.String(), "nonce", nonce, "txHash", signedTx.Hash().String())
+	if err = capi.SendTransaction(signedTx); err != nil {
+		return nil, err
+	}
+	return signedTx, nil
+}
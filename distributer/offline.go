@@ -0,0 +1,440 @@
+package distributer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/notify"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common/hexutil"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/core/types"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/rlp"
+)
+
+// RewardTxsFile offline reward transactions file.
+// it is produced by 'buildtxs', filled with signatures by 'signtxs',
+// and consumed by 'broadcast'.
+type RewardTxsFile struct {
+	ChainID      *big.Int
+	Sender       string
+	RewardType   string
+	RewardToken  string
+	Exchange     string `json:",omitempty"`
+	Program      string `json:",omitempty"`
+	StartHeight  uint64
+	EndHeight    uint64
+	Transactions []*RewardTx
+}
+
+// RewardTx offline reward transaction
+type RewardTx struct {
+	Account  string
+	Reward   *big.Int
+	Share    *big.Int `json:",omitempty"`
+	Number   uint64   `json:",omitempty"`
+	Nonce    uint64
+	To       string
+	Value    *big.Int
+	GasLimit uint64
+	GasPrice *big.Int
+	Data     hexutil.Bytes `json:",omitempty"`
+	SignedTx hexutil.Bytes `json:",omitempty"`
+	TxHash   string        `json:",omitempty"`
+}
+
+func newRewardTx(stat *mongodb.AccountStat, rawTx *types.Transaction) *RewardTx {
+	return &RewardTx{
+		Account:  strings.ToLower(stat.Account.String()),
+		Reward:   stat.Reward,
+		Share:    stat.Share,
+		Number:   stat.Number,
+		Nonce:    rawTx.Nonce(),
+		To:       strings.ToLower(rawTx.To().String()),
+		Value:    rawTx.Value(),
+		GasLimit: rawTx.Gas(),
+		GasPrice: rawTx.GasPrice(),
+		Data:     rawTx.Data(),
+	}
+}
+
+func (rtx *RewardTx) toTransaction() *types.Transaction {
+	return types.NewTransaction(rtx.Nonce, common.HexToAddress(rtx.To), rtx.Value, rtx.GasLimit, rtx.GasPrice, rtx.Data)
+}
+
+func (rtx *RewardTx) accountStat() *mongodb.AccountStat {
+	return &mongodb.AccountStat{
+		Account: common.HexToAddress(rtx.Account),
+		Reward:  rtx.Reward,
+		Share:   rtx.Share,
+		Number:  rtx.Number,
+	}
+}
+
+// verify the transaction really pays 'Reward' to 'Account'
+func (rtx *RewardTx) verify(rewardToken string) error {
+	if !common.IsHexAddress(rtx.Account) || !common.IsHexAddress(rtx.To) {
+		return fmt.Errorf("wrong address, account '%v' to '%v'", rtx.Account, rtx.To)
+	}
+	if rtx.Reward == nil || rtx.Reward.Sign() <= 0 || rtx.Value == nil || rtx.GasPrice == nil {
+		return fmt.Errorf("missing reward, value or gas price of account %v", rtx.Account)
+	}
+	args := &BuildTxArgs{
+		GasLimit: &rtx.GasLimit,
+		GasPrice: rtx.GasPrice,
+	}
+	expect := args.buildRewardsTransaction(rtx.Nonce, common.HexToAddress(rtx.Account), rtx.Reward, common.HexToAddress(rewardToken))
+	if *expect.To() != common.HexToAddress(rtx.To) ||
+		expect.Value().Cmp(rtx.Value) != 0 ||
+		!strings.EqualFold(hexutil.Encode(expect.Data()), hexutil.Encode(rtx.Data)) {
+		return fmt.Errorf("transaction of nonce %v does not match reward %v of account %v", rtx.Nonce, rtx.Reward, rtx.Account)
+	}
+	return nil
+}
+
+// get signed tx and verify it is signed by sender and matches the unsigned fields
+func (rtx *RewardTx) getSignedTx(signer types.Signer, sender common.Address) (*types.Transaction, error) {
+	if len(rtx.SignedTx) == 0 {
+		return nil, fmt.Errorf("transaction of nonce %v is not signed", rtx.Nonce)
+	}
+	signedTx := new(types.Transaction)
+	if err := rlp.DecodeBytes(rtx.SignedTx, signedTx); err != nil {
+		return nil, fmt.Errorf("decode signed transaction of nonce %v failed, %v", rtx.Nonce, err)
+	}
+	if signedTx.Hash().Hex() != rtx.TxHash {
+		return nil, fmt.Errorf("tx hash mismatch, %v != %v", signedTx.Hash().Hex(), rtx.TxHash)
+	}
+	if signer.Hash(signedTx) != signer.Hash(rtx.toTransaction()) {
+		return nil, fmt.Errorf("signed transaction of nonce %v does not match unsigned fields", rtx.Nonce)
+	}
+	signedBy, err := types.Sender(signer, signedTx)
+	if err != nil {
+		return nil, fmt.Errorf("recover sender of nonce %v failed, %v", rtx.Nonce, err)
+	}
+	if signedBy != sender {
+		return nil, fmt.Errorf("transaction of nonce %v is signed by %v, not sender %v", rtx.Nonce, signedBy.String(), sender.String())
+	}
+	return signedTx, nil
+}
+
+// TotalRewards calc total rewards of all transactions
+func (txsFile *RewardTxsFile) TotalRewards() *big.Int {
+	total := big.NewInt(0)
+	for _, rtx := range txsFile.Transactions {
+		if rtx.Reward != nil {
+			total.Add(total, rtx.Reward)
+		}
+	}
+	return total
+}
+
+func (txsFile *RewardTxsFile) check() error {
+	if txsFile.ChainID == nil || txsFile.ChainID.Sign() <= 0 {
+		return fmt.Errorf("wrong chain ID %v", txsFile.ChainID)
+	}
+	if !common.IsHexAddress(txsFile.Sender) {
+		return fmt.Errorf("wrong sender '%v'", txsFile.Sender)
+	}
+	if txsFile.RewardToken != "" && !common.IsHexAddress(txsFile.RewardToken) {
+		return fmt.Errorf("wrong reward token '%v'", txsFile.RewardToken)
+	}
+	if GetStandardByWhat(txsFile.RewardType) == "" {
+		return fmt.Errorf("unknown reward type '%v'", txsFile.RewardType)
+	}
+	for i, rtx := range txsFile.Transactions {
+		if err := rtx.verify(txsFile.RewardToken); err != nil {
+			return err
+		}
+		if i > 0 && rtx.Nonce != txsFile.Transactions[i-1].Nonce+1 {
+			return fmt.Errorf("nonce is not continuous, %v after %v", rtx.Nonce, txsFile.Transactions[i-1].Nonce)
+		}
+	}
+	return nil
+}
+
+// ReadRewardTxsFile read and check reward transactions file
+func ReadRewardTxsFile(fileName string) (*RewardTxsFile, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("read %v failed. %v", fileName, err)
+	}
+	txsFile := &RewardTxsFile{}
+	if err = json.Unmarshal(data, txsFile); err != nil {
+		return nil, fmt.Errorf("unmarshal %v failed. %v", fileName, err)
+	}
+	if err = txsFile.check(); err != nil {
+		return nil, fmt.Errorf("check %v failed. %v", fileName, err)
+	}
+	return txsFile, nil
+}
+
+// WriteRewardTxsFile write reward transactions file
+func WriteRewardTxsFile(fileName string, txsFile *RewardTxsFile) error {
+	data, err := json.MarshalIndent(txsFile, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(fileName, data, 0644)
+	if err != nil {
+		return err
+	}
+	log.Info("write reward transactions file success", "file", fileName, "txs", len(txsFile.Transactions), "totalRewards", txsFile.TotalRewards())
+	return nil
+}
+
+func (opt *Option) checkInputAndOutputFiles() error {
+	if len(opt.Exchanges) != 0 {
+		if len(opt.InputFiles) != len(opt.Exchanges) {
+			return fmt.Errorf("count of exchanges and input files is not equal")
+		}
+		if len(opt.OutputFiles) != len(opt.Exchanges) {
+			return fmt.Errorf("count of exchanges and output files is not equal")
+		}
+	} else if len(opt.InputFiles) != len(opt.OutputFiles) {
+		return fmt.Errorf("count of input and output files is not equal")
+	}
+	return nil
+}
+
+// BuildRewardTxsFromFile build unsigned reward transactions from input reward files
+func (opt *Option) BuildRewardTxsFromFile() (err error) {
+	if err = opt.checkInputAndOutputFiles(); err != nil {
+		return err
+	}
+	var exchange string
+	for i, inputFile := range opt.InputFiles {
+		if len(opt.Exchanges) != 0 {
+			exchange = opt.Exchanges[i]
+		}
+		outputFile := opt.OutputFiles[i]
+		err = opt.buildRewardTxsFromFile(exchange, inputFile, outputFile)
+		if err != nil {
+			log.Error("build reward txs from file failed", "exchange", exchange, "index", i, "input", inputFile, "output", outputFile, "err", err)
+			return err
+		}
+	}
+	log.Info("build reward txs from file success", "input file count", len(opt.InputFiles), "next nonce", *opt.BuildTxArgs.Nonce)
+	return nil
+}
+
+func (opt *Option) buildRewardTxsFromFile(exchange, ifile, ofile string) error {
+	accountStats, err := opt.checkSendRewardsFromFile(ifile)
+	if err != nil {
+		return err
+	}
+
+	log.Info("call build reward txs from file", "input", ifile, "output", ofile)
+
+	args := opt.BuildTxArgs
+	txsFile := &RewardTxsFile{
+		ChainID:     args.GetChainID(),
+		Sender:      strings.ToLower(args.GetSender().String()),
+		RewardType:  opt.byWhat,
+		RewardToken: strings.ToLower(opt.RewardToken),
+		Exchange:    strings.ToLower(exchange),
+		Program:     opt.Program,
+		StartHeight: opt.StartHeight,
+		EndHeight:   opt.EndHeight,
+	}
+
+	rewardToken := common.HexToAddress(opt.RewardToken)
+	dustRewardThreshold := params.GetDustRewardThreshold()
+	totalDustReward := big.NewInt(0)
+	totalDustRewardCount := 0
+	for _, stat := range accountStats {
+		account := stat.Account
+		reward := stat.Reward
+		if reward == nil || reward.Sign() <= 0 {
+			log.Info("ignore zero reward line", "account", account)
+			continue
+		}
+		if reward.Cmp(dustRewardThreshold) < 0 {
+			log.Info("buildtxs ignore dust reward", "account", account.String(), "reward", reward, "dustRewardThreshold", dustRewardThreshold)
+			totalDustReward.Add(totalDustReward, reward)
+			totalDustRewardCount++
+			continue
+		}
+		rawTx := args.buildRewardsTransaction(*args.Nonce, account, reward, rewardToken)
+		txsFile.Transactions = append(txsFile.Transactions, newRewardTx(stat, rawTx))
+		*args.Nonce++
+	}
+
+	log.Info("[buildRewardTxsFromFile] reward txs built",
+		"exchange", exchange,
+		"totalRewards", opt.TotalValue,
+		"txs", len(txsFile.Transactions),
+		"totalDustReward", totalDustReward,
+		"totalDustRewardCount", totalDustRewardCount,
+	)
+	return WriteRewardTxsFile(ofile, txsFile)
+}
+
+// SignRewardTxsFile sign reward transactions file offline (no gateway access)
+func (args *BuildTxArgs) SignRewardTxsFile(ifile, ofile string) error {
+	txsFile, err := ReadRewardTxsFile(ifile)
+	if err != nil {
+		return err
+	}
+	if args.signer == nil {
+		if args.Sender == "" {
+			args.Sender = txsFile.Sender
+		}
+		if err = args.loadSigner(); err != nil {
+			return err
+		}
+	}
+	if !strings.EqualFold(txsFile.Sender, args.fromAddr.String()) {
+		return fmt.Errorf("sender mismatch. sender from file = '%v', sender from signer = '%v'", txsFile.Sender, args.fromAddr.String())
+	}
+	args.chainID = txsFile.ChainID
+
+	for _, rtx := range txsFile.Transactions {
+		signedTx, err := args.signTransaction(rtx.toTransaction())
+		if err != nil {
+			return err
+		}
+		rtx.SignedTx, err = rlp.EncodeToBytes(signedTx)
+		if err != nil {
+			return fmt.Errorf("encode signed tx failed, %v", err)
+		}
+		rtx.TxHash = signedTx.Hash().Hex()
+		log.Info("sign reward tx success", "account", rtx.Account, "reward", rtx.Reward, "nonce", rtx.Nonce, "txHash", rtx.TxHash)
+	}
+	return WriteRewardTxsFile(ofile, txsFile)
+}
+
+// BroadcastRewardTxsFromFile broadcast signed reward transactions from input files.
+// It's safe to run repeatedly, transactions known by the node are not sent again.
+func (opt *Option) BroadcastRewardTxsFromFile() (err error) {
+	if len(opt.InputFiles) != len(opt.OutputFiles) {
+		return fmt.Errorf("count of input and output files is not equal")
+	}
+
+	totalRewardsSended := big.NewInt(0)
+
+	var rewardsSended *big.Int
+	for i, inputFile := range opt.InputFiles {
+		outputFile := opt.OutputFiles[i]
+		rewardsSended, err = opt.broadcastRewardTxsFromFile(inputFile, outputFile)
+		if rewardsSended != nil {
+			totalRewardsSended.Add(totalRewardsSended, rewardsSended)
+		}
+		if err != nil {
+			log.Error("broadcast reward txs from file failed", "index", i, "input", inputFile, "output", outputFile, "err", err)
+			break
+		}
+	}
+	log.Infof("total sended reward is %v, input file count is %v\n", totalRewardsSended, len(opt.InputFiles))
+	return err
+}
+
+func (opt *Option) broadcastRewardTxsFromFile(ifile, ofile string) (rewardsSended *big.Int, err error) {
+	txsFile, err := ReadRewardTxsFile(ifile)
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := capi.GetChainID()
+	if err != nil {
+		return nil, fmt.Errorf("get chain ID failed, %v", err)
+	}
+	if chainID.Cmp(txsFile.ChainID) != 0 {
+		return nil, fmt.Errorf("chain ID mismatch. chain ID from file = %v, chain ID from gateway = %v", txsFile.ChainID, chainID)
+	}
+
+	if err = opt.SetByWhat(txsFile.RewardType); err != nil {
+		return nil, err
+	}
+	opt.RewardToken = txsFile.RewardToken
+	opt.Program = txsFile.Program
+	opt.StartHeight = txsFile.StartHeight
+	opt.EndHeight = txsFile.EndHeight
+	opt.TotalValue = txsFile.TotalRewards()
+	exchange := txsFile.Exchange
+	sender := common.HexToAddress(txsFile.Sender)
+	signer := types.NewEIP155Signer(txsFile.ChainID)
+
+	outputFile, err := os.OpenFile(ofile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer outputFile.Close()
+
+	log.Info("call broadcast reward txs from file", "input", ifile, "output", ofile, "txs", len(txsFile.Transactions))
+
+	rewardsSended = big.NewInt(0)
+	zeroDust := big.NewInt(0)
+	sendCount := uint64(0)
+	for _, rtx := range txsFile.Transactions {
+		signedTx, err := rtx.getSignedTx(signer, sender)
+		if err == nil {
+			var isSent bool
+			isSent, err = broadcastRewardTx(sender, signedTx)
+			if isSent {
+				sendCount++
+			}
+		}
+		if err != nil {
+			log.Error("[broadcastRewardTxsFromFile] broadcast tx failed", "account", rtx.Account, "reward", rtx.Reward, "nonce", rtx.Nonce, "err", err)
+			failedPayoutCounter.Inc(opt.byWhat)
+			observeCycleRewards(opt.byWhat, exchange, opt.TotalValue, rewardsSended, zeroDust, 0)
+			notify.Notify(notify.EventTransferFailed, "broadcast reward transaction failed",
+				"bywhat", opt.byWhat, "exchange", exchange, "input", ifile,
+				"account", rtx.Account, "reward", rtx.Reward, "nonce", rtx.Nonce, "err", err)
+			return rewardsSended, errSendTransactionFailed
+		}
+		rewardsSended.Add(rewardsSended, rtx.Reward)
+		txHash := signedTx.Hash()
+		_ = opt.WriteSendRewardResult(outputFile, exchange, rtx.accountStat(), &txHash)
+		if opt.BatchCount > 0 && sendCount > 0 && sendCount%opt.BatchCount == 0 {
+			time.Sleep(time.Duration(opt.BatchInterval) * time.Millisecond)
+		}
+	}
+
+	observeCycleRewards(opt.byWhat, exchange, opt.TotalValue, rewardsSended, zeroDust, 0)
+	opt.notifyRewardsSent(exchange, rewardsSended, zeroDust, 0)
+
+	log.Info("[broadcastRewardTxsFromFile] rewards sended",
+		"exchange", exchange,
+		"totalRewards", opt.TotalValue,
+		"rewardsSended", rewardsSended,
+		"newlySendedTxs", sendCount,
+	)
+	return rewardsSended, nil
+}
+
+// broadcast signed tx if it's not known by the node yet,
+// error if it's already mined but failed, which should not be recorded as paid.
+func broadcastRewardTx(sender common.Address, signedTx *types.Transaction) (isSent bool, err error) {
+	txHash := signedTx.Hash()
+	if receipt, _ := capi.GetTransactionReceipt(txHash); receipt != nil {
+		log.Info("reward tx is already mined", "txHash", txHash.String(), "status", receipt.Status)
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return false, fmt.Errorf("tx %v is mined but failed with status %v", txHash.String(), receipt.Status)
+		}
+		return false, nil
+	}
+	if tx, _, _ := capi.GetTransactionByHash(txHash); tx != nil {
+		log.Info("reward tx is already in pool", "txHash", txHash.String())
+		return false, nil
+	}
+	nonce, err := capi.GetAccountNonce(sender)
+	if err != nil {
+		return false, fmt.Errorf("get nonce failed, %v", err)
+	}
+	if nonce > signedTx.Nonce() {
+		return false, fmt.Errorf("nonce %v is already used by other transaction, pending nonce is %v", signedTx.Nonce(), nonce)
+	}
+	err = capi.SendTransaction(signedTx)
+	if err != nil {
+		return false, fmt.Errorf("send tx failed, %v", err)
+	}
+	log.Info("broadcast reward tx success", "nonce", signedTx.Nonce(), "txHash", txHash.String())
+	return true, nil
+}
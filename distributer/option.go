@@ -11,6 +11,7 @@ import (
 
 	"github.com/anyswap/ANYToken-distribution/log"
 	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/notify"
 	"github.com/anyswap/ANYToken-distribution/params"
 	"github.com/anyswap/ANYToken-distribution/tools"
 	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
@@ -35,6 +36,8 @@ type Option struct {
 	DryRun       bool
 	ArchiveMode  bool
 
+	OverrideLimits bool
+
 	WeightIsPercentage bool
 
 	BatchCount    uint64
@@ -49,12 +52,35 @@ type Option struct {
 	ScalingNumerator   *big.Int
 	ScalingDenominator *big.Int
 
+	// reward program id, empty for the default distribution
+	Program string `json:",omitempty"`
+
 	byWhat    string
 	noVolumes uint64
 
+	recordedPolicy string // unspent policy recorded in verified rewards file
+
+	carriedIn      *big.Int // carried in unspent rewards of previous cycles in TotalValue
+	referralDeduct *big.Int // referral rewards deducted from referees in the same budget
+
 	hasNoMissingVolumes  bool
 	noVolumeStartHeights []uint64
 
+	exchangeShares []*ExchangeShare
+	exclusions     map[string]*mongodb.MgoRewardExclusion
+
+	unspentRewards   []*mongodb.MgoUnspentReward
+	carriedInRewards []*mongodb.MgoUnspentReward
+	pendingRewards   map[string]*mongodb.MgoPendingReward
+	pendingIn        map[string]*big.Int // paid pending rewards of exchange and account
+
+	stakers     map[common.Address]uint64 // discovered stakers and first seen block
+	stakeBoosts map[string]*StakeExplanation
+	stepStats   map[string]mongodb.AccountStatSlice // explained volume step rewards
+
+	rewardTokenUSD       *big.Rat // USD price of one Wei of reward token
+	rewardTokenUSDLoaded bool
+
 	outputFiles []*os.File
 }
 
@@ -103,16 +129,23 @@ func (opt *Option) GetChainID() *big.Int {
 }
 
 func (opt *Option) String() string {
-	return fmt.Sprintf("%v TotalValue %v StartHeight %v EndHeight %v StableHeight %v"+
+	return fmt.Sprintf("%v%v TotalValue %v StartHeight %v EndHeight %v StableHeight %v"+
 		" StepCount %v StepReward %v SampleHeight %v Exchanges %v Weights %v"+
 		" RewardToken %v DryRun %v SaveDB %v ArchiveMode %v Sender %v ChainID %v",
-		opt.byWhat, opt.TotalValue, opt.StartHeight, opt.EndHeight, opt.StableHeight,
+		opt.programPrefix(), opt.byWhat, opt.TotalValue, opt.StartHeight, opt.EndHeight, opt.StableHeight,
 		opt.StepCount, opt.StepReward, opt.SampleHeight, opt.Exchanges, opt.Weights,
 		opt.RewardToken, opt.DryRun, opt.SaveDB, opt.ArchiveMode,
 		opt.GetSender().String(), opt.GetChainID(),
 	)
 }
 
+func (opt *Option) programPrefix() string {
+	if opt.Program == "" {
+		return ""
+	}
+	return opt.Program + "-"
+}
+
 func (opt *Option) deinit() {
 	opt.noVolumeStartHeights = nil
 	for _, file := range opt.outputFiles {
@@ -123,6 +156,17 @@ func (opt *Option) deinit() {
 	}
 }
 
+// sleep after every batch of sends, unless in-flight transactions are limited by nonce manager
+func (opt *Option) sleepAfterBatch(count uint64) {
+	if opt.DryRun || opt.BatchCount == 0 || count%opt.BatchCount != 0 {
+		return
+	}
+	if opt.BuildTxArgs != nil && opt.BuildTxArgs.MaxInflight > 0 {
+		return
+	}
+	time.Sleep(time.Duration(opt.BatchInterval) * time.Millisecond)
+}
+
 // CheckBasic check option basic
 func (opt *Option) CheckBasic() error {
 	if opt.byWhat == customMethodID {
@@ -165,7 +209,7 @@ func (opt *Option) checkWeights() error {
 		return fmt.Errorf("[check option] count of exchanges %v != count of weights %v", len(opt.Exchanges), len(opt.Weights))
 	}
 	for i, weight := range opt.Weights {
-		if weight == 0 {
+		if weight == 0 && !opt.hasFixedExchangeRewards(opt.Exchanges[i]) {
 			return fmt.Errorf("[check option] has zero weight exchange %v", opt.Exchanges[i])
 		}
 	}
@@ -219,6 +263,10 @@ func (opt *Option) checkAndInit() (err error) {
 	if err != nil {
 		return err
 	}
+	err = opt.checkVesting()
+	if err != nil {
+		return err
+	}
 	log.Info("checkAndInit success")
 	return nil
 }
@@ -246,7 +294,7 @@ func (opt *Option) getDefaultOutputFile(i int) string {
 	exchange := opt.Exchanges[i]
 	pairs := params.GetExchangePairs(exchange)
 	timestamp := time.Now().Unix()
-	return fmt.Sprintf("%s-%sReward-%d-%d-%d.csv", pairs, opt.byWhat, opt.StartHeight, opt.EndHeight, timestamp)
+	return fmt.Sprintf("%s%s-%sReward-%d-%d-%d.csv", opt.programPrefix(), pairs, opt.byWhat, opt.StartHeight, opt.EndHeight, timestamp)
 }
 
 func (opt *Option) openOutputFile(i int) (err error) {
@@ -331,22 +379,23 @@ func (opt *Option) WriteSendRewardResult(ofile io.Writer, exchange string, stat
 	}
 	if txHash != nil {
 		hashStr = txHash.Hex()
+	} else if opt.isVesting() && !opt.DryRun {
+		hashStr = VestingRewardTx
 	}
 
 	// write output beofre write database
-	if txHash == nil {
-		if share != nil {
-			err = WriteOutput(ofile, accoutStr, rewardStr, shareStr, numStr)
-		} else {
-			err = WriteOutput(ofile, accoutStr, rewardStr)
-		}
-	} else {
-		if share != nil {
-			err = WriteOutput(ofile, accoutStr, rewardStr, shareStr, numStr, hashStr)
-		} else {
-			err = WriteOutput(ofile, accoutStr, rewardStr, hashStr)
-		}
+	parts := []string{accoutStr, rewardStr}
+	if share != nil {
+		parts = append(parts, shareStr, numStr)
 	}
+	if hashStr != "" {
+		parts = append(parts, hashStr)
+	}
+	parts = append(parts, opt.getOutputAnnotations(exchange, account)...)
+	if rewardUSD := opt.rewardToUSD(reward); rewardUSD != "" {
+		parts = append(parts, annotationUSD+rewardUSD)
+	}
+	err = WriteOutput(ofile, parts...)
 
 	opt.WriteRewardResultToDB(exchange, accoutStr, rewardStr, shareStr, number, hashStr)
 
@@ -358,12 +407,35 @@ func (opt *Option) WriteRewardResultToDB(exchange, accoutStr, rewardStr, shareSt
 	if !opt.SaveDB || opt.byWhat == customMethodID {
 		return
 	}
+	switch mr := opt.newRewardResult(exchange, accoutStr, rewardStr, shareStr, number, hashStr).(type) {
+	case *mongodb.MgoVolumeRewardResult:
+		_ = mongodb.TryDoTimes("AddVolumeRewardResult "+mr.Key, func() error {
+			return mongodb.AddVolumeRewardResult(mr)
+		})
+	case *mongodb.MgoLiquidRewardResult:
+		_ = mongodb.TryDoTimes("AddLiquidRewardResult "+mr.Key, func() error {
+			return mongodb.AddLiquidRewardResult(mr)
+		})
+	case *mongodb.MgoReferralRewardResult:
+		_ = mongodb.TryDoTimes("AddReferralRewardResult "+mr.Key, func() error {
+			return mongodb.AddReferralRewardResult(mr)
+		})
+	default:
+		log.Warn("unknown byWhat in option", "byWhat", opt.byWhat)
+	}
+}
+
+// new reward result of database tagged by program, nil if byWhat has no result table
+func (opt *Option) newRewardResult(exchange, accoutStr, rewardStr, shareStr string, number uint64, hashStr string) interface{} {
 	exchange = strings.ToLower(exchange)
 	pairs := params.GetExchangePairs(exchange)
+	reward, _ := tools.GetBigIntFromString(rewardStr)
+	rewardUSD := opt.rewardToUSD(reward)
 	switch opt.byWhat {
 	case byVolumeMethodID:
-		mr := &mongodb.MgoVolumeRewardResult{
-			Key:         mongodb.GetKeyOfRewardResult(exchange, accoutStr, opt.StartHeight),
+		return &mongodb.MgoVolumeRewardResult{
+			Key:         mongodb.GetKeyOfProgramRewardResult(opt.Program, exchange, accoutStr, opt.StartHeight),
+			Program:     opt.Program,
 			Exchange:    exchange,
 			Pairs:       pairs,
 			Start:       opt.StartHeight,
@@ -374,14 +446,18 @@ func (opt *Option) WriteRewardResultToDB(exchange, accoutStr, rewardStr, shareSt
 			Volume:      shareStr,
 			TxCount:     number,
 			RewardTx:    hashStr,
+			Pending:     opt.getPendingIn(exchange, accoutStr),
+			RewardUSD:   rewardUSD,
 			Timestamp:   uint64(time.Now().Unix()),
 		}
-		_ = mongodb.TryDoTimes("AddVolumeRewardResult "+mr.Key, func() error {
-			return mongodb.AddVolumeRewardResult(mr)
-		})
 	case byLiquidMethodID:
-		mr := &mongodb.MgoLiquidRewardResult{
-			Key:         mongodb.GetKeyOfRewardResult(exchange, accoutStr, opt.StartHeight),
+		var balanceStr string
+		if share, _ := tools.GetBigIntFromString(shareStr); share != nil {
+			balanceStr = opt.getUnboostedShare(exchange, common.HexToAddress(accoutStr), share).String()
+		}
+		return &mongodb.MgoLiquidRewardResult{
+			Key:         mongodb.GetKeyOfProgramRewardResult(opt.Program, exchange, accoutStr, opt.StartHeight),
+			Program:     opt.Program,
 			Exchange:    exchange,
 			Pairs:       pairs,
 			Start:       opt.StartHeight,
@@ -390,17 +466,32 @@ func (opt *Option) WriteRewardResultToDB(exchange, accoutStr, rewardStr, shareSt
 			Account:     accoutStr,
 			Reward:      rewardStr,
 			Liquidity:   shareStr,
+			Balance:     balanceStr,
 			Height:      number,
 			RewardTx:    hashStr,
+			Pending:     opt.getPendingIn(exchange, accoutStr),
+			RewardUSD:   rewardUSD,
 			Timestamp:   uint64(time.Now().Unix()),
 		}
-		_ = mongodb.TryDoTimes("AddLiquidRewardResult "+mr.Key, func() error {
-			return mongodb.AddLiquidRewardResult(mr)
-		})
-	case customMethodID:
-	default:
-		log.Warn("unknown byWhat in option", "byWhat", opt.byWhat)
+	case referralMethodID:
+		return &mongodb.MgoReferralRewardResult{
+			Key:           mongodb.GetKeyOfProgramRewardResult(opt.Program, exchange, accoutStr, opt.StartHeight),
+			Program:       opt.Program,
+			Exchange:      exchange,
+			Pairs:         pairs,
+			Start:         opt.StartHeight,
+			End:           opt.EndHeight,
+			RewardToken:   opt.RewardToken,
+			Account:       accoutStr,
+			Reward:        rewardStr,
+			RefereeReward: shareStr,
+			Referees:      number,
+			RewardTx:      hashStr,
+			RewardUSD:     rewardUSD,
+			Timestamp:     uint64(time.Now().Unix()),
+		}
 	}
+	return nil
 }
 
 // WriteNoVolumeOutput write output
@@ -418,7 +509,11 @@ func (opt *Option) WriteNoVolumeSummary() {
 // SendRewardsTransaction send rewards
 func (opt *Option) SendRewardsTransaction(account common.Address, reward *big.Int) (txHash *common.Hash, err error) {
 	rewardToken := common.HexToAddress(opt.RewardToken)
-	return opt.BuildTxArgs.sendRewardsTransaction(account, reward, rewardToken, opt.DryRun)
+	txHash, err = opt.BuildTxArgs.sendRewardsTransaction(account, reward, rewardToken, opt.DryRun)
+	if err == nil && txHash != nil && !opt.DryRun {
+		opt.recordSpending(account, reward, *txHash)
+	}
+	return txHash, err
 }
 
 // CheckSenderRewardTokenBalance check token balance
@@ -435,6 +530,8 @@ func (opt *Option) CheckSenderRewardTokenBalance() (err error) {
 			time.Sleep(time.Second)
 			continue
 		}
+		senderTokenBalanceGauge.SetBigInt(senderTokenBalance, strings.ToLower(sender.String()), strings.ToLower(opt.RewardToken))
+		notifyIfLowBalance(sender, opt.RewardToken, senderTokenBalance)
 		if senderTokenBalance.Cmp(opt.TotalValue) < 0 {
 			err = fmt.Errorf("[check option] not enough reward token balance, %v < %v, sender: %v token: %v", senderTokenBalance, opt.TotalValue, sender.String(), opt.RewardToken)
 			if opt.DryRun {
@@ -451,6 +548,8 @@ func (opt *Option) CheckSenderRewardTokenBalance() (err error) {
 	if err != nil {
 		log.Warn("get sender coin balance failed", "err", err)
 	} else {
+		senderCoinBalanceGauge.SetBigInt(senderBalance, strings.ToLower(sender.String()))
+		notifyIfLowBalance(sender, "", senderBalance)
 		log.Info("get sender coin balance success, please ensure it's enough for gas fee", "balance", senderBalance)
 	}
 	return nil
@@ -469,6 +568,8 @@ func (opt *Option) CheckSenderCoinBalance() (err error) {
 			time.Sleep(time.Second)
 			continue
 		}
+		senderCoinBalanceGauge.SetBigInt(senderBalance, strings.ToLower(sender.String()))
+		notifyIfLowBalance(sender, "", senderBalance)
 		if senderBalance.Cmp(opt.TotalValue) < 0 {
 			err = fmt.Errorf("[check option] not enough coin balance, %v < %v, sender: %v", senderBalance, opt.TotalValue, sender.String())
 			if opt.DryRun {
@@ -483,6 +584,25 @@ func (opt *Option) CheckSenderCoinBalance() (err error) {
 	return nil
 }
 
+// notify if balance is lower than threshold, empty token means coin balance
+func notifyIfLowBalance(sender common.Address, token string, balance *big.Int) {
+	notifyCfg := params.GetConfig().Notify
+	if notifyCfg == nil {
+		return
+	}
+	var threshold *big.Int
+	if token != "" {
+		threshold = notifyCfg.GetLowTokenBalance()
+	} else {
+		threshold = notifyCfg.GetLowCoinBalance()
+	}
+	if threshold == nil || balance.Cmp(threshold) >= 0 {
+		return
+	}
+	notify.Notify(notify.EventLowBalance, "sender balance is lower than threshold",
+		"sender", sender.String(), "token", token, "balance", balance, "threshold", threshold)
+}
+
 func (opt *Option) getAccounts() (accounts [][]common.Address, err error) {
 	accounts = make([][]common.Address, len(opt.Exchanges))
 	var accs []common.Address
@@ -559,7 +679,10 @@ func (opt *Option) GetAccountsAndRewards() (accountStats []mongodb.AccountStatSl
 	for i, exchange := range opt.Exchanges {
 		ifile := opt.getInputFileName(i)
 		if ifile == "" {
-			stats = opt.GetAccountsAndRewardsFromDB(exchange)
+			stats, err = opt.GetAccountsAndRewardsFromDB(exchange)
+			if err != nil {
+				return nil, err
+			}
 		} else {
 			stats, _, err = GetAccountsAndRewardsFromFile(ifile)
 			if err != nil {
@@ -601,10 +724,10 @@ func (opt *Option) updateNoVolumes(noVolumeStarts []uint64) {
 }
 
 // GetAccountsAndRewardsFromDB get from database
-func (opt *Option) GetAccountsAndRewardsFromDB(exchange string) (accountStats mongodb.AccountStatSlice) {
+func (opt *Option) GetAccountsAndRewardsFromDB(exchange string) (accountStats mongodb.AccountStatSlice, err error) {
 	step := opt.StepCount
 	if step == 0 {
-		return getSingleCycleRewardsFromDB(opt.TotalValue, exchange, opt.StartHeight, opt.EndHeight, opt.UseTimeMeasurement, opt.ArchiveMode)
+		return opt.getSingleCycleRewardsFromDB(opt.TotalValue, exchange, opt.StartHeight, opt.EndHeight)
 	}
 
 	// use map to statistic
@@ -615,7 +738,10 @@ func (opt *Option) GetAccountsAndRewardsFromDB(exchange string) (accountStats mo
 
 	var noVolumeStarts []uint64
 	for start := opt.StartHeight; start < opt.EndHeight; start += step {
-		cycleStats := getSingleCycleRewardsFromDB(stepRewards, exchange, start, start+step, opt.UseTimeMeasurement, opt.ArchiveMode)
+		cycleStats, err := opt.getSingleCycleRewardsFromDB(stepRewards, exchange, start, start+step)
+		if err != nil {
+			return nil, err
+		}
 		if len(cycleStats) == 0 {
 			WriteNoVolumeOutput(exchange, start, start+step)
 			noVolumeStarts = append(noVolumeStarts, start)
@@ -641,21 +767,21 @@ func (opt *Option) GetAccountsAndRewardsFromDB(exchange string) (accountStats mo
 	accountStats = mongodb.ConvertToSortedSlice(finStatMap)
 	log.Info("get account volumes from db success", "exchange", exchange, "start", opt.StartHeight, "end", opt.EndHeight, "step", step, "missSteps", opt.noVolumes)
 	opt.WriteNoVolumeSummary()
-	return accountStats
+	return accountStats, nil
 }
 
-func getSingleCycleRewardsFromDB(totalRewards *big.Int, exchange string, startHeight, endHeight uint64, useTimestamp, archiveMode bool) mongodb.AccountStatSlice {
-	accountStats := mongodb.FindAccountVolumes(exchange, startHeight, endHeight, useTimestamp)
-	if len(accountStats) == 0 {
-		return nil
+func (opt *Option) getSingleCycleRewardsFromDB(totalRewards *big.Int, exchange string, startHeight, endHeight uint64) (mongodb.AccountStatSlice, error) {
+	if opt.exclusions == nil {
+		opt.exclusions = make(map[string]*mongodb.MgoRewardExclusion)
 	}
-	if params.GetConfig().Stake != nil {
-		var blockNumber *big.Int
-		if archiveMode {
-			blockNumber = new(big.Int).SetUint64(endHeight)
-		}
-		weightedByStakeAmount(accountStats, blockNumber)
+	accountStats, err := findAccountVolumes(exchange, startHeight, endHeight, opt.UseTimeMeasurement, opt.exclusions)
+	if err != nil {
+		return nil, err
 	}
+	if len(accountStats) == 0 {
+		return nil, nil
+	}
+	opt.boostSharesByStake(exchange, startHeight, accountStats, opt.getStakeBlockNumber(endHeight))
 	accountStats.CalcRewards(totalRewards)
 
 	subject := fmt.Sprintf("calcRewards exchange=%v start=%v end=%v rewards=%v accounts=%v", exchange, startHeight, endHeight, totalRewards, len(accountStats))
@@ -665,40 +791,7 @@ func getSingleCycleRewardsFromDB(totalRewards *big.Int, exchange string, startHe
 		log.Println(line)
 	}
 
-	return accountStats
-}
-
-func weightedByStakeAmount(accountStats mongodb.AccountStatSlice, blockNumber *big.Int) {
-	stakeCfg := params.GetConfig().Stake
-	stakeContract := common.HexToAddress(stakeCfg.Contract)
-	for _, stat := range accountStats {
-		if !params.IsInStakerList(stat.Account) {
-			continue
-		}
-		stakeAmount := capi.LoopGetStakeAmount(stakeContract, stat.Account, blockNumber)
-		stakeWholeAmount := stakeAmount.Div(stakeAmount, big.NewInt(1e18)).Uint64()
-		addPercent := calcAddPercentOfStaking(stakeWholeAmount)
-		if addPercent == 0 {
-			continue
-		}
-		added := new(big.Int).Mul(stat.Share, new(big.Int).SetUint64(addPercent))
-		added.Div(added, big.NewInt(100))
-		log.Trace("add weighted volume share by stake", "account", stat.Account.String(),
-			"origin", stat.Share, "added", added, "addPercent", addPercent,
-			"stakeAmount", stakeAmount, "stakeWholeAmount", stakeWholeAmount,
-			"blockNumber", blockNumber)
-		stat.Share.Add(stat.Share, added)
-	}
-}
-
-func calcAddPercentOfStaking(stakeWholeAmount uint64) (percent uint64) {
-	stakeCfg := params.GetConfig().Stake
-	for _, point := range stakeCfg.Points {
-		if stakeWholeAmount >= point {
-			percent = point
-		}
-	}
-	return percent
+	return accountStats, nil
 }
 
 // GetAccountsAndRewardsFromFile pass line format "<address> <amount>" from input file
@@ -709,7 +802,14 @@ func GetAccountsAndRewardsFromFile(ifile string) (accountStats mongodb.AccountSt
 	}
 	defer file.Close()
 
+	if err = mongodb.CheckAccountListsAvailable(); err != nil {
+		return nil, "", fmt.Errorf("check account lists of %v failed. %v", ifile, err)
+	}
+
 	accountStats = make(mongodb.AccountStatSlice, 0)
+	var position uint64
+	hasPosition := false
+	excludedAmount := big.NewInt(0)
 
 	reader := bufio.NewReader(file)
 	isFirstLine := true
@@ -728,7 +828,7 @@ func GetAccountsAndRewardsFromFile(ifile string) (accountStats mongodb.AccountSt
 			continue
 		}
 		isFirstLine = false
-		parts := blankOrCommaSepRegexp.Split(line, -1)
+		parts := SplitLineParts(line)
 		if len(parts) < 2 {
 			return nil, "", fmt.Errorf("less than 2 parts in line %v", line)
 		}
@@ -742,6 +842,16 @@ func GetAccountsAndRewardsFromFile(ifile string) (accountStats mongodb.AccountSt
 			log.Warn("ignore excluded account", "account", accountStr)
 			continue
 		}
+		if !hasPosition {
+			position, hasPosition = getTitleAccountListPosition(titleLine, ifile), true
+		}
+		if reason, detail := mongodb.CheckRewardAccount(account, position); reason != "" {
+			log.Warn("ignore listed account", "account", accountStr, "reason", reason, "detail", detail, "amount", parts[1])
+			if amount, _ := tools.GetBigIntFromString(parts[1]); amount != nil {
+				excludedAmount.Add(excludedAmount, amount)
+			}
+			continue
+		}
 		if accountStats.IsAccountExist(account) {
 			log.Info("found duplicate account", "account", accountStr)
 		}
@@ -772,6 +882,9 @@ func GetAccountsAndRewardsFromFile(ifile string) (accountStats mongodb.AccountSt
 		}
 		accountStats = append(accountStats, stat)
 	}
+	if excludedAmount.Sign() > 0 {
+		log.Info("ignore listed accounts in file", "file", ifile, "excludedRewards", excludedAmount)
+	}
 
 	return accountStats, titleLine, nil
 }
@@ -784,10 +897,22 @@ func (opt *Option) GetAccountsAndShares() (accountStats []mongodb.AccountStatSli
 	if len(opt.InputFiles) != len(opt.Exchanges) {
 		return nil, fmt.Errorf("count of input files %v and exchanges %v are not equal", len(opt.InputFiles), len(opt.Exchanges))
 	}
+	// account lists are checked at the sample height, or end of cycle
+	position := opt.SampleHeight
+	if position == 0 || opt.UseTimeMeasurement {
+		position = opt.EndHeight - 1
+	}
 	accountStats = make([]mongodb.AccountStatSlice, len(opt.Exchanges))
 	var stats mongodb.AccountStatSlice
 	for i, inputFile := range opt.InputFiles {
-		stats, err = GetAccountsAndSharesFromFile(inputFile, opt.SampleHeight)
+		stats, err = GetAccountsAndSharesFromFile(inputFile, opt.SampleHeight, position)
+		if err != nil {
+			return nil, err
+		}
+		exchange := opt.Exchanges[i]
+		stats, err = applyContractPolicyToStats(stats, opt.getContractCheckBlock(), func(account common.Address, reason string, amount *big.Int, detail string) {
+			opt.addRewardExclusion(exchange, account, reason, amount, detail)
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -796,15 +921,21 @@ func (opt *Option) GetAccountsAndShares() (accountStats []mongodb.AccountStatSli
 	return accountStats, nil
 }
 
-// GetAccountsAndSharesFromFile get accounts and shares from file
-func GetAccountsAndSharesFromFile(ifile string, sampleHeight uint64) (accountStats mongodb.AccountStatSlice, err error) {
+// GetAccountsAndSharesFromFile get accounts and shares from file,
+// account lists are checked at position (block height or timestamp)
+func GetAccountsAndSharesFromFile(ifile string, sampleHeight, position uint64) (accountStats mongodb.AccountStatSlice, err error) {
 	file, err := os.Open(ifile)
 	if err != nil {
 		return nil, fmt.Errorf("open %v failed. %v)", ifile, err)
 	}
 	defer file.Close()
 
+	if err = mongodb.CheckAccountListsAvailable(); err != nil {
+		return nil, fmt.Errorf("check account lists of %v failed. %v", ifile, err)
+	}
+
 	accountStats = make(mongodb.AccountStatSlice, 0)
+	excludedAmount := big.NewInt(0)
 
 	reader := bufio.NewReader(file)
 
@@ -817,7 +948,7 @@ func GetAccountsAndSharesFromFile(ifile string, sampleHeight uint64) (accountSta
 		if isCommentedLine(line) {
 			continue
 		}
-		parts := blankOrCommaSepRegexp.Split(line, -1)
+		parts := SplitLineParts(line)
 		if len(parts) < 2 {
 			return nil, fmt.Errorf("less than 2 parts in line %v", line)
 		}
@@ -831,6 +962,13 @@ func GetAccountsAndSharesFromFile(ifile string, sampleHeight uint64) (accountSta
 			log.Warn("ignore excluded account", "account", accountStr)
 			continue
 		}
+		if reason, detail := mongodb.CheckRewardAccount(account, position); reason != "" {
+			log.Warn("ignore listed account", "account", accountStr, "reason", reason, "detail", detail, "amount", parts[1])
+			if amount, _ := tools.GetBigIntFromString(parts[1]); amount != nil {
+				excludedAmount.Add(excludedAmount, amount)
+			}
+			continue
+		}
 		if accountStats.IsAccountExist(account) {
 			log.Info("found duplicate account", "account", accountStr)
 		}
@@ -848,10 +986,41 @@ func GetAccountsAndSharesFromFile(ifile string, sampleHeight uint64) (accountSta
 		}
 		accountStats = append(accountStats, stat)
 	}
+	if excludedAmount.Sign() > 0 {
+		log.Info("ignore listed accounts in file", "file", ifile, "excludedShares", excludedAmount)
+	}
 
 	return accountStats, nil
 }
 
+// account lists of rewards files are checked at the sample height or end of cycle recorded in title line,
+// files without cycle in title line are checked at current block height or timestamp
+func getTitleAccountListPosition(titleLine, ifile string) uint64 {
+	if title, err := ParseRewardTitleLine(titleLine); err == nil {
+		if title.SampleHeight != 0 && !title.UseTime {
+			return title.SampleHeight
+		}
+		return title.End - 1
+	}
+	log.Warn("no cycle in title line, check account lists at current position", "file", ifile)
+	return getAccountListPosition()
+}
+
+func getAccountListPosition() uint64 {
+	distCfg := params.GetConfig().Distribute
+	if distCfg != nil && distCfg.UseTimeMeasurement {
+		return uint64(time.Now().Unix())
+	}
+	if !mongodb.HasSession() {
+		return 0
+	}
+	syncInfo, err := mongodb.FindLatestSyncInfo()
+	if err != nil {
+		return 0
+	}
+	return syncInfo.Number
+}
+
 func isCommentedLine(line string) bool {
 	return strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//")
 }
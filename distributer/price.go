@@ -0,0 +1,39 @@
+package distributer
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/price"
+)
+
+// USD price of one Wei of reward token at end of cycle, loaded once per option, nil if unknown
+func (opt *Option) getRewardTokenUSD() *big.Rat {
+	if !opt.rewardTokenUSDLoaded {
+		opt.rewardTokenUSD = price.GetTokenUSD(opt.RewardToken, opt.getCycleEndTimestamp())
+		opt.rewardTokenUSDLoaded = true
+	}
+	return opt.rewardTokenUSD
+}
+
+// timestamp of end of cycle, use the last block of cycle in block height measurement
+func (opt *Option) getCycleEndTimestamp() uint64 {
+	if opt.UseTimeMeasurement && opt.EndHeight > 0 {
+		return opt.EndHeight
+	}
+	if opt.EndHeight > 0 && mongodb.HasSession() {
+		blocks, err := mongodb.FindBlocksInRange(opt.EndHeight-1, opt.EndHeight-1)
+		if err == nil && len(blocks) == 1 && blocks[0] != nil {
+			return blocks[0].Timestamp
+		}
+		log.Warn("find last block of cycle failed, use current time for price", "height", opt.EndHeight-1, "err", err)
+	}
+	return uint64(time.Now().Unix())
+}
+
+// USD value of reward, empty if unknown
+func (opt *Option) rewardToUSD(reward *big.Int) string {
+	return price.FormatUSD(price.TokenToUSD(reward, opt.getRewardTokenUSD()))
+}
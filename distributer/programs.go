@@ -0,0 +1,164 @@
+package distributer
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/notify"
+	"github.com/anyswap/ANYToken-distribution/params"
+)
+
+// StartPrograms start isolated runners of enabled reward programs
+func StartPrograms(distCfg *params.DistributeConfig) {
+	for _, prog := range params.GetConfig().Programs {
+		if !prog.Enable {
+			log.Info("[program] program is disabled", "program", prog.ID)
+			continue
+		}
+		runner, err := initProgramRunner(distCfg, prog)
+		if err != nil {
+			log.Error("[program] start program failed", "program", prog.ID, "err", err)
+			continue
+		}
+		log.Info("[program] start program", "program", prog.ID, "method", prog.Method, "rewardToken", prog.RewardToken, "start", prog.Start, "end", prog.End)
+		go runner.run()
+	}
+}
+
+func initProgramRunner(distCfg *params.DistributeConfig, prog *params.ProgramConfig) (*distributeRunner, error) {
+	args, err := getBuildTxArgs(distCfg, prog.Sender)
+	if err != nil {
+		return nil, err
+	}
+	runner := &distributeRunner{
+		program:              prog.ID,
+		rewardToken:          prog.RewardToken,
+		start:                prog.Start,
+		end:                  prog.End,
+		stable:               distCfg.StableHeight,
+		byLiquidCycleLen:     prog.CycleLen, // whole cycle of every method
+		byLiquidCycleRewards: big.NewInt(0),
+		byVolumeCycleLen:     prog.CycleLen,
+		byVolumeCycleRewards: big.NewInt(0),
+		totalVolumeRewards:   big.NewInt(0),
+		useTimeMeasurement:   distCfg.UseTimeMeasurement,
+		isArchiveMode:        distCfg.ArchiveMode,
+	}
+	if runner.useTimeMeasurement {
+		runner.stable = distCfg.StableDuration
+	}
+
+	switch GetStandardByWhat(prog.Method) {
+	case byLiquidMethodID:
+		runner.liquidExchanges = prog.Exchanges
+		runner.liquidWeights = prog.Weights
+		runner.byLiquidCycleRewards = prog.GetRewards()
+		runner.byLiquidArgs = args
+	case byVolumeMethodID:
+		runner.tradeExchanges = prog.Exchanges
+		runner.tradeWeights = prog.Weights
+		runner.tradeWeightIsPercentage = prog.WeightIsPercentage
+		runner.quickSettleVolumeRewards = prog.QuickSettle
+		runner.byVolumeCycleLen = prog.GetStepLen()
+		runner.totalVolumeCycles = prog.CycleLen / runner.byVolumeCycleLen
+		runner.byVolumeCycleRewards = getProgramStepRewards(prog)
+		runner.totalVolumeRewards = new(big.Int).Mul(runner.byVolumeCycleRewards, new(big.Int).SetUint64(runner.totalVolumeCycles))
+		runner.byVolumeArgs = args
+	case customMethodID:
+		runner.customInputDir = prog.InputDir
+		runner.customArgs, err = getSendTxArgs(distCfg, prog.Sender, prog.KeystoreFile, prog.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown program method '%v'", prog.Method)
+	}
+	return runner, nil
+}
+
+// program rewards are configed of every cycle, divided equally by steps
+func getProgramStepRewards(prog *params.ProgramConfig) *big.Int {
+	steps := prog.CycleLen / prog.GetStepLen()
+	return new(big.Int).Div(prog.GetRewards(), new(big.Int).SetUint64(steps))
+}
+
+// apply program settings on cycle option
+func (opt *Option) applyProgram(prog *params.ProgramConfig, totalReward *big.Int) error {
+	if GetStandardByWhat(prog.Method) != opt.byWhat {
+		return fmt.Errorf("method of program %v is %v, not %v", prog.ID, prog.Method, opt.byWhat)
+	}
+	opt.Program = prog.ID
+	opt.RewardToken = prog.RewardToken
+	opt.Exchanges = prog.Exchanges
+	opt.Weights = prog.Weights
+	opt.TotalValue = totalReward
+	if opt.TotalValue == nil {
+		opt.TotalValue = prog.GetRewards()
+	}
+	if opt.byWhat == byVolumeMethodID {
+		opt.WeightIsPercentage = prog.WeightIsPercentage
+		opt.StepCount = prog.GetStepLen()
+		opt.StepReward = getProgramStepRewards(prog)
+	}
+	if err := opt.checkSteps(); err != nil {
+		return err
+	}
+	return opt.checkWeights()
+}
+
+func (runner *distributeRunner) runCustomDistribute(wg *sync.WaitGroup, curCycleStart uint64) {
+	defer wg.Done()
+	if runner.customInputDir == "" {
+		return
+	}
+	log.Info("start custom reward distribution", "program", runner.program, "start", curCycleStart)
+	for !runner.isEnded(curCycleStart) {
+		curCycleEnd := curCycleStart + runner.byLiquidCycleLen
+		waitCycleEnd("custom", curCycleStart, curCycleEnd, runner.stable, 60*time.Second, runner.useTimeMeasurement)
+		_ = runner.sendCustomRewards(curCycleStart, curCycleEnd)
+		// start next cycle
+		curCycleStart = curCycleEnd
+		log.Info("start next custom cycle", "program", runner.program, "start", curCycleStart)
+	}
+}
+
+// send custom rewards of cycle from input file, wait until the file is provided
+func (runner *distributeRunner) sendCustomRewards(start, end uint64) error {
+	fileName := fmt.Sprintf("%s-%d-%d", runner.program, start, end)
+	inputFile := filepath.Join(runner.customInputDir, fileName+".csv")
+	for {
+		if _, err := os.Stat(inputFile); err == nil {
+			break
+		}
+		log.Info("wait custom rewards input file", "program", runner.program, "input", inputFile)
+		time.Sleep(60 * time.Second)
+	}
+	opt := &Option{
+		BuildTxArgs:        runner.customArgs,
+		StartHeight:        start,
+		EndHeight:          end,
+		RewardToken:        runner.rewardToken,
+		InputFiles:         []string{inputFile},
+		OutputFiles:        []string{filepath.Join(runner.customInputDir, fileName+"-result.csv")},
+		SaveDB:             true,
+		UseTimeMeasurement: runner.useTimeMeasurement,
+		Program:            runner.program,
+		byWhat:             customMethodID,
+	}
+	log.Info("start send custom reward", "option", opt.String())
+	notify.Notify(notify.EventCycleStart, "custom reward cycle start", "program", runner.program, "start", start, "end", end)
+	err := opt.SendRewardsFromFile()
+	if err != nil {
+		log.Error("send custom reward failed", "program", runner.program, "start", start, "end", end, "err", err)
+		notify.Notify(notify.EventCycleFinish, "custom reward cycle failed", "program", runner.program, "start", start, "end", end, "err", err)
+		return err
+	}
+	log.Info("send custom reward success", "program", runner.program, "start", start, "end", end, "rewards", opt.TotalValue)
+	notify.Notify(notify.EventCycleFinish, "custom reward cycle success", "program", runner.program, "start", start, "end", end, "rewards", opt.TotalValue)
+	return nil
+}
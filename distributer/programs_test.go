@@ -0,0 +1,73 @@
+package distributer
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+const (
+	testProgram     = "partner"
+	testExchange    = "0x049ddc3cd20ac7a2f6c867680f7e21de70aca9c3"
+	testRewardToken = "0x0000000000000000000000000000000000000001"
+	testAccount     = "0x0000000000000000000000000000000000000002"
+)
+
+func newTestProgramOption(byWhat string) *Option {
+	return &Option{
+		Program:              testProgram,
+		RewardToken:          testRewardToken,
+		StartHeight:          100,
+		EndHeight:            200,
+		byWhat:               byWhat,
+		rewardTokenUSDLoaded: true, // no price sources
+	}
+}
+
+func TestRewardResultsRecordProgram(t *testing.T) {
+	defer params.SetConfig(params.GetConfig())
+	params.SetConfig(&params.Config{})
+
+	wantKey := mongodb.GetKeyOfProgramRewardResult(testProgram, testExchange, testAccount, 100)
+	for _, byWhat := range []string{byVolumeMethodID, byLiquidMethodID, referralMethodID} {
+		opt := newTestProgramOption(byWhat)
+		var program, key string
+		switch mr := opt.newRewardResult(testExchange, testAccount, "100", "1000", 1, "").(type) {
+		case *mongodb.MgoVolumeRewardResult:
+			program, key = mr.Program, mr.Key
+		case *mongodb.MgoLiquidRewardResult:
+			program, key = mr.Program, mr.Key
+		case *mongodb.MgoReferralRewardResult:
+			program, key = mr.Program, mr.Key
+		default:
+			t.Fatalf("no reward result of %v", byWhat)
+		}
+		if program != testProgram || key != wantKey {
+			t.Errorf("%v result has program '%v' key '%v', want '%v' '%v'", byWhat, program, key, testProgram, wantKey)
+		}
+	}
+	if mr := newTestProgramOption(customMethodID).newRewardResult(testExchange, testAccount, "100", "", 0, ""); mr != nil {
+		t.Errorf("custom rewards should have no reward result, got %v", mr)
+	}
+}
+
+func TestCustomSpendingRecordProgram(t *testing.T) {
+	defer func() { unrecordedSpendings = nil }()
+	since := uint64(time.Now().Unix())
+
+	opt := newTestProgramOption(customMethodID)
+	opt.SaveDB = true
+	opt.recordSpending(common.HexToAddress(testAccount), big.NewInt(100), common.Hash{})
+
+	if spent := sumUnrecordedSpendingsSince(testProgram, strings.ToUpper(testRewardToken), since); spent.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("want spending 100 of program %v, got %v", testProgram, spent)
+	}
+	if spent := sumUnrecordedSpendingsSince("", testRewardToken, since); spent.Sign() != 0 {
+		t.Errorf("want no spending of default distribution, got %v", spent)
+	}
+}
@@ -0,0 +1,279 @@
+package distributer
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	ethereum "github.com/fsn-dev/fsn-go-sdk/efsn"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+// reconcile issue types
+const (
+	ReconcileNoTx       = "notx"       // account never got a reward tx
+	ReconcileMissing    = "missing"    // reward tx is not found on chain
+	ReconcileReverted   = "reverted"   // reward tx is reverted
+	ReconcileDuplicated = "duplicated" // reward tx is recorded more than once
+	ReconcileMismatched = "mismatched" // transfered amount is not the recorded reward
+)
+
+var topicTransfer = common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+
+// RewardPayment recorded reward payment
+type RewardPayment struct {
+	Exchange    string
+	Account     common.Address
+	Start       uint64
+	End         uint64
+	RewardToken string
+	Reward      *big.Int
+	RewardTx    string
+}
+
+// ReconcileIssue reconcile issue of recorded reward payment
+type ReconcileIssue struct {
+	Type    string
+	Payment *RewardPayment
+	Paid    *big.Int
+}
+
+func (issue *ReconcileIssue) String() string {
+	p := issue.Payment
+	return fmt.Sprintf("%v exchange=%v start=%v end=%v account=%v reward=%v paid=%v tx=%v",
+		issue.Type, p.Exchange, p.Start, p.End, strings.ToLower(p.Account.String()), p.Reward, issue.Paid, p.RewardTx)
+}
+
+// corrective reward which should be sent again
+func (issue *ReconcileIssue) unpaid() *big.Int {
+	switch issue.Type {
+	case ReconcileNoTx, ReconcileMissing, ReconcileReverted:
+		return issue.Payment.Reward
+	case ReconcileMismatched:
+		if issue.Payment.Reward.Cmp(issue.Paid) > 0 {
+			return new(big.Int).Sub(issue.Payment.Reward, issue.Paid)
+		}
+	}
+	return nil
+}
+
+type txTransfers struct {
+	err       string                      // missing or reverted
+	transfers map[common.Address]*big.Int // paid amount of accounts
+}
+
+// ReconcileRewards compare reward results recorded in database of cycles start in [StartHeight, EndHeight)
+// with transfers on chain, report issues and write corrective rewards to output file.
+func (opt *Option) ReconcileRewards(ofile string) error {
+	payments, err := opt.getRewardPayments()
+	if err != nil {
+		return err
+	}
+	log.Info("[reconcile] find reward payments success", "bywhat", opt.byWhat, "start", opt.StartHeight, "end", opt.EndHeight, "count", len(payments))
+
+	recordedTxs := make(map[string]bool)
+	var issues []*ReconcileIssue
+	for _, payment := range payments {
+		issue, err := reconcilePayment(payment, recordedTxs)
+		if err != nil {
+			return err
+		}
+		if issue != nil {
+			log.Warn("[reconcile] found issue", "issue", issue.String())
+			issues = append(issues, issue)
+		}
+	}
+
+	// aggregate corrective rewards of accounts, keep order of first appearance
+	corrections := make(mongodb.AccountStatSlice, 0)
+	correctionMap := make(map[common.Address]*mongodb.AccountStat)
+	issueCounts := make(map[string]int)
+	totalUnpaid := big.NewInt(0)
+	for _, issue := range issues {
+		issueCounts[issue.Type]++
+		unpaid := issue.unpaid()
+		if unpaid == nil || unpaid.Sign() <= 0 {
+			continue
+		}
+		totalUnpaid.Add(totalUnpaid, unpaid)
+		account := issue.Payment.Account
+		if stat, exist := correctionMap[account]; exist {
+			stat.Reward.Add(stat.Reward, unpaid)
+			continue
+		}
+		stat := &mongodb.AccountStat{Account: account, Reward: new(big.Int).Set(unpaid)}
+		correctionMap[account] = stat
+		corrections = append(corrections, stat)
+	}
+
+	if err = opt.writeCorrectiveRewards(ofile, corrections); err != nil {
+		return err
+	}
+
+	log.Info("[reconcile] finished",
+		"payments", len(payments),
+		"issues", len(issues),
+		ReconcileNoTx, issueCounts[ReconcileNoTx],
+		ReconcileMissing, issueCounts[ReconcileMissing],
+		ReconcileReverted, issueCounts[ReconcileReverted],
+		ReconcileDuplicated, issueCounts[ReconcileDuplicated],
+		ReconcileMismatched, issueCounts[ReconcileMismatched],
+		"correctiveAccounts", len(corrections),
+		"totalUnpaid", totalUnpaid,
+		"output", ofile,
+	)
+	return nil
+}
+
+func (opt *Option) getRewardPayments() (payments []*RewardPayment, err error) {
+	exchanges := opt.Exchanges
+	if len(exchanges) == 0 {
+		exchanges = []string{""} // all exchanges
+	}
+	for _, exchange := range exchanges {
+		switch opt.byWhat {
+		case byVolumeMethodID:
+			results, errf := mongodb.FindVolumeRewardResultsInRange(exchange, opt.StartHeight, opt.EndHeight)
+			if errf != nil {
+				return nil, fmt.Errorf("find volume reward results failed, %v", errf)
+			}
+			for _, res := range results {
+				if res.Program != opt.Program { // reward programs are reconciled separately
+					continue
+				}
+				payments = opt.appendRewardPayment(payments, res.Exchange, res.Account, res.Start, res.End, res.RewardToken, res.Reward, res.RewardTx)
+			}
+		case byLiquidMethodID:
+			results, errf := mongodb.FindLiquidRewardResultsInRange(exchange, opt.StartHeight, opt.EndHeight)
+			if errf != nil {
+				return nil, fmt.Errorf("find liquid reward results failed, %v", errf)
+			}
+			for _, res := range results {
+				if res.Program != opt.Program {
+					continue
+				}
+				payments = opt.appendRewardPayment(payments, res.Exchange, res.Account, res.Start, res.End, res.RewardToken, res.Reward, res.RewardTx)
+			}
+		default:
+			return nil, fmt.Errorf("can only reconcile liquid or volume rewards. wrong byWhat %v", opt.byWhat)
+		}
+	}
+	return payments, nil
+}
+
+func (opt *Option) appendRewardPayment(payments []*RewardPayment, exchange, account string, start, end uint64, rewardToken, rewardStr, rewardTx string) []*RewardPayment {
+	if opt.RewardToken != "" && !strings.EqualFold(opt.RewardToken, rewardToken) {
+		return payments
+	}
+	reward, err := tools.GetBigIntFromString(rewardStr)
+	if err != nil || reward == nil || reward.Sign() <= 0 {
+		log.Warn("[reconcile] ignore wrong reward result", "exchange", exchange, "account", account, "start", start, "reward", rewardStr)
+		return payments
+	}
+	return append(payments, &RewardPayment{
+		Exchange:    exchange,
+		Account:     common.HexToAddress(account),
+		Start:       start,
+		End:         end,
+		RewardToken: rewardToken,
+		Reward:      reward,
+		RewardTx:    rewardTx,
+	})
+}
+
+func reconcilePayment(payment *RewardPayment, recordedTxs map[string]bool) (*ReconcileIssue, error) {
+	if payment.RewardTx == "" {
+		return &ReconcileIssue{Type: ReconcileNoTx, Payment: payment}, nil
+	}
+	if payment.RewardTx == VestingRewardTx {
+		return nil, nil // released by vesting schedule
+	}
+	txKey := strings.ToLower(payment.RewardTx)
+	if recordedTxs[txKey] {
+		return &ReconcileIssue{Type: ReconcileDuplicated, Payment: payment}, nil
+	}
+	recordedTxs[txKey] = true
+
+	transfers, err := getTxTransfers(common.HexToHash(payment.RewardTx), payment.RewardToken)
+	if err != nil {
+		return nil, err
+	}
+	if transfers.err != "" {
+		return &ReconcileIssue{Type: transfers.err, Payment: payment}, nil
+	}
+	paid := transfers.transfers[payment.Account]
+	if paid == nil {
+		paid = big.NewInt(0)
+	}
+	if paid.Cmp(payment.Reward) != 0 {
+		return &ReconcileIssue{Type: ReconcileMismatched, Payment: payment, Paid: paid}, nil
+	}
+	return nil, nil
+}
+
+func getTxTransfers(txHash common.Hash, rewardToken string) (*txTransfers, error) {
+	result := &txTransfers{transfers: make(map[common.Address]*big.Int)}
+	receipt, err := capi.GetTransactionReceipt(txHash)
+	if err == ethereum.NotFound {
+		result.err = ReconcileMissing
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get receipt of %v failed, %v", txHash.String(), err)
+	}
+	if receipt.Status != 1 {
+		result.err = ReconcileReverted
+		return result, nil
+	}
+
+	if rewardToken == "" {
+		tx, _, err := capi.GetTransactionByHash(txHash)
+		if err != nil {
+			return nil, fmt.Errorf("get transaction %v failed, %v", txHash.String(), err)
+		}
+		if tx.To() != nil {
+			result.transfers[*tx.To()] = tx.Value()
+		}
+		return result, nil
+	}
+
+	token := common.HexToAddress(rewardToken)
+	for _, rlog := range receipt.Logs {
+		if rlog.Address != token || len(rlog.Topics) != 3 || rlog.Topics[0] != topicTransfer {
+			continue
+		}
+		to := common.BytesToAddress(rlog.Topics[2].Bytes())
+		amount := new(big.Int).SetBytes(rlog.Data)
+		if paid, exist := result.transfers[to]; exist {
+			paid.Add(paid, amount)
+		} else {
+			result.transfers[to] = amount
+		}
+	}
+	return result, nil
+}
+
+// write in format which 'sendrewards' command consumes
+func (opt *Option) writeCorrectiveRewards(ofile string, corrections mongodb.AccountStatSlice) error {
+	outputFile, err := os.OpenFile(ofile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	title := fmt.Sprintf("reconcile=%v&&start=%v&&end=%v&&rewardToken=%v&&totalReward=%v",
+		opt.byWhat, opt.StartHeight, opt.EndHeight, strings.ToLower(opt.RewardToken), corrections.CalcTotalReward())
+	if err = WriteOutput(outputFile, "#account", "reward", title); err != nil {
+		return err
+	}
+	for _, stat := range corrections {
+		if err = WriteOutput(outputFile, strings.ToLower(stat.Account.String()), stat.Reward.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
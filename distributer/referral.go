@@ -0,0 +1,237 @@
+package distributer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+var (
+	errSelfReferral     = errors.New("referee and referrer are the same")
+	errZeroAddrReferral = errors.New("referee or referrer is zero address")
+)
+
+// RegisterReferral register referrer of referee from file or API,
+// the registration position is the latest synced block and current time.
+// return false if referee is already registered.
+func RegisterReferral(referee, referrer common.Address, source string) (bool, error) {
+	if referee == referrer {
+		return false, errSelfReferral
+	}
+	if referee == (common.Address{}) || referrer == (common.Address{}) {
+		return false, errZeroAddrReferral
+	}
+	var blockNumber uint64
+	if syncInfo, err := mongodb.FindLatestSyncInfo(); err == nil {
+		blockNumber = syncInfo.Number
+	}
+	refereeStr := strings.ToLower(referee.String())
+	mr := &mongodb.MgoReferral{
+		Key:         refereeStr,
+		Referee:     refereeStr,
+		Referrer:    strings.ToLower(referrer.String()),
+		Source:      source,
+		BlockNumber: blockNumber,
+		Timestamp:   uint64(time.Now().Unix()),
+	}
+	return mongodb.AddReferral(mr)
+}
+
+// ImportReferralsFromFile import referrals from file of lines 'referee referrer',
+// return count of added and skipped (already registered) referrals.
+func ImportReferralsFromFile(ifile string) (added, skipped int, err error) {
+	file, err := os.Open(ifile)
+	if err != nil {
+		return 0, 0, fmt.Errorf("open %v failed. %v)", ifile, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		lineData, _, errf := reader.ReadLine()
+		if errf == io.EOF {
+			break
+		}
+		line := strings.TrimSpace(string(lineData))
+		if line == "" || isCommentedLine(line) {
+			continue
+		}
+		parts := SplitLineParts(line)
+		if len(parts) < 2 || !common.IsHexAddress(parts[0]) || !common.IsHexAddress(parts[1]) {
+			return added, skipped, fmt.Errorf("found wrong referral line %v", line)
+		}
+		ok, errf := RegisterReferral(common.HexToAddress(parts[0]), common.HexToAddress(parts[1]), mongodb.ReferralSourceFile)
+		if errf != nil {
+			return added, skipped, fmt.Errorf("register referral line %v failed. %v", line, errf)
+		}
+		if ok {
+			added++
+		} else {
+			log.Warn("ignore already registered referee", "line", line)
+			skipped++
+		}
+	}
+	return added, skipped, nil
+}
+
+// ReferralInfo referrer and referees of account
+type ReferralInfo struct {
+	Account  string
+	Referral *mongodb.MgoReferral   `json:",omitempty"` // registration of account as referee
+	Referees []*mongodb.MgoReferral `json:",omitempty"` // registrations of account as referrer
+}
+
+// GetReferralInfo get referrer and referees of account
+func GetReferralInfo(account common.Address) (*ReferralInfo, error) {
+	accountStr := strings.ToLower(account.String())
+	info := &ReferralInfo{Account: accountStr}
+	info.Referral, _ = mongodb.FindReferral(accountStr)
+	referees, err := mongodb.FindReferralsOfReferrer(accountStr)
+	if err != nil {
+		return nil, err
+	}
+	info.Referees = referees
+	return info, nil
+}
+
+func (opt *Option) isReferralEnabled() bool {
+	return opt.Program == "" && params.IsReferralEnabled() && mongodb.HasSession()
+}
+
+// referrers of accounts in stats which are registered before cycle end
+func (opt *Option) getReferrers(accountStats []mongodb.AccountStatSlice) map[common.Address]common.Address {
+	var accounts []string
+	for _, stats := range accountStats {
+		for _, stat := range stats {
+			accounts = append(accounts, stat.Account.String())
+		}
+	}
+	if len(accounts) == 0 {
+		return nil
+	}
+	referrals, err := mongodb.FindReferrals(accounts)
+	if err != nil {
+		log.Warn("[referral] find referrals failed", "start", opt.StartHeight, "end", opt.EndHeight, "err", err)
+		return nil
+	}
+	referrers := make(map[common.Address]common.Address, len(referrals))
+	for _, ref := range referrals {
+		position := ref.BlockNumber
+		if opt.UseTimeMeasurement {
+			position = ref.Timestamp
+		}
+		if position >= opt.EndHeight {
+			continue
+		}
+		referrer := common.HexToAddress(ref.Referrer)
+		if reason, _ := mongodb.CheckRewardAccount(referrer, opt.StartHeight); reason != "" {
+			log.Info("[referral] ignore excluded referrer", "referee", ref.Referee, "referrer", ref.Referrer, "reason", reason)
+			continue
+		}
+		referrers[common.HexToAddress(ref.Referee)] = referrer
+	}
+	return referrers
+}
+
+// calc referral rewards of every exchange from calced volume rewards of referees.
+// referral rewards are deducted from referees' rewards in the same budget,
+// or added from separate budget which is scaled down to budget per cycle if configed.
+// Share of referral stat is the sum of referees' rewards, Number is the count of referees.
+func (opt *Option) calcReferralRewards(accountStats []mongodb.AccountStatSlice) []mongodb.AccountStatSlice {
+	if !opt.isReferralEnabled() {
+		return nil
+	}
+	referrers := opt.getReferrers(accountStats)
+	if len(referrers) == 0 {
+		return nil
+	}
+	refCfg := params.GetConfig().Referral
+	percent := new(big.Int).SetUint64(refCfg.Percent)
+	// carried in rewards of previous cycles are not referred
+	cycleTotal := opt.TotalValue
+	if opt.carriedIn != nil && opt.carriedIn.Sign() > 0 {
+		cycleTotal = new(big.Int).Sub(opt.TotalValue, opt.carriedIn)
+	}
+	totalReferral := big.NewInt(0)
+	referralStats := make([]mongodb.AccountStatSlice, len(accountStats))
+	for i, stats := range accountStats {
+		statsMap := make(map[common.Address]*mongodb.AccountStat)
+		for _, stat := range stats {
+			referrer, exist := referrers[stat.Account]
+			if !exist || stat.Reward == nil || stat.Reward.Sign() <= 0 {
+				continue
+			}
+			amount := new(big.Int).Mul(stat.Reward, percent)
+			if cycleTotal != opt.TotalValue {
+				amount.Mul(amount, cycleTotal)
+				amount.Div(amount, opt.TotalValue)
+			}
+			amount.Div(amount, big.NewInt(100))
+			if amount.Sign() <= 0 {
+				continue
+			}
+			refStat, exist := statsMap[referrer]
+			if !exist {
+				refStat = &mongodb.AccountStat{
+					Account: referrer,
+					Reward:  big.NewInt(0),
+					Share:   big.NewInt(0),
+				}
+				statsMap[referrer] = refStat
+				referralStats[i] = append(referralStats[i], refStat)
+			}
+			refStat.Share.Add(refStat.Share, stat.Reward)
+			refStat.Reward.Add(refStat.Reward, amount)
+			refStat.Number++
+			totalReferral.Add(totalReferral, amount)
+			if !refCfg.SeparateBudget {
+				stat.Reward = new(big.Int).Sub(stat.Reward, amount)
+			}
+		}
+	}
+	if !refCfg.SeparateBudget {
+		opt.referralDeduct = totalReferral
+	}
+	budget := refCfg.GetBudgetPerCycle()
+	if refCfg.SeparateBudget && budget != nil && totalReferral.Cmp(budget) > 0 {
+		log.Info("[referral] scale down referral rewards to budget", "total", totalReferral, "budget", budget)
+		for _, stats := range referralStats {
+			for _, stat := range stats {
+				stat.Reward.Mul(stat.Reward, budget)
+				stat.Reward.Div(stat.Reward, totalReferral)
+			}
+		}
+	}
+	log.Info("[referral] calc referral rewards success", "start", opt.StartHeight, "end", opt.EndHeight,
+		"referees", len(referrers), "percent", refCfg.Percent, "separateBudget", refCfg.SeparateBudget, "total", totalReferral)
+	return referralStats
+}
+
+// dispatch referral rewards as a separate reward type with its own output files
+func (opt *Option) dispatchReferralRewards(referralStats []mongodb.AccountStatSlice) error {
+	totalValue := big.NewInt(0)
+	for _, stats := range referralStats {
+		totalValue.Add(totalValue, stats.CalcTotalReward())
+	}
+	if totalValue.Sign() <= 0 {
+		return nil
+	}
+	sub := *opt
+	sub.byWhat = referralMethodID
+	sub.TotalValue = totalValue
+	sub.OutputFiles = nil
+	sub.outputFiles = nil
+	sub.pendingIn = nil
+	defer sub.deinit()
+	return sub.dispatchRewards(referralStats)
+}
@@ -0,0 +1,354 @@
+package distributer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common/hexutil"
+)
+
+// safe batch output formats
+const (
+	SafeTxBuilderFormat = "txbuilder"
+	SafeMultiSendFormat = "multisend"
+)
+
+var (
+	// multiSend(bytes)
+	multiSendFuncHash = common.FromHex("0x8d80ff0a")
+
+	safeCallOperation         uint8
+	safeDelegateCallOperation uint8 = 1
+)
+
+// SafeBatchArgs safe batch args
+type SafeBatchArgs struct {
+	Safe         string
+	MultiSend    string
+	ProposalSize uint64
+	Format       string
+}
+
+// Check check safe batch args
+func (args *SafeBatchArgs) Check() error {
+	if !common.IsHexAddress(args.Safe) {
+		return fmt.Errorf("wrong safe address '%v'", args.Safe)
+	}
+	switch args.Format {
+	case SafeTxBuilderFormat:
+	case SafeMultiSendFormat:
+		if !common.IsHexAddress(args.MultiSend) {
+			return fmt.Errorf("wrong multiSend address '%v'", args.MultiSend)
+		}
+	default:
+		return fmt.Errorf("unknown safe batch format '%v'", args.Format)
+	}
+	if args.ProposalSize == 0 {
+		return fmt.Errorf("zero proposal size")
+	}
+	return nil
+}
+
+// SafeTxBuilderBatch Safe Transaction Builder batch file
+type SafeTxBuilderBatch struct {
+	Version      string                `json:"version"`
+	ChainID      string                `json:"chainId"`
+	CreatedAt    int64                 `json:"createdAt"`
+	Meta         *SafeTxBuilderMeta    `json:"meta"`
+	Transactions []*SafeTxBuilderTxDef `json:"transactions"`
+}
+
+// SafeTxBuilderMeta Safe Transaction Builder batch meta
+type SafeTxBuilderMeta struct {
+	Name                   string `json:"name"`
+	Description            string `json:"description"`
+	CreatedFromSafeAddress string `json:"createdFromSafeAddress"`
+}
+
+// SafeTxBuilderTxDef Safe Transaction Builder transaction
+type SafeTxBuilderTxDef struct {
+	To                   string      `json:"to"`
+	Value                string      `json:"value"`
+	Data                 string      `json:"data"`
+	ContractMethod       interface{} `json:"contractMethod"`
+	ContractInputsValues interface{} `json:"contractInputsValues"`
+}
+
+// SafeMultiSendProposal MultiSend encoded safe transaction
+type SafeMultiSendProposal struct {
+	Safe      string
+	ChainID   string
+	To        string
+	Value     string
+	Data      hexutil.Bytes
+	Operation uint8
+	Transfers int
+	Total     *big.Int
+}
+
+type safeTransfer struct {
+	to    common.Address
+	value *big.Int
+	data  []byte
+}
+
+// safe batch proposal built in memory, written after all proposals are checked
+type safeBatchProposal struct {
+	file      string
+	content   interface{}
+	transfers int
+	total     *big.Int
+}
+
+// BuildSafeBatchFromFile build safe batch proposals from verified input reward files
+func (opt *Option) BuildSafeBatchFromFile(args *SafeBatchArgs) error {
+	if err := args.Check(); err != nil {
+		return err
+	}
+	if len(opt.InputFiles) != len(opt.OutputFiles) {
+		return fmt.Errorf("count of input and output files is not equal")
+	}
+	if opt.RewardToken != "" && !common.IsHexAddress(opt.RewardToken) {
+		return fmt.Errorf("wrong reward token '%v'", opt.RewardToken)
+	}
+	chainID, err := capi.GetChainID()
+	if err != nil {
+		return fmt.Errorf("get chain ID failed, %v", err)
+	}
+	safe := common.HexToAddress(args.Safe)
+	balance, err := opt.getSafeBalance(safe)
+	if err != nil {
+		return err
+	}
+
+	// build and check all proposals before writing any of them
+	var proposals []*safeBatchProposal
+	remainBalance := new(big.Int).Set(balance)
+	var remainValue *big.Int
+	if opt.TotalValue != nil {
+		remainValue = new(big.Int).Set(opt.TotalValue)
+	}
+	grandTotal := big.NewInt(0)
+	for i, inputFile := range opt.InputFiles {
+		accountStats, _, err := GetAccountsAndRewardsFromFile(inputFile)
+		if err != nil {
+			return err
+		}
+		opt.scaleRewards(accountStats)
+		inputTotal := accountStats.CalcTotalReward()
+
+		fileProposals, err := opt.buildSafeBatch(args, chainID, accountStats, inputTotal, inputFile, opt.OutputFiles[i])
+		if err != nil {
+			return err
+		}
+		for _, proposal := range fileProposals {
+			if remainBalance.Cmp(proposal.total) < 0 {
+				return fmt.Errorf("not enough safe balance for proposal %v, remaining %v < %v, safe: %v token: %v", proposal.file, remainBalance, proposal.total, safe.String(), opt.RewardToken)
+			}
+			remainBalance.Sub(remainBalance, proposal.total)
+			if remainValue != nil {
+				if remainValue.Cmp(proposal.total) < 0 {
+					return fmt.Errorf("proposal %v exceeds total value, remaining %v < %v", proposal.file, remainValue, proposal.total)
+				}
+				remainValue.Sub(remainValue, proposal.total)
+			}
+		}
+		proposals = append(proposals, fileProposals...)
+		grandTotal.Add(grandTotal, inputTotal)
+	}
+	if opt.TotalValue != nil && opt.TotalValue.Cmp(grandTotal) != 0 {
+		return fmt.Errorf("total reward of input files %v is not equal to total value %v", grandTotal, opt.TotalValue)
+	}
+	log.Info("check safe balance success", "safe", safe.String(), "token", opt.RewardToken, "balance", balance, "total", grandTotal)
+
+	for _, proposal := range proposals {
+		data, err := json.MarshalIndent(proposal.content, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(proposal.file, data, 0644); err != nil {
+			return err
+		}
+		log.Info("write safe batch proposal success", "file", proposal.file, "transfers", proposal.transfers, "total", proposal.total)
+	}
+	log.Info("build safe batch success", "safe", args.Safe, "format", args.Format, "files", len(opt.InputFiles), "proposals", len(proposals), "totalRewards", grandTotal)
+	return nil
+}
+
+func (opt *Option) scaleRewards(accountStats mongodb.AccountStatSlice) {
+	if opt.ScalingNumerator == nil {
+		return
+	}
+	for _, stat := range accountStats {
+		stat.Reward.Mul(stat.Reward, opt.ScalingNumerator)
+		if opt.ScalingDenominator != nil {
+			stat.Reward.Div(stat.Reward, opt.ScalingDenominator)
+		}
+	}
+}
+
+func (opt *Option) getSafeBalance(safe common.Address) (balance *big.Int, err error) {
+	if opt.RewardToken != "" {
+		balance, err = capi.GetTokenBalance(common.HexToAddress(opt.RewardToken), safe, nil)
+	} else {
+		balance, err = capi.GetCoinBalance(safe, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get safe balance failed, %v", err)
+	}
+	return balance, nil
+}
+
+// build proposals of one input file, the amounts decoded from the encoded
+// transfers and the dust rewards should cover the input total exactly
+func (opt *Option) buildSafeBatch(args *SafeBatchArgs, chainID *big.Int, accountStats mongodb.AccountStatSlice, inputTotal *big.Int, ifile, ofile string) ([]*safeBatchProposal, error) {
+	rewardToken := common.HexToAddress(opt.RewardToken)
+	dustRewardThreshold := params.GetDustRewardThreshold()
+	transfers := make([]*safeTransfer, 0, len(accountStats))
+	totalDustReward := big.NewInt(0)
+	totalDustRewardCount := 0
+	for _, stat := range accountStats {
+		if stat.Reward.Cmp(dustRewardThreshold) < 0 {
+			log.Info("safebatch ignore dust reward", "account", stat.Account.String(), "reward", stat.Reward, "dustRewardThreshold", dustRewardThreshold)
+			totalDustReward.Add(totalDustReward, stat.Reward)
+			totalDustRewardCount++
+			continue
+		}
+		transfers = append(transfers, newSafeTransfer(stat.Account, stat.Reward, rewardToken))
+	}
+
+	size := int(args.ProposalSize)
+	count := (len(transfers) + size - 1) / size
+	proposals := make([]*safeBatchProposal, 0, count)
+	proposalsTotal := big.NewInt(0)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(transfers) {
+			end = len(transfers)
+		}
+		batch := transfers[i*size : end]
+		total := big.NewInt(0)
+		for _, t := range batch {
+			total.Add(total, t.amount(rewardToken))
+		}
+		proposalsTotal.Add(proposalsTotal, total)
+
+		var content interface{}
+		if args.Format == SafeMultiSendFormat {
+			content = newSafeMultiSendProposal(args, chainID, batch, total)
+		} else {
+			content = newSafeTxBuilderBatch(args, chainID, batch, fmt.Sprintf("%v (%v/%v)", filepath.Base(ifile), i+1, count), total)
+		}
+		proposals = append(proposals, &safeBatchProposal{
+			file:      getSafeBatchFileName(ofile, i, count),
+			content:   content,
+			transfers: len(batch),
+			total:     total,
+		})
+	}
+
+	if new(big.Int).Add(proposalsTotal, totalDustReward).Cmp(inputTotal) != 0 {
+		return nil, fmt.Errorf("proposals total %v plus dust %v is not equal to input total %v of %v", proposalsTotal, totalDustReward, inputTotal, ifile)
+	}
+
+	log.Info("[buildSafeBatch] proposals built",
+		"input", ifile,
+		"proposals", count,
+		"proposalsTotal", proposalsTotal,
+		"transfers", len(transfers),
+		"totalDustReward", totalDustReward,
+		"totalDustRewardCount", totalDustRewardCount,
+	)
+	return proposals, nil
+}
+
+func newSafeTransfer(account common.Address, reward *big.Int, rewardToken common.Address) *safeTransfer {
+	if rewardToken == (common.Address{}) {
+		return &safeTransfer{to: account, value: reward}
+	}
+	data := make([]byte, 68)
+	copy(data[:4], transferFuncHash)
+	copy(data[4:36], account.Hash().Bytes())
+	copy(data[36:68], common.LeftPadBytes(reward.Bytes(), 32))
+	return &safeTransfer{to: rewardToken, value: big.NewInt(0), data: data}
+}
+
+// amount transfered, decoded from calldata if is token transfer
+func (t *safeTransfer) amount(rewardToken common.Address) *big.Int {
+	if rewardToken == (common.Address{}) {
+		return t.value
+	}
+	return new(big.Int).SetBytes(t.data[36:68])
+}
+
+func newSafeTxBuilderBatch(args *SafeBatchArgs, chainID *big.Int, batch []*safeTransfer, name string, total *big.Int) *SafeTxBuilderBatch {
+	txs := make([]*SafeTxBuilderTxDef, len(batch))
+	for i, t := range batch {
+		txs[i] = &SafeTxBuilderTxDef{
+			To:    t.to.String(),
+			Value: t.value.String(),
+			Data:  hexutil.Encode(t.data),
+		}
+	}
+	return &SafeTxBuilderBatch{
+		Version:   "1.0",
+		ChainID:   chainID.String(),
+		CreatedAt: time.Now().UnixNano() / int64(time.Millisecond),
+		Meta: &SafeTxBuilderMeta{
+			Name:                   name,
+			Description:            fmt.Sprintf("%v reward transfers, total %v", len(batch), total),
+			CreatedFromSafeAddress: common.HexToAddress(args.Safe).String(),
+		},
+		Transactions: txs,
+	}
+}
+
+// encode as MultiSend 'transactions' packed bytes:
+// operation (uint8) + to (address) + value (uint256) + data length (uint256) + data
+func newSafeMultiSendProposal(args *SafeBatchArgs, chainID *big.Int, batch []*safeTransfer, total *big.Int) *SafeMultiSendProposal {
+	var packed []byte
+	for _, t := range batch {
+		packed = append(packed, safeCallOperation)
+		packed = append(packed, t.to.Bytes()...)
+		packed = append(packed, common.LeftPadBytes(t.value.Bytes(), 32)...)
+		packed = append(packed, common.LeftPadBytes(big.NewInt(int64(len(t.data))).Bytes(), 32)...)
+		packed = append(packed, t.data...)
+	}
+	// abi encode multiSend(bytes)
+	data := make([]byte, 0, 4+64+len(packed)+32)
+	data = append(data, multiSendFuncHash...)
+	data = append(data, common.LeftPadBytes(big.NewInt(32).Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(big.NewInt(int64(len(packed))).Bytes(), 32)...)
+	data = append(data, packed...)
+	if padding := len(packed) % 32; padding != 0 {
+		data = append(data, make([]byte, 32-padding)...)
+	}
+	return &SafeMultiSendProposal{
+		Safe:      common.HexToAddress(args.Safe).String(),
+		ChainID:   chainID.String(),
+		To:        common.HexToAddress(args.MultiSend).String(),
+		Value:     "0",
+		Data:      data,
+		Operation: safeDelegateCallOperation,
+		Transfers: len(batch),
+		Total:     total,
+	}
+}
+
+// insert index before extension if there are multiple proposals
+func getSafeBatchFileName(fileName string, index, count int) string {
+	if count <= 1 {
+		return fileName
+	}
+	ext := filepath.Ext(fileName)
+	return fmt.Sprintf("%v-%d%v", strings.TrimSuffix(fileName, ext), index+1, ext)
+}
@@ -3,15 +3,13 @@ package distributer
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/big"
 	"strings"
-	"time"
 
 	"github.com/anyswap/ANYToken-distribution/log"
 	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/notify"
 	"github.com/anyswap/ANYToken-distribution/params"
-	"github.com/fsn-dev/fsn-go-sdk/efsn/accounts/keystore"
 	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
 	"github.com/fsn-dev/fsn-go-sdk/efsn/core/types"
 )
@@ -25,6 +23,8 @@ var (
 // BuildTxArgs build tx args
 type BuildTxArgs struct {
 	Sender       string
+	SignerType   string
+	SignerURL    string
 	KeystoreFile string `json:"-"`
 	PasswordFile string `json:"-"`
 
@@ -32,14 +32,16 @@ type BuildTxArgs struct {
 	GasLimit *uint64
 	GasPrice *big.Int
 
+	// max count of pipelined in-flight transactions, 0 means no limit
+	MaxInflight uint64
+
 	// calculated result
-	keyWrapper  *keystore.Key
-	fromAddr    common.Address
-	chainID     *big.Int
-	chainSigner types.Signer
+	signer   Signer
+	fromAddr common.Address
+	chainID  *big.Int
 }
 
-// GetSender get sender from keystore
+// GetSender get sender from signer
 func (args *BuildTxArgs) GetSender() common.Address {
 	return args.fromAddr
 }
@@ -55,12 +57,12 @@ func (args *BuildTxArgs) Check(dryRun bool) error {
 		return fmt.Errorf("wrong sender address '%v'", args.Sender)
 	}
 	if !dryRun {
-		err := args.loadKeyStore()
+		err := args.loadSigner()
 		if err != nil {
 			return err
 		}
 		if !strings.EqualFold(args.Sender, args.fromAddr.String()) {
-			return fmt.Errorf("sender mismatch. sender from args = '%v', sender from keystore = '%v'", args.Sender, args.fromAddr.String())
+			return fmt.Errorf("sender mismatch. sender from args = '%v', sender from signer = '%v'", args.Sender, args.fromAddr.String())
 		}
 	} else {
 		if args.Sender != "" {
@@ -74,29 +76,12 @@ func (args *BuildTxArgs) Check(dryRun bool) error {
 	return nil
 }
 
-func (args *BuildTxArgs) loadKeyStore() error {
-	keyfile := args.KeystoreFile
-	passfile := args.PasswordFile
-	keyjson, err := ioutil.ReadFile(keyfile)
-	if err != nil {
-		log.Println("read keystore fail", err)
-		return err
-	}
-
-	passdata, err := ioutil.ReadFile(passfile)
-	if err != nil {
-		log.Println("read password fail", err)
-		return err
-	}
-	passwd := strings.TrimSpace(string(passdata))
-
-	log.Println("decrypt keystore ......")
-	args.keyWrapper, err = keystore.DecryptKey(keyjson, passwd)
+func (args *BuildTxArgs) loadSigner() (err error) {
+	args.signer, err = NewSigner(args.SignerType, args.SignerURL, args.Sender, args.KeystoreFile, args.PasswordFile)
 	if err != nil {
-		log.Println("key decrypt fail", err)
 		return err
 	}
-	args.fromAddr = args.keyWrapper.Address
+	args.fromAddr = args.signer.Address()
 	if args.Sender == "" {
 		args.Sender = args.fromAddr.String()
 	}
@@ -113,7 +98,6 @@ func (args *BuildTxArgs) setDefaults() {
 				log.Warn("get chain ID error", "err", err)
 				continue
 			}
-			args.chainSigner = types.NewEIP155Signer(args.chainID)
 		}
 		log.Info("get chain ID succeed", "chainID", args.chainID)
 		if args.Nonce == nil {
@@ -154,34 +138,30 @@ func (args *BuildTxArgs) sendRewardsTransaction(account common.Address, reward *
 		return nil, nil
 	}
 
-	nonce, err := capi.GetAccountNonce(args.fromAddr)
-	if err == nil && nonce > *args.Nonce {
-		*args.Nonce = nonce
+	nonceManager, err := args.getNonceManager()
+	if err != nil {
+		return nil, err
 	}
+	nonce := nonceManager.AcquireNonce()
 
-	var rawTx *types.Transaction
-
-	if rewardToken != (common.Address{}) {
-		data := make([]byte, 68)
-		copy(data[:4], transferFuncHash)
-		copy(data[4:36], account.Hash().Bytes())
-		copy(data[36:68], common.LeftPadBytes(reward.Bytes(), 32))
-
-		rawTx = types.NewTransaction(*args.Nonce, rewardToken, big.NewInt(0), *args.GasLimit, args.GasPrice, data)
-	} else {
-		rawTx = types.NewTransaction(*args.Nonce, account, reward, *args.GasLimit, args.GasPrice, nil)
-	}
+	rawTx := args.buildRewardsTransaction(nonce, account, reward, rewardToken)
 
-	signedTx, err := types.SignTx(rawTx, args.chainSigner, args.keyWrapper.PrivateKey)
+	signedTx, err := args.signTransaction(rawTx)
 	if err != nil {
-		return nil, fmt.Errorf("sign tx failed, %v", err)
+		nonceManager.Release(nonce)
+		return nil, err
 	}
 
 	err = capi.SendTransaction(signedTx)
 	if err != nil {
-		return nil, fmt.Errorf("send tx failed, %v", err)
+		if !nonceManager.ReleaseSent(signedTx) {
+			return nil, fmt.Errorf("send tx failed, %v", err)
+		}
+		log.Warn("sendRewards tx is accepted though sending failed", "account", account.String(), "txHash", signedTx.Hash().String(), "err", err)
+	} else {
+		nonceManager.Commit(signedTx)
 	}
-	*args.Nonce++
+	*args.Nonce = nonce + 1
 
 	signedTxHash := signedTx.Hash()
 	txHash = &signedTxHash
@@ -189,52 +169,88 @@ func (args *BuildTxArgs) sendRewardsTransaction(account common.Address, reward *
 	return txHash, nil
 }
 
+// build unsigned reward transaction
+func (args *BuildTxArgs) buildRewardsTransaction(nonce uint64, account common.Address, reward *big.Int, rewardToken common.Address) *types.Transaction {
+	if rewardToken != (common.Address{}) {
+		data := make([]byte, 68)
+		copy(data[:4], transferFuncHash)
+		copy(data[4:36], account.Hash().Bytes())
+		copy(data[36:68], common.LeftPadBytes(reward.Bytes(), 32))
+
+		return types.NewTransaction(nonce, rewardToken, big.NewInt(0), *args.GasLimit, args.GasPrice, data)
+	}
+	return types.NewTransaction(nonce, account, reward, *args.GasLimit, args.GasPrice, nil)
+}
+
+func (args *BuildTxArgs) signTransaction(rawTx *types.Transaction) (*types.Transaction, error) {
+	signedTx, err := args.signer.SignTx(rawTx, args.chainID)
+	if err != nil {
+		return nil, fmt.Errorf("sign tx failed, %v", err)
+	}
+	return signedTx, nil
+}
+
 func (opt *Option) checkSendRewardsFromFile(ifile string) (mongodb.AccountStatSlice, error) {
-	accountStats, _, err := GetAccountsAndRewardsFromFile(ifile)
+	accountStats, titleLine, err := GetAccountsAndRewardsFromFile(ifile)
 	if err != nil {
 		log.Error("[sendRewards] get accounts and rewards from input file failed", "inputfile", ifile, "err", err)
 		return nil, err
 	}
+	// rewards of program are limited and recorded as the program
+	if title, errt := ParseRewardTitleLine(titleLine); errt == nil && title.Program != opt.Program {
+		if opt.Program != "" {
+			return nil, fmt.Errorf("program %v of input file %v mismatch with %v", title.Program, ifile, opt.Program)
+		}
+		opt.Program = title.Program
+	}
 	if len(accountStats) == 0 {
 		log.Warn("empty account list, no need to send reward")
 		return nil, nil
 	}
 
 	// scaling reward value
-	if opt.ScalingNumerator != nil {
-		for _, stat := range accountStats {
-			stat.Reward.Mul(stat.Reward, opt.ScalingNumerator)
-			if opt.ScalingDenominator != nil {
-				stat.Reward.Div(stat.Reward, opt.ScalingDenominator)
-			}
-		}
-	}
+	opt.scaleRewards(accountStats)
 
 	// assign total value before check balance
 	opt.TotalValue = accountStats.CalcTotalReward()
-	if opt.RewardToken != "" {
-		err = opt.CheckSenderRewardTokenBalance()
-	} else {
-		err = opt.CheckSenderCoinBalance()
-	}
-	if err != nil {
+	if err = opt.checkSenderBalance(); err != nil {
 		return nil, err
 	}
 
 	return accountStats, nil
 }
 
+func (opt *Option) checkSenderBalance() error {
+	if opt.RewardToken != "" {
+		return opt.CheckSenderRewardTokenBalance()
+	}
+	return opt.CheckSenderCoinBalance()
+}
+
 // SendRewardsFromFile send rewards from file
 func (opt *Option) SendRewardsFromFile() (err error) {
-	if len(opt.Exchanges) != 0 {
-		if len(opt.InputFiles) != len(opt.Exchanges) {
-			return fmt.Errorf("count of exchanges and input files is not equal")
+	if err = opt.checkInputAndOutputFiles(); err != nil {
+		return err
+	}
+
+	// spending limits are checked on all input files before sending the first transaction
+	allStats := make([]mongodb.AccountStatSlice, len(opt.InputFiles))
+	allTotal := big.NewInt(0)
+	for i, inputFile := range opt.InputFiles {
+		allStats[i], err = opt.checkSendRewardsFromFile(inputFile)
+		if err != nil {
+			return err
 		}
-		if len(opt.OutputFiles) != len(opt.Exchanges) {
-			return fmt.Errorf("count of exchanges and output files is not equal")
+		allTotal.Add(allTotal, allStats[i].CalcTotalReward())
+	}
+	if len(opt.InputFiles) > 1 {
+		opt.TotalValue = allTotal
+		if err = opt.checkSenderBalance(); err != nil {
+			return err
 		}
-	} else if len(opt.InputFiles) != len(opt.OutputFiles) {
-		return fmt.Errorf("count of input and output files is not equal")
+	}
+	if err = opt.checkSpendingLimits(allStats...); err != nil {
+		return err
 	}
 
 	totalRewardsSended := big.NewInt(0)
@@ -246,7 +262,7 @@ func (opt *Option) SendRewardsFromFile() (err error) {
 			exchange = opt.Exchanges[i]
 		}
 		outputFile := opt.OutputFiles[i]
-		rewardsSended, err = opt.sendRewardsFromFile(exchange, inputFile, outputFile)
+		rewardsSended, err = opt.sendRewardsFromFile(exchange, allStats[i], inputFile, outputFile)
 		if rewardsSended != nil {
 			totalRewardsSended.Add(totalRewardsSended, rewardsSended)
 		}
@@ -259,11 +275,8 @@ func (opt *Option) SendRewardsFromFile() (err error) {
 	return err
 }
 
-func (opt *Option) sendRewardsFromFile(exchange, ifile, ofile string) (rewardsSended *big.Int, err error) {
-	accountStats, err := opt.checkSendRewardsFromFile(ifile)
-	if err != nil {
-		return nil, err
-	}
+func (opt *Option) sendRewardsFromFile(exchange string, accountStats mongodb.AccountStatSlice, ifile, ofile string) (rewardsSended *big.Int, err error) {
+	opt.TotalValue = accountStats.CalcTotalReward()
 	outputFile, err := openOutputFile(ofile)
 	if err != nil {
 		return nil, err
@@ -291,6 +304,11 @@ func (opt *Option) sendRewardsFromFile(exchange, ifile, ofile string) (rewardsSe
 			totalDustRewardCount++
 		default:
 			log.Error("[sendRewardsFromFile] send tx failed", "account", account.String(), "reward", reward, "dryrun", opt.DryRun, "err", err)
+			failedPayoutCounter.Inc(opt.byWhat)
+			observeCycleRewards(opt.byWhat, exchange, opt.TotalValue, rewardsSended, totalDustReward, totalDustRewardCount)
+			notify.Notify(notify.EventTransferFailed, "send reward transaction failed",
+				"bywhat", opt.byWhat, "exchange", exchange, "input", ifile,
+				"account", account.String(), "reward", reward, "err", err)
 			return rewardsSended, errSendTransactionFailed
 		}
 		rewardsSended.Add(rewardsSended, reward)
@@ -299,11 +317,12 @@ func (opt *Option) sendRewardsFromFile(exchange, ifile, ofile string) (rewardsSe
 			_ = opt.WriteSendRewardResult(outputFile, exchange, stat, txHash)
 			i++
 		}
-		if !opt.DryRun && opt.BatchCount > 0 && i%opt.BatchCount == 0 {
-			time.Sleep(time.Duration(opt.BatchInterval) * time.Millisecond)
-		}
+		opt.sleepAfterBatch(i)
 	}
 
+	observeCycleRewards(opt.byWhat, exchange, opt.TotalValue, rewardsSended, totalDustReward, totalDustRewardCount)
+	opt.notifyRewardsSent(exchange, rewardsSended, totalDustReward, totalDustRewardCount)
+
 	log.Info("[sendRewardsFromFile] rewards sended",
 		"exchange", exchange,
 		"totalRewards", opt.TotalValue,
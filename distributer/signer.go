@@ -0,0 +1,251 @@
+package distributer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/accounts/keystore"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common/hexutil"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/core/types"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/rlp"
+)
+
+// signer types
+const (
+	KeystoreSignerType = "keystore"
+	ClefSignerType     = "clef"
+	HTTPSignerType     = "http"
+)
+
+const remoteSignerTimeout = 60 * time.Second
+
+// Signer sign transactions of sender
+type Signer interface {
+	Address() common.Address
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// NewSigner new signer of specified type
+func NewSigner(signerType, signerURL, sender, keyfile, passfile string) (Signer, error) {
+	switch signerType {
+	case "", KeystoreSignerType:
+		return NewKeystoreSigner(keyfile, passfile)
+	case ClefSignerType, HTTPSignerType:
+		return NewRemoteSigner(signerType, signerURL, sender)
+	default:
+		return nil, fmt.Errorf("unknown signer type '%v'", signerType)
+	}
+}
+
+// KeystoreSigner sign with decrypted keystore
+type KeystoreSigner struct {
+	key *keystore.Key
+}
+
+// NewKeystoreSigner new keystore signer
+func NewKeystoreSigner(keyfile, passfile string) (*KeystoreSigner, error) {
+	keyjson, err := ioutil.ReadFile(keyfile)
+	if err != nil {
+		log.Println("read keystore fail", err)
+		return nil, err
+	}
+
+	passdata, err := ioutil.ReadFile(passfile)
+	if err != nil {
+		log.Println("read password fail", err)
+		return nil, err
+	}
+	passwd := strings.TrimSpace(string(passdata))
+
+	log.Println("decrypt keystore ......")
+	key, err := keystore.DecryptKey(keyjson, passwd)
+	if err != nil {
+		log.Println("key decrypt fail", err)
+		return nil, err
+	}
+	return &KeystoreSigner{key: key}, nil
+}
+
+// Address signer address
+func (s *KeystoreSigner) Address() common.Address {
+	return s.key.Address
+}
+
+// SignTx sign tx
+func (s *KeystoreSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.NewEIP155Signer(chainID), s.key.PrivateKey)
+}
+
+// RemoteSigner sign by external signing service, key is never loaded in process.
+//
+// clef type calls JSON-RPC method 'account_signTransaction' and uses 'raw' in result.
+// http type posts the same transaction args as json to URL, and expects response
+// json '{"raw":"0x..."}' where 'raw' is the RLP encoded signed transaction.
+type RemoteSigner struct {
+	signerType string
+	url        string
+	address    common.Address
+	client     *http.Client
+}
+
+// NewRemoteSigner new remote signer
+func NewRemoteSigner(signerType, signerURL, sender string) (*RemoteSigner, error) {
+	if signerURL == "" {
+		return nil, fmt.Errorf("empty %v signer URL", signerType)
+	}
+	if !common.IsHexAddress(sender) {
+		return nil, fmt.Errorf("remote signer requires sender, but get '%v'", sender)
+	}
+	s := &RemoteSigner{
+		signerType: signerType,
+		url:        signerURL,
+		address:    common.HexToAddress(sender),
+		client:     &http.Client{Timeout: remoteSignerTimeout},
+	}
+	if signerType == ClefSignerType {
+		if err := s.checkClefAccount(); err != nil {
+			return nil, err
+		}
+	}
+	log.Info("init remote signer success", "type", signerType, "url", signerURL, "sender", sender)
+	return s, nil
+}
+
+// Address signer address
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+type signTxArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice hexutil.Big     `json:"gasPrice"`
+	Value    hexutil.Big     `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     hexutil.Bytes   `json:"data"`
+	ChainID  *hexutil.Big    `json:"chainId"`
+}
+
+type signTxResult struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+type jsonrpcRequest struct {
+	Version string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type jsonrpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// SignTx sign tx
+func (s *RemoteSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := &signTxArgs{
+		From:     s.address,
+		To:       tx.To(),
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: hexutil.Big(*tx.GasPrice()),
+		Value:    hexutil.Big(*tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     tx.Data(),
+		ChainID:  (*hexutil.Big)(chainID),
+	}
+
+	var result signTxResult
+	var err error
+	if s.signerType == ClefSignerType {
+		err = s.callClef(&result, "account_signTransaction", args)
+	} else {
+		err = s.post(s.url, args, &result)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx := new(types.Transaction)
+	if err = rlp.DecodeBytes(result.Raw, signedTx); err != nil {
+		return nil, fmt.Errorf("decode remote signed tx failed, %v", err)
+	}
+	// never trust remote signer, check what is really signed
+	chainSigner := types.NewEIP155Signer(chainID)
+	if chainSigner.Hash(signedTx) != chainSigner.Hash(tx) {
+		return nil, fmt.Errorf("remote signed tx mismatch")
+	}
+	signedBy, err := types.Sender(chainSigner, signedTx)
+	if err != nil {
+		return nil, fmt.Errorf("recover remote signed tx sender failed, %v", err)
+	}
+	if signedBy != s.address {
+		return nil, fmt.Errorf("remote signed tx sender mismatch, %v != %v", signedBy.String(), s.address.String())
+	}
+	return signedTx, nil
+}
+
+func (s *RemoteSigner) checkClefAccount() error {
+	var accounts []common.Address
+	if err := s.callClef(&accounts, "account_list"); err != nil {
+		return fmt.Errorf("list clef accounts failed, %v", err)
+	}
+	for _, account := range accounts {
+		if account == s.address {
+			return nil
+		}
+	}
+	return fmt.Errorf("sender %v is not managed by clef signer", s.address.String())
+}
+
+func (s *RemoteSigner) callClef(result interface{}, method string, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	req := &jsonrpcRequest{
+		Version: "2.0",
+		ID:      1,
+		Method:  method,
+		Params:  params,
+	}
+	var resp jsonrpcResponse
+	if err := s.post(s.url, req, &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("call %v failed, code %v, %v", method, resp.Error.Code, resp.Error.Message)
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+func (s *RemoteSigner) post(url string, args, result interface{}) error {
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("remote signer response status %v, %v", resp.Status, string(body))
+	}
+	return json.Unmarshal(body, result)
+}
@@ -0,0 +1,317 @@
+package distributer
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/anyswap/ANYToken-distribution/callapi"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common/hexutil"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/core/types"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/crypto"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/rlp"
+)
+
+const (
+	testChainID      = 32659
+	testAccountNonce = 5
+	testGasPrice     = 1000000000
+)
+
+// nodeStandIn answers the JSON-RPC calls of sending a transaction,
+// and records the raw transactions sent to it.
+type nodeStandIn struct {
+	*httptest.Server
+
+	lock sync.Mutex
+	sent []*types.Transaction
+
+	// accept sent transactions but answer with error, like a timeout
+	failAccepted bool
+}
+
+func newNodeStandIn(t *testing.T) *nodeStandIn {
+	node := &nodeStandIn{}
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode node request failed: %v", err)
+			return
+		}
+		var result interface{}
+		switch req.Method {
+		case "eth_getBlockByNumber":
+			result = &types.Header{
+				Difficulty: big.NewInt(1),
+				Number:     big.NewInt(100),
+				Time:       big.NewInt(1600000000),
+			}
+		case "net_version":
+			result = big.NewInt(testChainID).String()
+		case "eth_getTransactionCount":
+			result = hexutil.Uint64(node.txCount())
+		case "eth_gasPrice":
+			result = (*hexutil.Big)(big.NewInt(testGasPrice))
+		case "eth_sendRawTransaction":
+			var raw hexutil.Bytes
+			if err := json.Unmarshal(req.Params[0], &raw); err != nil {
+				t.Errorf("decode raw transaction failed: %v", err)
+				return
+			}
+			tx := new(types.Transaction)
+			if err := rlp.DecodeBytes(raw, tx); err != nil {
+				t.Errorf("decode raw transaction failed: %v", err)
+				return
+			}
+			node.lock.Lock()
+			node.sent = append(node.sent, tx)
+			failAccepted := node.failAccepted
+			node.lock.Unlock()
+			if failAccepted {
+				writeJSONRPCError(w, req.ID, "already known")
+				return
+			}
+			result = tx.Hash()
+		case "eth_getTransactionByHash":
+			var txHash common.Hash
+			if err := json.Unmarshal(req.Params[0], &txHash); err != nil {
+				t.Errorf("decode tx hash failed: %v", err)
+				return
+			}
+			result = node.findTx(txHash)
+		default:
+			t.Errorf("unexpected node method %v", req.Method)
+			return
+		}
+		writeJSONRPCResult(t, w, req.ID, result)
+	}))
+	return node
+}
+
+// sent transactions are pending, so the pending nonce is the same as the
+// confirmed one plus the number of sent transactions
+func (node *nodeStandIn) txCount() uint64 {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	return testAccountNonce + uint64(len(node.sent))
+}
+
+func (node *nodeStandIn) findTx(txHash common.Hash) *types.Transaction {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	for _, tx := range node.sent {
+		if tx.Hash() == txHash {
+			return tx
+		}
+	}
+	return nil
+}
+
+func (node *nodeStandIn) setFailAccepted(failAccepted bool) {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	node.failAccepted = failAccepted
+}
+
+func (node *nodeStandIn) sentTxs() []*types.Transaction {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	return append([]*types.Transaction(nil), node.sent...)
+}
+
+// newSignerStandIn is a http type remote signer signing with key
+func newSignerStandIn(t *testing.T, key *ecdsa.PrivateKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var args signTxArgs
+		if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+			t.Errorf("decode sign request failed: %v", err)
+			return
+		}
+		tx := types.NewTransaction(uint64(args.Nonce), *args.To, args.Value.ToInt(), uint64(args.Gas), args.GasPrice.ToInt(), args.Data)
+		signedTx, err := types.SignTx(tx, types.NewEIP155Signer(args.ChainID.ToInt()), key)
+		if err != nil {
+			t.Errorf("sign tx failed: %v", err)
+			return
+		}
+		raw, err := rlp.EncodeToBytes(signedTx)
+		if err != nil {
+			t.Errorf("encode signed tx failed: %v", err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&signTxResult{Raw: raw})
+	}))
+}
+
+func writeJSONRPCResult(t *testing.T, w http.ResponseWriter, id json.RawMessage, result interface{}) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Errorf("encode node result failed: %v", err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"result":  json.RawMessage(data),
+	})
+}
+
+func writeJSONRPCError(w http.ResponseWriter, id json.RawMessage, message string) {
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   map[string]interface{}{"code": -32000, "message": message},
+	})
+}
+
+func useNodeStandIn(t *testing.T) *nodeStandIn {
+	node := newNodeStandIn(t)
+	oldCapi := capi
+	capi = callapi.NewDefaultAPICaller()
+	if err := capi.DialServer(node.URL); err != nil {
+		t.Fatalf("dial node stand-in failed: %v", err)
+	}
+	t.Cleanup(func() {
+		capi.CloseClient()
+		capi = oldCapi
+		node.Close()
+	})
+	return node
+}
+
+func newRemoteSignerArgs(t *testing.T, sender common.Address, signerURL string) *BuildTxArgs {
+	args := &BuildTxArgs{
+		Sender:     sender.String(),
+		SignerType: HTTPSignerType,
+		SignerURL:  signerURL,
+	}
+	if err := args.Check(false); err != nil {
+		t.Fatalf("check build tx args failed: %v", err)
+	}
+	t.Cleanup(func() {
+		nonceManagersLock.Lock()
+		delete(nonceManagers, sender)
+		nonceManagersLock.Unlock()
+	})
+	return args
+}
+
+func TestSendRewardsWithRemoteSigner(t *testing.T) {
+	node := useNodeStandIn(t)
+
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	signer := newSignerStandIn(t, key)
+	defer signer.Close()
+
+	args := newRemoteSignerArgs(t, sender, signer.URL)
+
+	account := common.HexToAddress("0x0000000000000000000000000000000000000123")
+	rewardToken := common.HexToAddress("0xC20b5E92E1ce63Af6FE537491f75C19016ea5fb4")
+	reward := big.NewInt(1234567)
+	txHash, err := args.sendRewardsTransaction(account, reward, rewardToken, false)
+	if err != nil {
+		t.Fatalf("send rewards failed: %v", err)
+	}
+
+	sent := node.sentTxs()
+	if len(sent) != 1 {
+		t.Fatalf("want 1 sent transaction, got %v", len(sent))
+	}
+	tx := sent[0]
+	if tx.Hash() != *txHash {
+		t.Errorf("sent tx hash %v, returned %v", tx.Hash().String(), txHash.String())
+	}
+	if tx.Nonce() != testAccountNonce {
+		t.Errorf("want nonce %v, got %v", testAccountNonce, tx.Nonce())
+	}
+	if tx.To() == nil || *tx.To() != rewardToken {
+		t.Errorf("want tx to reward token %v, got %v", rewardToken.String(), tx.To())
+	}
+	if tx.GasPrice().Cmp(big.NewInt(testGasPrice)) != 0 {
+		t.Errorf("want gas price %v, got %v", testGasPrice, tx.GasPrice())
+	}
+	if tx.Value().Sign() != 0 {
+		t.Errorf("want zero value, got %v", tx.Value())
+	}
+	wantData := append(append(append([]byte{}, transferFuncHash...), account.Hash().Bytes()...), common.LeftPadBytes(reward.Bytes(), 32)...)
+	if !bytes.Equal(tx.Data(), wantData) {
+		t.Errorf("want tx data %x, got %x", wantData, tx.Data())
+	}
+	signedBy, err := types.Sender(types.NewEIP155Signer(big.NewInt(testChainID)), tx)
+	if err != nil {
+		t.Fatalf("recover sender failed: %v", err)
+	}
+	if signedBy != sender {
+		t.Errorf("want signed by %v, got %v", sender.String(), signedBy.String())
+	}
+
+	// next transaction uses next nonce
+	if _, err = args.sendRewardsTransaction(account, reward, rewardToken, false); err != nil {
+		t.Fatalf("send second rewards failed: %v", err)
+	}
+	if sent = node.sentTxs(); len(sent) != 2 || sent[1].Nonce() != testAccountNonce+1 {
+		t.Errorf("want second tx with nonce %v, got %v transactions", testAccountNonce+1, len(sent))
+	}
+}
+
+func TestSendRewardsRejectsWrongRemoteSignature(t *testing.T) {
+	node := useNodeStandIn(t)
+
+	key, _ := crypto.GenerateKey()
+	otherKey, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	signer := newSignerStandIn(t, otherKey)
+	defer signer.Close()
+
+	args := newRemoteSignerArgs(t, sender, signer.URL)
+
+	account := common.HexToAddress("0x0000000000000000000000000000000000000123")
+	_, err := args.sendRewardsTransaction(account, big.NewInt(1000), common.Address{}, false)
+	if err == nil {
+		t.Fatal("want error of signature by other key")
+	}
+	if sent := node.sentTxs(); len(sent) != 0 {
+		t.Errorf("want no sent transaction, got %v", len(sent))
+	}
+}
+
+func TestSendRewardsAcceptedThoughSendingFailed(t *testing.T) {
+	node := useNodeStandIn(t)
+
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	signer := newSignerStandIn(t, key)
+	defer signer.Close()
+
+	args := newRemoteSignerArgs(t, sender, signer.URL)
+	account := common.HexToAddress("0x0000000000000000000000000000000000000123")
+
+	node.setFailAccepted(true)
+	txHash, err := args.sendRewardsTransaction(account, big.NewInt(1000), common.Address{}, false)
+	if err != nil {
+		t.Fatalf("want accepted transaction treated as sent, got error %v", err)
+	}
+	if txHash == nil || node.findTx(*txHash) == nil {
+		t.Fatalf("want hash of accepted transaction, got %v", txHash)
+	}
+
+	// nonce of accepted transaction must not be reused
+	node.setFailAccepted(false)
+	if _, err = args.sendRewardsTransaction(account, big.NewInt(1000), common.Address{}, false); err != nil {
+		t.Fatalf("send second rewards failed: %v", err)
+	}
+	sent := node.sentTxs()
+	if len(sent) != 2 || sent[0].Nonce() != testAccountNonce || sent[1].Nonce() != testAccountNonce+1 {
+		t.Errorf("want nonces %v and %v, got %v transactions", testAccountNonce, testAccountNonce+1, len(sent))
+	}
+}
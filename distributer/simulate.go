@@ -0,0 +1,322 @@
+package distributer
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+// SimulateArgs simulate args, overridden params are applied on config.
+// stake points and dust threshold are overridden in config directly.
+type SimulateArgs struct {
+	ByWhat          string
+	Start           uint64 // start of first cycle
+	End             uint64 // end of last cycle
+	Exchanges       []string
+	Weights         []uint64
+	IsPercentage    *bool
+	TotalReward     *big.Int // liquidity rewards per cycle
+	StepReward      *big.Int // volume rewards per step
+	ChangeThreshold uint64   // percentage
+	OutputFile      string
+}
+
+// SimulateResult simulate result of an exchange or all exchanges
+type SimulateResult struct {
+	Exchange       string
+	Accounts       int
+	ActualTotal    *big.Int
+	SimulatedTotal *big.Int
+	ActualGini     float64
+	SimulatedGini  float64
+	ChangedCount   int // accounts whose reward changes beyond threshold
+}
+
+type simulateAccountKey struct {
+	exchange string
+	account  common.Address
+}
+
+type simulateRewards struct {
+	actual    *big.Int
+	simulated *big.Int
+}
+
+// Simulate replay past cycles with overridden params, compare with recorded rewards.
+// it never sends any transaction.
+func Simulate(args *SimulateArgs) (results []*SimulateResult, err error) {
+	distCfg := params.GetConfig().Distribute
+	if distCfg == nil {
+		return nil, fmt.Errorf("no distribute config")
+	}
+	cycleLen := distCfg.ByLiquidCycle
+	if distCfg.UseTimeMeasurement {
+		cycleLen = distCfg.ByLiquidCycleDuration
+	}
+	if cycleLen == 0 || args.Start >= args.End || (args.End-args.Start)%cycleLen != 0 {
+		return nil, fmt.Errorf("range [%v, %v) is not intergral multiple of cycle length %v", args.Start, args.End, cycleLen)
+	}
+	if len(args.Exchanges) != len(args.Weights) {
+		return nil, fmt.Errorf("count of exchanges %v != count of weights %v", len(args.Exchanges), len(args.Weights))
+	}
+
+	outputFile, err := os.OpenFile(args.OutputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer outputFile.Close()
+	_ = WriteOutput(outputFile, "#start", "exchange", "account", "actual", "simulated", "diff", "changePercent")
+
+	allRewards := make(map[simulateAccountKey]*simulateRewards)
+	for start := args.Start; start < args.End; start += cycleLen {
+		cycleRewards, err := simulateCycle(args, start, start+cycleLen)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range sortedSimulateKeys(cycleRewards) {
+			rewards := cycleRewards[key]
+			diff := new(big.Int).Sub(rewards.simulated, rewards.actual)
+			_ = WriteOutput(outputFile, fmt.Sprint(start), key.exchange, strings.ToLower(key.account.String()),
+				rewards.actual.String(), rewards.simulated.String(), diff.String(), formatChangePercent(rewards))
+			if total, exist := allRewards[key]; exist {
+				total.actual.Add(total.actual, rewards.actual)
+				total.simulated.Add(total.simulated, rewards.simulated)
+			} else {
+				allRewards[key] = rewards
+			}
+		}
+	}
+
+	exchanges := make(map[string]struct{})
+	for key := range allRewards {
+		exchanges[key.exchange] = struct{}{}
+	}
+	exchangeList := make([]string, 0, len(exchanges))
+	for exchange := range exchanges {
+		exchangeList = append(exchangeList, exchange)
+	}
+	sort.Strings(exchangeList)
+	for _, exchange := range exchangeList {
+		results = append(results, summarizeSimulation(exchange, allRewards, args.ChangeThreshold))
+	}
+	results = append(results, summarizeSimulation("", allRewards, args.ChangeThreshold))
+	return results, nil
+}
+
+func simulateCycle(args *SimulateArgs, start, end uint64) (map[simulateAccountKey]*simulateRewards, error) {
+	byWhat := GetStandardByWhat(args.ByWhat)
+	var sampleHeight uint64
+	if byWhat == byLiquidMethodID {
+		info, err := mongodb.FindDistributeInfo("", byWhat, start)
+		if err != nil {
+			log.Warn("[simulate] ignore cycle without recorded sample height", "start", start, "end", end)
+			return nil, nil
+		}
+		sampleHeight = info.SampleHeight
+	}
+	opt, err := newCycleOption(byWhat, start, end, args.TotalReward, sampleHeight, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(args.Exchanges) != 0 {
+		opt.Exchanges = args.Exchanges
+		opt.Weights = args.Weights
+	}
+	if args.IsPercentage != nil {
+		opt.WeightIsPercentage = *args.IsPercentage
+	}
+	if byWhat == byVolumeMethodID && args.StepReward != nil {
+		opt.StepReward = args.StepReward
+		if args.TotalReward == nil {
+			opt.TotalValue = opt.getConfigedCycleRewards(params.GetConfig().Distribute)
+		}
+	}
+	if err = opt.CheckBasic(); err != nil {
+		return nil, err
+	}
+	if err = opt.checkWeights(); err != nil {
+		return nil, err
+	}
+
+	var accountStats []mongodb.AccountStatSlice
+	switch opt.byWhat {
+	case byLiquidMethodID:
+		accountStats, err = opt.calcLiquidityRewards()
+	case byVolumeMethodID:
+		accountStats, err = opt.calcVolumeRewards()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cycleRewards := make(map[simulateAccountKey]*simulateRewards)
+	getRewards := func(exchange string, account common.Address) *simulateRewards {
+		key := simulateAccountKey{exchange: strings.ToLower(exchange), account: account}
+		rewards, exist := cycleRewards[key]
+		if !exist {
+			rewards = &simulateRewards{actual: big.NewInt(0), simulated: big.NewInt(0)}
+			cycleRewards[key] = rewards
+		}
+		return rewards
+	}
+	dustRewardThreshold := params.GetDustRewardThreshold()
+	for i, stats := range accountStats {
+		for _, stat := range stats {
+			if stat.Reward == nil || stat.Reward.Cmp(dustRewardThreshold) < 0 {
+				continue // dust rewards are not sent
+			}
+			rewards := getRewards(opt.Exchanges[i], stat.Account)
+			rewards.simulated.Add(rewards.simulated, stat.Reward)
+		}
+	}
+	err = findRecordedRewards(byWhat, start, func(exchange, account, rewardStr string) {
+		reward, _ := tools.GetBigIntFromString(rewardStr)
+		if reward != nil && common.IsHexAddress(account) {
+			rewards := getRewards(exchange, common.HexToAddress(account))
+			rewards.actual.Add(rewards.actual, reward)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info("[simulate] simulate cycle success", "bywhat", byWhat, "start", start, "end", end, "accounts", len(cycleRewards))
+	return cycleRewards, nil
+}
+
+func findRecordedRewards(byWhat string, start uint64, callback func(exchange, account, reward string)) error {
+	switch byWhat {
+	case byVolumeMethodID:
+		results, err := mongodb.FindVolumeRewardResultsInRange("", start, start+1)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			if res.Program == "" { // results of reward programs are not simulated
+				callback(res.Exchange, res.Account, res.Reward)
+			}
+		}
+	case byLiquidMethodID:
+		results, err := mongodb.FindLiquidRewardResultsInRange("", start, start+1)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			if res.Program == "" { // results of reward programs are not simulated
+				callback(res.Exchange, res.Account, res.Reward)
+			}
+		}
+	}
+	return nil
+}
+
+// summarize of exchange, or of all exchanges if exchange is empty
+func summarizeSimulation(exchange string, allRewards map[simulateAccountKey]*simulateRewards, changeThreshold uint64) *SimulateResult {
+	accountRewards := make(map[common.Address]*simulateRewards)
+	for key, rewards := range allRewards {
+		if exchange != "" && key.exchange != exchange {
+			continue
+		}
+		if total, exist := accountRewards[key.account]; exist {
+			total.actual.Add(total.actual, rewards.actual)
+			total.simulated.Add(total.simulated, rewards.simulated)
+		} else {
+			accountRewards[key.account] = &simulateRewards{
+				actual:    new(big.Int).Set(rewards.actual),
+				simulated: new(big.Int).Set(rewards.simulated),
+			}
+		}
+	}
+	result := &SimulateResult{
+		Exchange:       exchange,
+		Accounts:       len(accountRewards),
+		ActualTotal:    big.NewInt(0),
+		SimulatedTotal: big.NewInt(0),
+	}
+	actuals := make([]*big.Int, 0, len(accountRewards))
+	simulateds := make([]*big.Int, 0, len(accountRewards))
+	for _, rewards := range accountRewards {
+		result.ActualTotal.Add(result.ActualTotal, rewards.actual)
+		result.SimulatedTotal.Add(result.SimulatedTotal, rewards.simulated)
+		actuals = append(actuals, rewards.actual)
+		simulateds = append(simulateds, rewards.simulated)
+		if isChangedBeyond(rewards, changeThreshold) {
+			result.ChangedCount++
+		}
+	}
+	result.ActualGini = CalcGini(actuals)
+	result.SimulatedGini = CalcGini(simulateds)
+	return result
+}
+
+func isChangedBeyond(rewards *simulateRewards, threshold uint64) bool {
+	diff := new(big.Int).Sub(rewards.simulated, rewards.actual)
+	if diff.Sign() == 0 {
+		return false
+	}
+	if rewards.actual.Sign() == 0 {
+		return true
+	}
+	// |diff| * 100 > actual * threshold
+	diff.Abs(diff).Mul(diff, big.NewInt(100))
+	return diff.Cmp(new(big.Int).Mul(rewards.actual, new(big.Int).SetUint64(threshold))) > 0
+}
+
+func formatChangePercent(rewards *simulateRewards) string {
+	if rewards.actual.Sign() == 0 {
+		if rewards.simulated.Sign() == 0 {
+			return "0"
+		}
+		return "inf"
+	}
+	diff := new(big.Float).SetInt(new(big.Int).Sub(rewards.simulated, rewards.actual))
+	percent := new(big.Float).Quo(diff, new(big.Float).SetInt(rewards.actual))
+	percent.Mul(percent, big.NewFloat(100))
+	return percent.Text('f', 2)
+}
+
+func sortedSimulateKeys(rewards map[simulateAccountKey]*simulateRewards) []simulateAccountKey {
+	keys := make([]simulateAccountKey, 0, len(rewards))
+	for key := range rewards {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].exchange != keys[j].exchange {
+			return keys[i].exchange < keys[j].exchange
+		}
+		return keys[i].account.Hex() < keys[j].account.Hex()
+	})
+	return keys
+}
+
+// CalcGini calc Gini coefficient of values, 0 is perfect equality
+func CalcGini(values []*big.Int) float64 {
+	n := len(values)
+	if n == 0 {
+		return 0
+	}
+	sorted := make([]*big.Int, n)
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	sum := big.NewInt(0)
+	weightedSum := big.NewInt(0)
+	for i, value := range sorted {
+		sum.Add(sum, value)
+		weightedSum.Add(weightedSum, new(big.Int).Mul(value, big.NewInt(int64(i+1))))
+	}
+	if sum.Sign() <= 0 {
+		return 0
+	}
+	// G = 2 * sum(i * x_i) / (n * sum(x_i)) - (n + 1) / n
+	numerator := new(big.Float).SetInt(new(big.Int).Mul(weightedSum, big.NewInt(2)))
+	denominator := new(big.Float).SetInt(new(big.Int).Mul(sum, big.NewInt(int64(n))))
+	gini, _ := new(big.Float).Quo(numerator, denominator).Float64()
+	return gini - float64(n+1)/float64(n)
+}
@@ -0,0 +1,173 @@
+package distributer
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+// block number to read stake amounts of cycle ended at end,
+// use sample height of option if exist, nil means latest in non archive mode
+func (opt *Option) getStakeBlockNumber(end uint64) *big.Int {
+	if !opt.ArchiveMode {
+		return nil
+	}
+	if opt.SampleHeight != 0 {
+		return new(big.Int).SetUint64(opt.SampleHeight)
+	}
+	if opt.UseTimeMeasurement {
+		return new(big.Int).SetUint64(getBlockHeightByTime(end))
+	}
+	return new(big.Int).SetUint64(end)
+}
+
+// is account in configed staker list, or discovered from events not after block number
+func (opt *Option) isStaker(account common.Address, blockNumber *big.Int) bool {
+	if params.IsInStakerList(account) {
+		return true
+	}
+	stakeCfg := params.GetConfig().Stake
+	if !stakeCfg.DiscoverStakers || !mongodb.HasSession() {
+		return false
+	}
+	if opt.stakers == nil {
+		opt.stakers = make(map[common.Address]uint64)
+		stakers, err := mongodb.FindStakers(stakeCfg.Contract, math.MaxInt64)
+		if err != nil {
+			log.Warn("find discovered stakers failed", "contract", stakeCfg.Contract, "err", err)
+		}
+		for _, staker := range stakers {
+			opt.stakers[common.HexToAddress(staker.Account)] = staker.BlockNumber
+		}
+	}
+	firstSeen, exist := opt.stakers[account]
+	if !exist {
+		return false
+	}
+	return blockNumber == nil || firstSeen <= blockNumber.Uint64()
+}
+
+// boost shares of stakers by stake amounts at block number and the configed curve
+func (opt *Option) boostSharesByStake(exchange string, start uint64, accountStats mongodb.AccountStatSlice, blockNumber *big.Int) {
+	stakeCfg := params.GetConfig().Stake
+	if stakeCfg == nil || !params.IsStakeBoosted(opt.byWhat) || len(accountStats) == 0 {
+		return
+	}
+	stakeContract := common.HexToAddress(stakeCfg.Contract)
+	stakeAmounts := make([]*big.Int, len(accountStats))
+	totalStake := big.NewInt(0)
+	for i, stat := range accountStats {
+		if !opt.isStaker(stat.Account, blockNumber) {
+			continue
+		}
+		stakeAmount := capi.LoopGetStakeAmount(stakeContract, stat.Account, blockNumber)
+		stakeAmounts[i] = stakeAmount
+		totalStake.Add(totalStake, stakeAmount)
+	}
+	totalShare := accountStats.CalcTotalShare()
+	curve := stakeCfg.GetCurve()
+	for i, stat := range accountStats {
+		stakeAmount := stakeAmounts[i]
+		if stakeAmount == nil || stakeAmount.Sign() <= 0 {
+			continue
+		}
+		stakeWholeAmount := new(big.Int).Div(stakeAmount, big.NewInt(1e18)).Uint64()
+		var addPercent uint64
+		switch curve {
+		case params.StakeCurveLinear:
+			addPercent = calcLinearPercentOfStaking(stakeWholeAmount)
+		case params.StakeCurveVeBoost:
+			addPercent = calcVeBoostPercentOfStaking(stat.Share, totalShare, stakeAmount, totalStake)
+		default:
+			addPercent = calcAddPercentOfStaking(stakeWholeAmount)
+		}
+		opt.addStakeBoost(exchange, stat.Account, start, &StakeExplanation{
+			OriginShare:      new(big.Int).Set(stat.Share),
+			BlockNumber:      blockNumber,
+			StakeAmount:      stakeAmount,
+			StakeWholeAmount: stakeWholeAmount,
+			Curve:            curve,
+			AddPercent:       addPercent,
+		})
+		if addPercent == 0 {
+			continue
+		}
+		added := new(big.Int).Mul(stat.Share, new(big.Int).SetUint64(addPercent))
+		added.Div(added, big.NewInt(100))
+		log.Trace("add weighted share by stake", "bywhat", opt.byWhat, "exchange", exchange, "account", stat.Account.String(),
+			"origin", stat.Share, "added", added, "curve", curve, "addPercent", addPercent,
+			"stakeAmount", stakeAmount, "stakeWholeAmount", stakeWholeAmount, "blockNumber", blockNumber)
+		stat.Share.Add(stat.Share, added)
+	}
+}
+
+// percent of the highest reached point
+func calcAddPercentOfStaking(stakeWholeAmount uint64) (percent uint64) {
+	stakeCfg := params.GetConfig().Stake
+	for i, point := range stakeCfg.Points {
+		if stakeWholeAmount >= point && i < len(stakeCfg.Percents) {
+			percent = stakeCfg.Percents[i]
+		}
+	}
+	return percent
+}
+
+// linear to max percent at full stake
+func calcLinearPercentOfStaking(stakeWholeAmount uint64) uint64 {
+	stakeCfg := params.GetConfig().Stake
+	if stakeWholeAmount >= stakeCfg.FullStake {
+		return stakeCfg.GetMaxPercent()
+	}
+	percent := new(big.Int).SetUint64(stakeCfg.GetMaxPercent())
+	percent.Mul(percent, new(big.Int).SetUint64(stakeWholeAmount))
+	return percent.Div(percent, new(big.Int).SetUint64(stakeCfg.FullStake)).Uint64()
+}
+
+// like Curve's veBoost, max percent of stake ratio over share ratio (among accounts of the same shares),
+// capped at max percent.
+func calcVeBoostPercentOfStaking(share, totalShare, stakeAmount, totalStake *big.Int) uint64 {
+	maxPercent := params.GetConfig().Stake.GetMaxPercent()
+	if share.Sign() <= 0 || totalStake.Sign() <= 0 {
+		return 0
+	}
+	// maxPercent * (stakeAmount / totalStake) / (share / totalShare)
+	percent := new(big.Int).SetUint64(maxPercent)
+	percent.Mul(percent, stakeAmount)
+	percent.Mul(percent, totalShare)
+	percent.Div(percent, totalStake)
+	percent.Div(percent, share)
+	if !percent.IsUint64() || percent.Uint64() > maxPercent {
+		return maxPercent
+	}
+	return percent.Uint64()
+}
+
+func getStakeBoostKey(exchange string, account common.Address, start uint64) string {
+	return strings.ToLower(fmt.Sprintf("%s:%s:%d", exchange, account.String(), start))
+}
+
+func (opt *Option) addStakeBoost(exchange string, account common.Address, start uint64, boost *StakeExplanation) {
+	if opt.stakeBoosts == nil {
+		opt.stakeBoosts = make(map[string]*StakeExplanation)
+	}
+	opt.stakeBoosts[getStakeBoostKey(exchange, account, start)] = boost
+}
+
+// get stake boost of account in exchange of cycle start, nil if not boosted
+func (opt *Option) getStakeBoost(exchange string, account common.Address, start uint64) *StakeExplanation {
+	return opt.stakeBoosts[getStakeBoostKey(exchange, account, start)]
+}
+
+// get share of account in exchange of this cycle before stake boost
+func (opt *Option) getUnboostedShare(exchange string, account common.Address, share *big.Int) *big.Int {
+	if boost := opt.getStakeBoost(exchange, account, opt.StartHeight); boost != nil && boost.OriginShare != nil {
+		return boost.OriginShare
+	}
+	return share
+}
@@ -0,0 +1,372 @@
+package distributer
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+// reasons of unspent rewards
+const (
+	unspentReasonDust        = "dust"
+	unspentReasonNoVolume    = "novolume"
+	unspentReasonUnallocated = "unallocated"
+)
+
+func (opt *Option) unspentPolicy() string {
+	if opt.recordedPolicy != "" {
+		return opt.recordedPolicy
+	}
+	return params.GetUnspentPolicy(opt.Program)
+}
+
+// status of unspent rewards which are not paid in this cycle.
+// accumulate policy only applies to dust rewards of accounts, others are carried over.
+func (opt *Option) unspentStatus() string {
+	if opt.unspentPolicy() == params.UnspentPolicyDrop {
+		return mongodb.UnspentStatusDropped
+	}
+	return mongodb.UnspentStatusCarried
+}
+
+func (opt *Option) addUnspentReward(exchange, reason, status string, amount *big.Int, accounts int) {
+	if amount == nil || amount.Sign() <= 0 {
+		return
+	}
+	exchange = strings.ToLower(exchange)
+	unspent := &mongodb.MgoUnspentReward{
+		Key:         mongodb.GetKeyOfUnspentReward(opt.Program, opt.byWhat, exchange, opt.StartHeight, reason),
+		Program:     opt.Program,
+		ByWhat:      opt.byWhat,
+		Exchange:    exchange,
+		Start:       opt.StartHeight,
+		End:         opt.EndHeight,
+		RewardToken: strings.ToLower(opt.RewardToken),
+		Reason:      reason,
+		Amount:      amount.String(),
+		Accounts:    accounts,
+		Policy:      opt.unspentPolicy(),
+		Status:      status,
+	}
+	opt.unspentRewards = append(opt.unspentRewards, unspent)
+	log.Info("[unspent] add unspent rewards", "bywhat", opt.byWhat, "exchange", exchange,
+		"start", opt.StartHeight, "end", opt.EndHeight, "reason", reason, "amount", amount, "accounts", accounts, "status", status)
+}
+
+// add carried unspent rewards of previous cycles to total rewards
+func (opt *Option) carryInUnspentRewards() {
+	if !mongodb.HasSession() {
+		return
+	}
+	carried, err := mongodb.FindCarriedUnspentRewards(opt.Program, opt.byWhat, opt.RewardToken, opt.StartHeight)
+	if err != nil {
+		log.Warn("[unspent] find carried unspent rewards failed", "bywhat", opt.byWhat, "start", opt.StartHeight, "err", err)
+		return
+	}
+	sum := big.NewInt(0)
+	for _, unspent := range carried {
+		amount, _ := tools.GetBigIntFromString(unspent.Amount)
+		if amount == nil {
+			continue
+		}
+		sum.Add(sum, amount)
+		opt.carriedInRewards = append(opt.carriedInRewards, unspent)
+	}
+	if sum.Sign() <= 0 {
+		return
+	}
+	opt.TotalValue = new(big.Int).Add(opt.TotalValue, sum)
+	opt.carriedIn = sum
+	log.Info("[unspent] carry in unspent rewards", "bywhat", opt.byWhat, "start", opt.StartHeight, "end", opt.EndHeight,
+		"count", len(opt.carriedInRewards), "carriedIn", sum, "totalReward", opt.TotalValue)
+}
+
+// total rewards which are neither given to accounts nor recorded as unspent
+func (opt *Option) calcUnallocatedRewards(accountStats []mongodb.AccountStatSlice) *big.Int {
+	unallocated := new(big.Int).Set(opt.TotalValue)
+	if opt.referralDeduct != nil {
+		unallocated.Sub(unallocated, opt.referralDeduct)
+	}
+	for _, unspent := range opt.unspentRewards {
+		amount, _ := tools.GetBigIntFromString(unspent.Amount)
+		if amount != nil {
+			unallocated.Sub(unallocated, amount)
+		}
+	}
+	for _, stats := range accountStats {
+		for _, stat := range stats {
+			if stat.Reward != nil {
+				unallocated.Sub(unallocated, stat.Reward)
+			}
+		}
+	}
+	return unallocated
+}
+
+// apply unspent policy on calced rewards before dispatching,
+// dust rewards are left to the policy only if they are not accumulated.
+func (opt *Option) applyUnspentPolicy(accountStats []mongodb.AccountStatSlice) {
+	unallocated := opt.calcUnallocatedRewards(accountStats)
+	withDust := opt.unspentPolicy() != params.UnspentPolicyAccumulate
+	if !withDust {
+		opt.accumulateDustRewards(accountStats)
+	}
+	if opt.unspentPolicy() == params.UnspentPolicyRedistribute {
+		opt.redistributeUnspentRewards(accountStats, unallocated, withDust)
+		return
+	}
+	if withDust {
+		dustRewardThreshold := params.GetDustRewardThreshold()
+		for i, stats := range accountStats {
+			dust, count := sumDustRewards(stats, dustRewardThreshold)
+			opt.addUnspentReward(opt.Exchanges[i], unspentReasonDust, opt.unspentStatus(), dust, count)
+		}
+	}
+	opt.addUnspentReward("", unspentReasonUnallocated, opt.unspentStatus(), unallocated, 0)
+}
+
+func isDustReward(reward, dustRewardThreshold *big.Int) bool {
+	return reward != nil && reward.Sign() > 0 && reward.Cmp(dustRewardThreshold) < 0
+}
+
+func sumDustRewards(stats mongodb.AccountStatSlice, dustRewardThreshold *big.Int) (sum *big.Int, count int) {
+	sum = big.NewInt(0)
+	for _, stat := range stats {
+		if isDustReward(stat.Reward, dustRewardThreshold) {
+			sum.Add(sum, stat.Reward)
+			count++
+		}
+	}
+	return sum, count
+}
+
+// redistribute dust rewards (if withDust) pro rata among other accounts of the same exchange,
+// and unallocated rewards (eg. exchange without any account) among all accounts of the cycle.
+// rewards are carried over if there is no account to redistribute to.
+func (opt *Option) redistributeUnspentRewards(accountStats []mongodb.AccountStatSlice, unallocated *big.Int, withDust bool) {
+	type exchangeDust struct {
+		index  int
+		amount *big.Int
+		count  int
+	}
+	dustRewardThreshold := params.GetDustRewardThreshold()
+	if unallocated.Sign() < 0 {
+		unallocated = big.NewInt(0)
+	}
+	leftover := new(big.Int).Set(unallocated)
+	var leftoverDusts []*exchangeDust
+	for i, stats := range accountStats {
+		dust, count := sumDustRewards(stats, dustRewardThreshold)
+		if count == 0 || !withDust {
+			continue
+		}
+		recipients := make(mongodb.AccountStatSlice, 0, len(stats))
+		for _, stat := range stats {
+			if stat.Reward != nil && stat.Reward.Sign() > 0 && !isDustReward(stat.Reward, dustRewardThreshold) {
+				recipients = append(recipients, stat)
+			}
+		}
+		accountStats[i] = recipients
+		if len(recipients) == 0 {
+			leftover.Add(leftover, dust)
+			leftoverDusts = append(leftoverDusts, &exchangeDust{index: i, amount: dust, count: count})
+			continue
+		}
+		addRewardsProRata(recipients, dust)
+		opt.addUnspentReward(opt.Exchanges[i], unspentReasonDust, mongodb.UnspentStatusRedistributed, dust, count)
+	}
+
+	var allRecipients mongodb.AccountStatSlice
+	for _, stats := range accountStats {
+		for _, stat := range stats {
+			if stat.Reward != nil && stat.Reward.Sign() > 0 && !isDustReward(stat.Reward, dustRewardThreshold) {
+				allRecipients = append(allRecipients, stat)
+			}
+		}
+	}
+	status := mongodb.UnspentStatusRedistributed
+	if len(allRecipients) == 0 {
+		status = mongodb.UnspentStatusCarried
+	} else {
+		addRewardsProRata(allRecipients, leftover)
+	}
+	for _, dust := range leftoverDusts {
+		opt.addUnspentReward(opt.Exchanges[dust.index], unspentReasonDust, status, dust.amount, dust.count)
+	}
+	opt.addUnspentReward("", unspentReasonUnallocated, status, unallocated, 0)
+	log.Info("[unspent] redistribute unspent rewards", "bywhat", opt.byWhat, "start", opt.StartHeight, "end", opt.EndHeight,
+		"unallocated", unallocated, "leftover", leftover, "recipients", len(allRecipients), "status", status)
+}
+
+// add rewards to stats pro rata by their rewards
+func addRewardsProRata(stats mongodb.AccountStatSlice, amount *big.Int) {
+	if len(stats) == 0 || amount.Sign() <= 0 {
+		return
+	}
+	shares := make([]*big.Int, len(stats))
+	for i, stat := range stats {
+		shares[i] = stat.Reward
+	}
+	adds := mongodb.DivideRewards(amount, shares)
+	if len(adds) != len(stats) {
+		return
+	}
+	for i, stat := range stats {
+		stat.Reward = new(big.Int).Add(stat.Reward, adds[i])
+	}
+}
+
+// add pending rewards to accounts, pay them once passing dust threshold,
+// otherwise accumulate dust rewards as pending.
+func (opt *Option) accumulateDustRewards(accountStats []mongodb.AccountStatSlice) {
+	dustRewardThreshold := params.GetDustRewardThreshold()
+	pendings := opt.loadPendingRewards()
+	opt.pendingRewards = make(map[string]*mongodb.MgoPendingReward)
+	for i, stats := range accountStats {
+		dust := big.NewInt(0)
+		dustCount := 0
+		for _, stat := range stats {
+			if stat.Reward == nil || stat.Reward.Sign() <= 0 {
+				continue
+			}
+			pending := pendings[stat.Account]
+			balance := big.NewInt(0)
+			if pending != nil {
+				if amount, _ := tools.GetBigIntFromString(pending.Amount); amount != nil {
+					balance = amount
+				}
+			}
+			total := new(big.Int).Add(stat.Reward, balance)
+			if total.Cmp(dustRewardThreshold) >= 0 {
+				if balance.Sign() <= 0 {
+					continue
+				}
+				log.Info("[unspent] pay pending rewards", "account", stat.Account.String(), "reward", stat.Reward, "pending", balance)
+				stat.Reward = total
+				opt.addPendingIn(opt.Exchanges[i], stat.Account.String(), balance)
+				paid, _ := tools.GetBigIntFromString(pending.Paid)
+				if paid == nil {
+					paid = big.NewInt(0)
+				}
+				pending.Paid = paid.Add(paid, balance).String()
+				pending.Amount = "0"
+			} else {
+				if pending == nil {
+					pending = opt.newPendingReward(stat.Account)
+					pendings[stat.Account] = pending
+				}
+				pending.Amount = total.String()
+				dust.Add(dust, stat.Reward)
+				dustCount++
+			}
+			pending.LastStart = opt.StartHeight
+			opt.pendingRewards[pending.Key] = pending
+		}
+		opt.addUnspentReward(opt.Exchanges[i], unspentReasonDust, mongodb.UnspentStatusAccumulated, dust, dustCount)
+	}
+}
+
+func (opt *Option) addPendingIn(exchange, account string, amount *big.Int) {
+	if opt.pendingIn == nil {
+		opt.pendingIn = make(map[string]*big.Int)
+	}
+	opt.pendingIn[strings.ToLower(exchange+":"+account)] = amount
+}
+
+// get paid pending rewards of account in exchange, empty if not exist
+func (opt *Option) getPendingIn(exchange, account string) string {
+	if amount, exist := opt.pendingIn[strings.ToLower(exchange+":"+account)]; exist {
+		return amount.String()
+	}
+	return ""
+}
+
+func (opt *Option) newPendingReward(account common.Address) *mongodb.MgoPendingReward {
+	accountStr := strings.ToLower(account.String())
+	return &mongodb.MgoPendingReward{
+		Key:         mongodb.GetKeyOfPendingReward(opt.Program, opt.RewardToken, accountStr),
+		Program:     opt.Program,
+		RewardToken: strings.ToLower(opt.RewardToken),
+		Account:     accountStr,
+		Amount:      "0",
+		Paid:        "0",
+	}
+}
+
+func (opt *Option) loadPendingRewards() map[common.Address]*mongodb.MgoPendingReward {
+	pendings := make(map[common.Address]*mongodb.MgoPendingReward)
+	if !mongodb.HasSession() {
+		return pendings
+	}
+	records, err := mongodb.FindPendingRewards(opt.Program, opt.RewardToken)
+	if err != nil {
+		log.Warn("[unspent] find pending rewards failed", "rewardToken", opt.RewardToken, "err", err)
+		return pendings
+	}
+	for _, record := range records {
+		pendings[common.HexToAddress(record.Account)] = record
+	}
+	return pendings
+}
+
+// mark paid pending rewards of account right after transferring to it,
+// so a failure in the middle of dispatching can not pay them again in next cycle.
+// carried in rewards are marked applied only after the whole cycle is dispatched,
+// as they are shared by all accounts and the unpaid part must be carried in again.
+func (opt *Option) markRewardsApplied(account common.Address) {
+	if !opt.SaveDB || opt.DryRun {
+		return
+	}
+	key := mongodb.GetKeyOfPendingReward(opt.Program, opt.RewardToken, strings.ToLower(account.String()))
+	if pending, exist := opt.pendingRewards[key]; exist {
+		opt.savePendingReward(pending, uint64(time.Now().Unix()))
+		delete(opt.pendingRewards, key)
+	}
+}
+
+func (opt *Option) markCarriedInApplied() {
+	for _, unspent := range opt.carriedInRewards {
+		key := unspent.Key
+		_ = mongodb.TryDoTimes("UpdateUnspentRewardApplied "+key, func() error {
+			return mongodb.UpdateUnspentRewardApplied(key, opt.StartHeight)
+		})
+	}
+}
+
+func (opt *Option) savePendingReward(pending *mongodb.MgoPendingReward, now uint64) {
+	pending.Timestamp = now
+	_ = mongodb.TryDoTimes("AddPendingReward "+pending.Key, func() error {
+		return mongodb.AddPendingReward(pending)
+	})
+}
+
+// save unspent rewards and pending rewards of accounts not paid in cycle to database if SaveDB,
+// and mark carried in rewards applied, it is called after the whole cycle is dispatched.
+func (opt *Option) saveUnspentRewards() {
+	if !opt.SaveDB || opt.DryRun {
+		return
+	}
+	opt.markCarriedInApplied()
+	now := uint64(time.Now().Unix())
+	for _, unspent := range opt.unspentRewards {
+		unspent.Timestamp = now
+		_ = mongodb.TryDoTimes("AddUnspentReward "+unspent.Key, func() error {
+			return mongodb.AddUnspentReward(unspent)
+		})
+	}
+	keys := make([]string, 0, len(opt.pendingRewards))
+	for key := range opt.pendingRewards {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		opt.savePendingReward(opt.pendingRewards[key], now)
+	}
+}
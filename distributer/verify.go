@@ -0,0 +1,475 @@
+package distributer
+
+import (
+	"bufio"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+// RewardTitle title info of rewards file written by 'writeSendRewardTitleLine'
+type RewardTitle struct {
+	ByWhat       string
+	HasTxHash    bool
+	SampleHeight uint64
+	NoVolumes    uint64
+	Start        uint64
+	End          uint64
+	TotalReward  *big.Int
+	Exchange     string
+	RewardToken  string
+	Program      string
+
+	// parameters of cycle, empty Exchanges if not recorded
+	Exchanges          []string
+	Weights            []uint64
+	StepCount          uint64
+	StepReward         *big.Int
+	WeightIsPercentage bool
+	UseTime            bool
+	UnspentPolicy      string
+	DustThreshold      *big.Int
+	CarriedIn          *big.Int
+}
+
+// parameters of cycle recorded in title line, so the rewards can be verified
+// later with the same parameters even if the config is changed.
+func (opt *Option) getCycleParamsInfo() string {
+	weights := make([]string, len(opt.Weights))
+	for i, weight := range opt.Weights {
+		weights[i] = strconv.FormatUint(weight, 10)
+	}
+	info := fmt.Sprintf("&&exchanges=%v&&weights=%v&&unspentPolicy=%v&&dust=%v",
+		strings.Join(opt.Exchanges, "|"), strings.Join(weights, "|"),
+		opt.unspentPolicy(), params.GetDustRewardThreshold())
+	if opt.byWhat == byVolumeMethodID && opt.StepReward != nil {
+		info += fmt.Sprintf("&&stepCount=%v&&stepReward=%v&&weightIsPercentage=%v", opt.StepCount, opt.StepReward, opt.WeightIsPercentage)
+	}
+	if opt.UseTimeMeasurement {
+		info += "&&useTime=true"
+	}
+	if opt.carriedIn != nil && opt.carriedIn.Sign() > 0 {
+		info += fmt.Sprintf("&&carriedIn=%v", opt.carriedIn)
+	}
+	return info
+}
+
+// ParseRewardTitleLine parse title line with format
+// #account,reward,<liquidity|volume>,<height|txcount>[,txhash],<extra info>
+// extra info is '&&' separated key value pairs
+func ParseRewardTitleLine(titleLine string) (*RewardTitle, error) {
+	parts := strings.Split(titleLine, ",")
+	if len(parts) < 5 || parts[0] != "#account" || parts[1] != "reward" {
+		return nil, fmt.Errorf("wrong title line '%v'", titleLine)
+	}
+	title := &RewardTitle{
+		ByWhat:    GetStandardByWhat(parts[2]),
+		HasTxHash: len(parts) > 5 && parts[4] == "txhash",
+	}
+	if title.ByWhat != byLiquidMethodID && title.ByWhat != byVolumeMethodID {
+		return nil, fmt.Errorf("unknown reward type '%v' in title line", parts[2])
+	}
+	var err error
+	for _, kv := range strings.Split(parts[len(parts)-1], "&&") {
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("wrong extra info '%v' in title line", kv)
+		}
+		key, value := pair[0], pair[1]
+		switch key {
+		case "sampleHeight":
+			title.SampleHeight, err = strconv.ParseUint(value, 10, 64)
+		case "novolumes":
+			title.NoVolumes, err = strconv.ParseUint(value, 10, 64)
+		case "start":
+			title.Start, err = strconv.ParseUint(value, 10, 64)
+		case "end":
+			title.End, err = strconv.ParseUint(value, 10, 64)
+		case "totalReward":
+			title.TotalReward, err = tools.GetBigIntFromString(value)
+		case "exchange":
+			title.Exchange = value
+		case "rewardToken":
+			title.RewardToken = value
+		case "program":
+			title.Program = value
+		case "exchanges":
+			title.Exchanges = strings.Split(value, "|")
+		case "weights":
+			title.Weights, err = parseWeights(value)
+		case "stepCount":
+			title.StepCount, err = strconv.ParseUint(value, 10, 64)
+		case "stepReward":
+			title.StepReward, err = tools.GetBigIntFromString(value)
+		case "weightIsPercentage":
+			title.WeightIsPercentage, err = strconv.ParseBool(value)
+		case "useTime":
+			title.UseTime, err = strconv.ParseBool(value)
+		case "unspentPolicy":
+			title.UnspentPolicy = value
+		case "dust":
+			title.DustThreshold, err = tools.GetBigIntFromString(value)
+		case "carriedIn":
+			title.CarriedIn, err = tools.GetBigIntFromString(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("wrong %v '%v' in title line, %v", key, value, err)
+		}
+	}
+	if title.Start >= title.End {
+		return nil, fmt.Errorf("wrong range [%v, %v) in title line", title.Start, title.End)
+	}
+	if title.TotalReward == nil || title.TotalReward.Sign() <= 0 {
+		return nil, fmt.Errorf("no total reward in title line")
+	}
+	if !common.IsHexAddress(title.Exchange) {
+		return nil, fmt.Errorf("wrong exchange '%v' in title line", title.Exchange)
+	}
+	if len(title.Exchanges) != len(title.Weights) {
+		return nil, fmt.Errorf("count of exchanges %v != count of weights %v in title line", len(title.Exchanges), len(title.Weights))
+	}
+	return title, nil
+}
+
+func parseWeights(value string) ([]uint64, error) {
+	parts := strings.Split(value, "|")
+	weights := make([]uint64, len(parts))
+	for i, part := range parts {
+		weight, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		weights[i] = weight
+	}
+	return weights, nil
+}
+
+// VerifyRewardsFile recompute rewards of file from database and chain independently,
+// print diff of each account, return error if any mismatch is beyond rounding.
+func VerifyRewardsFile(ifile string) error {
+	fileStats, titleLine, err := GetAccountsAndRewardsFromFile(ifile)
+	if err != nil {
+		return err
+	}
+	title, err := ParseRewardTitleLine(titleLine)
+	if err != nil {
+		return err
+	}
+	log.Info("[verify] parse title line success", "file", ifile, "title", titleLine)
+
+	opt, index, err := newVerifyOption(title, fileStats)
+	if err != nil {
+		return err
+	}
+	var accountStats []mongodb.AccountStatSlice
+	switch opt.byWhat {
+	case byLiquidMethodID:
+		accountStats, err = opt.calcLiquidityRewards()
+	case byVolumeMethodID:
+		accountStats, err = opt.calcVolumeRewards()
+	}
+	if err != nil {
+		return err
+	}
+	if accountStats != nil && opt.byWhat == byVolumeMethodID {
+		_ = opt.calcReferralRewards(accountStats) // deduct referral rewards of referees in the same budget
+	}
+	if accountStats != nil && opt.unspentPolicy() == params.UnspentPolicyRedistribute {
+		opt.redistributeUnspentRewards(accountStats, opt.calcUnallocatedRewards(accountStats), true)
+	}
+	var expectStats mongodb.AccountStatSlice
+	if accountStats != nil {
+		expectStats = accountStats[index]
+	}
+	// paid pending rewards of previous cycles are recorded in file
+	pendings, err := getPendingAnnotations(ifile)
+	if err != nil {
+		return err
+	}
+	expectStats = replayPendingRewards(expectStats, pendings)
+
+	mismatches := 0
+	if opt.byWhat == byVolumeMethodID && opt.noVolumes != title.NoVolumes {
+		log.Printf("[verify] novolumes mismatch, file %v, recomputed %v\n", title.NoVolumes, opt.noVolumes)
+		mismatches++
+	}
+	mismatches += diffRewards(fileStats, expectStats, title, opt.verifyTolerance())
+
+	log.Info("[verify] finished", "file", ifile, "accounts", len(fileStats), "recomputed", len(expectStats), "mismatches", mismatches)
+	if mismatches > 0 {
+		return fmt.Errorf("verify %v failed with %v mismatches", ifile, mismatches)
+	}
+	return nil
+}
+
+// get paid pending rewards of accounts from '@pending=' annotations of file
+func getPendingAnnotations(ifile string) (map[common.Address]*big.Int, error) {
+	file, err := os.Open(ifile)
+	if err != nil {
+		return nil, fmt.Errorf("open %v failed. %v)", ifile, err)
+	}
+	defer file.Close()
+
+	pendings := make(map[common.Address]*big.Int)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if isCommentedLine(line) {
+			continue
+		}
+		parts := blankOrCommaSepRegexp.Split(line, -1)
+		if len(parts) == 0 || !common.IsHexAddress(parts[0]) {
+			continue
+		}
+		for _, part := range parts[1:] {
+			if !strings.HasPrefix(part, annotationPending) {
+				continue
+			}
+			amount, err := tools.GetBigIntFromString(strings.TrimPrefix(part, annotationPending))
+			if err != nil || amount == nil {
+				return nil, fmt.Errorf("wrong pending annotation in line %v", line)
+			}
+			account := common.HexToAddress(parts[0])
+			if pending, exist := pendings[account]; exist {
+				pending.Add(pending, amount)
+			} else {
+				pendings[account] = amount
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pendings, nil
+}
+
+// add paid pending rewards to recomputed rewards of this cycle before comparing
+func replayPendingRewards(expectStats mongodb.AccountStatSlice, pendings map[common.Address]*big.Int) mongodb.AccountStatSlice {
+	for _, stat := range expectStats {
+		pending, exist := pendings[stat.Account]
+		if !exist {
+			continue
+		}
+		delete(pendings, stat.Account)
+		reward := stat.Reward
+		if reward == nil {
+			reward = big.NewInt(0)
+		}
+		stat.Reward = new(big.Int).Add(reward, pending)
+		log.Info("[verify] replay paid pending rewards", "account", stat.Account.String(), "pending", pending, "reward", stat.Reward)
+	}
+	for account, pending := range pendings {
+		log.Info("[verify] replay paid pending rewards of account without rewards", "account", account.String(), "pending", pending)
+		expectStats = append(expectStats, &mongodb.AccountStat{Account: account, Reward: pending})
+	}
+	return expectStats
+}
+
+func newVerifyOption(title *RewardTitle, fileStats mongodb.AccountStatSlice) (opt *Option, index int, err error) {
+	sampleHeight := title.SampleHeight
+	if sampleHeight == 0 && len(fileStats) > 0 && title.ByWhat == byLiquidMethodID {
+		// liquidity balances are sampled at the height recorded in file
+		sampleHeight = fileStats[0].Number
+	}
+	recorded := title
+	if len(title.Exchanges) == 0 {
+		if title.Program != "" {
+			return nil, 0, fmt.Errorf("no cycle parameters of program %v in title line", title.Program)
+		}
+		log.Warn("[verify] no cycle parameters in title line, verify with current config")
+		recorded = nil
+	} else if title.DustThreshold != nil {
+		params.SetDustRewardThreshold(title.DustThreshold.String())
+	}
+	opt, err = newCycleOption(title.ByWhat, title.Start, title.End, title.TotalReward, sampleHeight, recorded)
+	if err != nil {
+		return nil, 0, err
+	}
+	opt.RewardToken = title.RewardToken
+	index = opt.getExchangeIndex(title.Exchange)
+	if index < 0 {
+		return nil, 0, fmt.Errorf("exchange %v of %v rewards is not configed", title.Exchange, opt.byWhat)
+	}
+	if err = opt.CheckBasic(); err != nil {
+		return nil, 0, err
+	}
+	return opt, index, nil
+}
+
+func (opt *Option) getExchangeIndex(exchange string) int {
+	for i, ex := range opt.Exchanges {
+		if strings.EqualFold(ex, exchange) {
+			return i
+		}
+	}
+	return -1
+}
+
+// rebuild the option of the cycle from config, same as the distributer does.
+// parameters recorded in rewards file take precedence over config if provided.
+func newCycleOption(byWhat string, start, end uint64, totalReward *big.Int, sampleHeight uint64, recorded *RewardTitle) (*Option, error) {
+	distCfg := params.GetConfig().Distribute
+	if distCfg == nil {
+		return nil, fmt.Errorf("no distribute config")
+	}
+	opt := &Option{
+		TotalValue:         totalReward,
+		StartHeight:        start,
+		EndHeight:          end,
+		RewardToken:        distCfg.RewardToken,
+		SampleHeight:       sampleHeight,
+		DryRun:             true,
+		ArchiveMode:        distCfg.ArchiveMode,
+		UseTimeMeasurement: distCfg.UseTimeMeasurement,
+	}
+	if err := opt.SetByWhat(byWhat); err != nil {
+		return nil, err
+	}
+	for _, exchange := range params.GetConfig().Exchanges {
+		weight, fixedRewards := exchange.LiquidWeight, exchange.LiquidRewardsPerCycle
+		if opt.byWhat == byVolumeMethodID {
+			weight, fixedRewards = exchange.TradeWeight, exchange.TradeRewardsPerCycle
+		}
+		if weight > 0 || fixedRewards != "" {
+			opt.Exchanges = append(opt.Exchanges, exchange.Exchange)
+			opt.Weights = append(opt.Weights, weight)
+		}
+	}
+
+	switch opt.byWhat {
+	case byLiquidMethodID:
+		// liquidity balances must be sampled at the sample height
+		opt.ArchiveMode = true
+		if opt.SampleHeight == 0 {
+			return nil, fmt.Errorf("unknown sample height")
+		}
+	case byVolumeMethodID:
+		opt.WeightIsPercentage = distCfg.TradeWeightIsPercentage
+		opt.StepReward = distCfg.GetByVolumeRewardsAt(start)
+		if opt.UseTimeMeasurement {
+			opt.StepCount = distCfg.ByVolumeCycleDuration
+		} else {
+			opt.StepCount = distCfg.ByVolumeCycle
+		}
+		if !opt.ArchiveMode && len(opt.Exchanges) > 1 && !opt.WeightIsPercentage {
+			log.Warn("exchange liquidity is sampled at latest block in non archive mode, rewards may differ")
+		}
+	default:
+		return nil, fmt.Errorf("only support liquidity or volume rewards")
+	}
+	if recorded != nil && recorded.Program != "" {
+		prog := params.GetProgramConfig(recorded.Program)
+		if prog == nil {
+			return nil, fmt.Errorf("program %v is not configed", recorded.Program)
+		}
+		if err := opt.applyProgram(prog, totalReward); err != nil {
+			return nil, err
+		}
+	}
+	if recorded != nil {
+		opt.applyRecordedParams(recorded)
+	}
+
+	if opt.TotalValue == nil {
+		opt.TotalValue = opt.getConfigedCycleRewards(distCfg)
+	}
+	if err := opt.checkSteps(); err != nil {
+		return nil, err
+	}
+	if err := opt.checkWeights(); err != nil {
+		return nil, err
+	}
+	return opt, nil
+}
+
+func (opt *Option) applyRecordedParams(recorded *RewardTitle) {
+	opt.Exchanges = make([]string, len(recorded.Exchanges))
+	for i, exchange := range recorded.Exchanges {
+		if exCfg := params.GetExchangeConfig(exchange); exCfg != nil {
+			exchange = exCfg.Exchange // same case as saved in database
+		}
+		opt.Exchanges[i] = exchange
+	}
+	opt.Weights = recorded.Weights
+	opt.UseTimeMeasurement = recorded.UseTime
+	opt.recordedPolicy = recorded.UnspentPolicy
+	opt.carriedIn = recorded.CarriedIn
+	if opt.byWhat == byVolumeMethodID && recorded.StepReward != nil {
+		opt.StepCount = recorded.StepCount
+		opt.StepReward = recorded.StepReward
+		opt.WeightIsPercentage = recorded.WeightIsPercentage
+	}
+}
+
+// total rewards of cycle calculated by config
+func (opt *Option) getConfigedCycleRewards(distCfg *params.DistributeConfig) *big.Int {
+	if opt.byWhat == byLiquidMethodID {
+		return distCfg.GetByLiquidRewardsAt(opt.StartHeight)
+	}
+	if opt.StepCount == 0 {
+		return new(big.Int).Set(opt.StepReward)
+	}
+	steps := (opt.EndHeight - opt.StartHeight) / opt.StepCount
+	return new(big.Int).Mul(opt.StepReward, new(big.Int).SetUint64(steps))
+}
+
+// every division may lose 1 wei of each account,
+// volume rewards are divided in every step and then by exchanges.
+func (opt *Option) verifyTolerance() *big.Int {
+	tolerance := uint64(2)
+	if opt.byWhat == byVolumeMethodID && opt.StepCount > 0 {
+		tolerance += (opt.EndHeight - opt.StartHeight) / opt.StepCount
+	}
+	return new(big.Int).SetUint64(tolerance)
+}
+
+func diffRewards(fileStats, expectStats mongodb.AccountStatSlice, title *RewardTitle, tolerance *big.Int) (mismatches int) {
+	expectMap := make(map[common.Address]*mongodb.AccountStat, len(expectStats))
+	for _, stat := range expectStats {
+		expectMap[stat.Account] = stat
+	}
+	dustRewardThreshold := params.GetDustRewardThreshold()
+
+	log.Printf("[verify] %v rewards of exchange %v start %v end %v, tolerance %v wei\n", title.ByWhat, title.Exchange, title.Start, title.End, tolerance)
+	for _, stat := range fileStats {
+		account := strings.ToLower(stat.Account.String())
+		expect, exist := expectMap[stat.Account]
+		if !exist {
+			log.Printf("[verify] MISMATCH %v reward %v share %v, not in recomputed rewards\n", account, stat.Reward, stat.Share)
+			mismatches++
+			continue
+		}
+		delete(expectMap, stat.Account)
+		diff := new(big.Int).Sub(stat.Reward, expect.Reward)
+		shareMatch := stat.Share == nil || expect.Share == nil || stat.Share.Cmp(expect.Share) == 0
+		status := "OK"
+		if new(big.Int).Abs(diff).Cmp(tolerance) > 0 || !shareMatch {
+			status = "MISMATCH"
+			mismatches++
+		}
+		log.Printf("[verify] %v %v reward %v expect %v diff %v share %v expect %v\n", status, account, stat.Reward, expect.Reward, diff, stat.Share, expect.Share)
+	}
+	// accounts not in file, dust rewards are not sent and not written
+	for _, expect := range expectStats {
+		if _, exist := expectMap[expect.Account]; !exist {
+			continue
+		}
+		account := strings.ToLower(expect.Account.String())
+		if expect.Reward == nil || expect.Reward.Sign() <= 0 ||
+			(title.HasTxHash && expect.Reward.Cmp(dustRewardThreshold) < 0) {
+			log.Printf("[verify] OK %v expect dust reward %v share %v, not in file\n", account, expect.Reward, expect.Share)
+			continue
+		}
+		log.Printf("[verify] MISMATCH %v expect reward %v share %v, not in file\n", account, expect.Reward, expect.Share)
+		mismatches++
+	}
+	return mismatches
+}
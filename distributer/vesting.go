@@ -0,0 +1,341 @@
+package distributer
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/notify"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+// VestingRewardTx reward tx of reward results whose rewards are vesting
+const VestingRewardTx = mongodb.VestingRewardTx
+
+const secondsPerDay uint64 = 86400
+
+// released rewards are sent but claimed amounts of grants are not recorded
+var errClaimedNotRecorded = errors.New("claimed of released grant is not recorded")
+
+// VestingBalance vesting balance of account of reward token
+type VestingBalance struct {
+	Account     string
+	RewardToken string
+	Total       *big.Int
+	Unlocked    *big.Int // vested
+	Locked      *big.Int
+	Claimed     *big.Int
+	Pending     *big.Int // unlocked but not claimed
+	Grants      []*mongodb.MgoVestingGrant
+}
+
+func (opt *Option) isVesting() bool {
+	return params.IsVestingEnabled() && (opt.byWhat == byLiquidMethodID || opt.byWhat == byVolumeMethodID)
+}
+
+func (opt *Option) checkVesting() error {
+	if !opt.isVesting() || opt.DryRun {
+		return nil
+	}
+	if !opt.SaveDB && params.GetConfig().Vesting.Mode == params.VestingModeRelease {
+		return fmt.Errorf("[check option] vesting release mode requires saving to database")
+	}
+	return nil
+}
+
+// open deposits file of vesting contract in contract mode, return nil in other cases
+func (opt *Option) openVestingDeposits(exchange string) (*os.File, error) {
+	vestCfg := params.GetConfig().Vesting
+	if !opt.isVesting() || opt.DryRun || vestCfg.Mode != params.VestingModeContract {
+		return nil, nil
+	}
+	fileName := fmt.Sprintf("%s%s-%sDeposits-%d-%d-%d.csv", opt.programPrefix(), params.GetExchangePairs(exchange), opt.byWhat, opt.StartHeight, opt.EndHeight, time.Now().Unix())
+	file, err := os.OpenFile(filepath.Join(vestCfg.DepositsDir, fileName), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = WriteOutput(file, "#account", "amount", "immediate", "start", "cliff", "end"); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// record vesting grant of reward instead of sending it in full,
+// the immediate part is released right away in release mode.
+func (opt *Option) grantVestingRewards(exchange string, stat *mongodb.AccountStat, depositsFile *os.File) error {
+	account, reward := stat.Account, stat.Reward
+	dustRewardThreshold := params.GetDustRewardThreshold()
+	if reward.Cmp(dustRewardThreshold) < 0 {
+		log.Info("grantVesting ignore dust reward", "account", account.String(), "reward", reward, "dustRewardThreshold", dustRewardThreshold)
+		return errDustReward
+	}
+	if opt.DryRun {
+		log.Info("grantVesting dry run", "account", account.String(), "reward", reward)
+		return nil
+	}
+
+	vestCfg := params.GetConfig().Vesting
+	now := uint64(time.Now().Unix())
+	immediate := new(big.Int).Mul(reward, new(big.Int).SetUint64(vestCfg.ImmediatePercent))
+	immediate.Div(immediate, big.NewInt(100))
+	cliffTime := now + vestCfg.CliffDays*secondsPerDay
+	accountStr := strings.ToLower(account.String())
+	grant := &mongodb.MgoVestingGrant{
+		Key:         mongodb.GetKeyOfVestingGrant(opt.Program, opt.byWhat, exchange, accountStr, opt.StartHeight),
+		ByWhat:      opt.byWhat,
+		Program:     opt.Program,
+		Exchange:    strings.ToLower(exchange),
+		Account:     accountStr,
+		Start:       opt.StartHeight,
+		End:         opt.EndHeight,
+		RewardToken: opt.RewardToken,
+		Mode:        vestCfg.Mode,
+		Total:       reward.String(),
+		Immediate:   immediate.String(),
+		GrantTime:   now,
+		CliffTime:   cliffTime,
+		EndTime:     cliffTime + vestCfg.LinearDays*secondsPerDay,
+		Claimed:     "0",
+		Timestamp:   now,
+	}
+
+	if depositsFile != nil {
+		// released by vesting contract
+		grant.Finished = true
+		err := WriteOutput(depositsFile, accountStr, grant.Total, grant.Immediate,
+			fmt.Sprint(grant.GrantTime), fmt.Sprint(grant.CliffTime), fmt.Sprint(grant.EndTime))
+		if err != nil {
+			return err
+		}
+	}
+	if !opt.SaveDB {
+		return nil
+	}
+	err := mongodb.TryDoTimes("AddVestingGrant "+grant.Key, func() error {
+		return mongodb.AddVestingGrant(grant)
+	})
+	if err != nil {
+		return err
+	}
+	if grant.Mode == params.VestingModeRelease && immediate.Sign() > 0 {
+		_, err = opt.releaseVestedRewards(account, []*mongodb.MgoVestingGrant{grant}, now)
+		if err != nil {
+			// keep pending, will be released by releaser job
+			log.Warn("release immediate vesting rewards failed", "account", accountStr, "immediate", immediate, "err", err)
+		}
+	}
+	return nil
+}
+
+// vested amount of grant at timestamp
+func vestedAmount(grant *mongodb.MgoVestingGrant, now uint64) *big.Int {
+	total, _ := tools.GetBigIntFromString(grant.Total)
+	immediate, _ := tools.GetBigIntFromString(grant.Immediate)
+	if total == nil {
+		return big.NewInt(0)
+	}
+	if immediate == nil {
+		immediate = big.NewInt(0)
+	}
+	switch {
+	case now >= grant.EndTime:
+		return total
+	case now < grant.CliffTime:
+		return immediate
+	}
+	vested := new(big.Int).Sub(total, immediate)
+	vested.Mul(vested, new(big.Int).SetUint64(now-grant.CliffTime))
+	vested.Div(vested, new(big.Int).SetUint64(grant.EndTime-grant.CliffTime))
+	return vested.Add(vested, immediate)
+}
+
+func getClaimedAmount(grant *mongodb.MgoVestingGrant) *big.Int {
+	claimed, _ := tools.GetBigIntFromString(grant.Claimed)
+	if claimed == nil {
+		return big.NewInt(0)
+	}
+	return claimed
+}
+
+// release newly vested rewards of grants of the same account and reward token in one transaction
+func (opt *Option) releaseVestedRewards(account common.Address, grants []*mongodb.MgoVestingGrant, now uint64) (*big.Int, error) {
+	released := big.NewInt(0)
+	vesteds := make([]*big.Int, len(grants))
+	for i, grant := range grants {
+		vesteds[i] = vestedAmount(grant, now)
+		released.Add(released, new(big.Int).Sub(vesteds[i], getClaimedAmount(grant)))
+	}
+	if released.Sign() <= 0 {
+		return released, nil
+	}
+	txHash, err := opt.SendRewardsTransaction(account, released)
+	if err != nil {
+		return nil, err
+	}
+	if txHash == nil {
+		return released, nil // dry run
+	}
+	for i, grant := range grants {
+		total, _ := tools.GetBigIntFromString(grant.Total)
+		finished := total == nil || vesteds[i].Cmp(total) >= 0
+		grant.Claimed = vesteds[i].String()
+		grant.Finished = finished
+		grant.LastReleaseTx = txHash.Hex()
+		err = mongodb.TryDoTimes("UpdateVestingGrantClaimed "+grant.Key, func() error {
+			return mongodb.UpdateVestingGrantClaimed(grant.Key, grant.Claimed, grant.Finished, grant.LastReleaseTx)
+		})
+		if err != nil {
+			log.Error("[vesting] record claimed of released grant failed", "grant", grant.Key, "claimed", grant.Claimed, "txHash", txHash.Hex(), "err", err)
+			return nil, fmt.Errorf("%w: grant %v claimed %v txHash %v, %v", errClaimedNotRecorded, grant.Key, grant.Claimed, txHash.Hex(), err)
+		}
+	}
+	log.Info("[vesting] release vested rewards success", "account", account.String(), "rewardToken", opt.RewardToken, "released", released, "grants", len(grants), "txHash", txHash.Hex())
+	return released, nil
+}
+
+// StartVestingReleaser start periodic job releasing vested rewards in release mode
+func StartVestingReleaser() {
+	if !params.IsVestingEnabled() || params.GetConfig().Vesting.Mode != params.VestingModeRelease {
+		return
+	}
+	vestCfg := params.GetConfig().Vesting
+	args, err := getSendTxArgs(params.GetConfig().Distribute, vestCfg.Sender, vestCfg.KeystoreFile, vestCfg.PasswordFile)
+	if err != nil {
+		log.Error("[vesting] start releaser failed", "err", err)
+		return
+	}
+	interval := time.Duration(vestCfg.GetReleaseInterval()) * time.Second
+	log.Info("[vesting] start releaser job", "sender", args.Sender, "interval", interval.String())
+	go func() {
+		for {
+			err := ReleaseVestedRewards(args, false)
+			if errors.Is(err, errClaimedNotRecorded) {
+				// sent rewards would be released again in next round
+				log.Error("[vesting] stop releaser job", "err", err)
+				notify.Notify(notify.EventReleaseStopped, "vesting releaser stopped, claimed amount of sent release is not recorded", "err", err)
+				return
+			}
+			if err != nil {
+				log.Warn("[vesting] release vested rewards failed", "err", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// ReleaseVestedRewards release newly vested rewards of all unfinished grants,
+// grants of reward programs are released by their own senders.
+func ReleaseVestedRewards(args *BuildTxArgs, dryRun bool) error {
+	grants, err := mongodb.FindUnfinishedVestingGrants(params.VestingModeRelease)
+	if err != nil {
+		return err
+	}
+	// group by program, account and reward token, keep order of first appearance
+	var groupKeys []string
+	groups := make(map[string][]*mongodb.MgoVestingGrant)
+	for _, grant := range grants {
+		groupKey := grant.Program + "/" + grant.Account + ":" + strings.ToLower(grant.RewardToken)
+		if _, exist := groups[groupKey]; !exist {
+			groupKeys = append(groupKeys, groupKey)
+		}
+		groups[groupKey] = append(groups[groupKey], grant)
+	}
+
+	now := uint64(time.Now().Unix())
+	totalReleased := big.NewInt(0)
+	programArgs := make(map[string]*BuildTxArgs)
+	for _, groupKey := range groupKeys {
+		group := groups[groupKey]
+		releaseArgs, errf := getVestingReleaseArgs(args, group[0].Program, programArgs)
+		if errf != nil {
+			log.Warn("[vesting] get release args failed", "program", group[0].Program, "err", errf)
+			continue
+		}
+		opt := &Option{
+			BuildTxArgs: releaseArgs,
+			RewardToken: group[0].RewardToken,
+			DryRun:      dryRun,
+			SaveDB:      true,
+			Program:     group[0].Program,
+		}
+		released, errf := opt.releaseVestedRewards(common.HexToAddress(group[0].Account), group, now)
+		switch {
+		case errf == nil:
+			totalReleased.Add(totalReleased, released)
+		case errf == errDustReward:
+			// keep pending until it accumulates beyond dust threshold
+		case errors.Is(errf, errClaimedNotRecorded):
+			return errf
+		default:
+			log.Warn("[vesting] release vested rewards failed", "program", group[0].Program, "account", group[0].Account, "rewardToken", group[0].RewardToken, "err", errf)
+		}
+	}
+	log.Info("[vesting] release vested rewards finished", "grants", len(grants), "accounts", len(groupKeys), "released", totalReleased, "dryrun", dryRun)
+	return nil
+}
+
+func getVestingReleaseArgs(args *BuildTxArgs, program string, programArgs map[string]*BuildTxArgs) (*BuildTxArgs, error) {
+	prog := params.GetProgramConfig(program)
+	if program == "" || prog == nil || prog.Sender == "" {
+		return args, nil
+	}
+	if progArgs, exist := programArgs[program]; exist {
+		return progArgs, nil
+	}
+	progArgs, err := getSendTxArgs(params.GetConfig().Distribute, prog.Sender, "", "")
+	if err != nil {
+		return nil, err
+	}
+	programArgs[program] = progArgs
+	return progArgs, nil
+}
+
+// GetVestingBalances get vesting balances of account of every reward token
+func GetVestingBalances(account common.Address) ([]*VestingBalance, error) {
+	grants, err := mongodb.FindAccountVestingGrants(account.String())
+	if err != nil {
+		return nil, err
+	}
+	now := uint64(time.Now().Unix())
+	var balances []*VestingBalance
+	balanceMap := make(map[string]*VestingBalance)
+	for _, grant := range grants {
+		rewardToken := strings.ToLower(grant.RewardToken)
+		balance, exist := balanceMap[rewardToken]
+		if !exist {
+			balance = &VestingBalance{
+				Account:     strings.ToLower(account.String()),
+				RewardToken: rewardToken,
+				Total:       big.NewInt(0),
+				Unlocked:    big.NewInt(0),
+				Locked:      big.NewInt(0),
+				Claimed:     big.NewInt(0),
+				Pending:     big.NewInt(0),
+			}
+			balanceMap[rewardToken] = balance
+			balances = append(balances, balance)
+		}
+		total, _ := tools.GetBigIntFromString(grant.Total)
+		if total == nil {
+			continue
+		}
+		vested := vestedAmount(grant, now)
+		claimed := getClaimedAmount(grant)
+		balance.Total.Add(balance.Total, total)
+		balance.Unlocked.Add(balance.Unlocked, vested)
+		balance.Locked.Add(balance.Locked, new(big.Int).Sub(total, vested))
+		balance.Claimed.Add(balance.Claimed, claimed)
+		balance.Pending.Add(balance.Pending, new(big.Int).Sub(vested, claimed))
+		balance.Grants = append(balance.Grants, grant)
+	}
+	return balances, nil
+}
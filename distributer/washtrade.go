@@ -0,0 +1,242 @@
+package distributer
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+// wash trade exclusion reasons
+const (
+	ExcludeMinSwap   = "minswap"   // swap is smaller than min swap amount
+	ExcludeRoundTrip = "roundtrip" // buy and sell netted out within round trip window
+	ExcludeVolumeCap = "volumecap" // volume beyond max account volume
+	ExcludeCluster   = "cluster"   // account is funded from the same source of a cluster
+)
+
+// funding source of account never changes once recorded,
+// accounts without funding source are not queried again in the same cycle.
+var (
+	fundingSources     = make(map[string]string)
+	fundingMisses      = make(map[string]uint64) // account to end of cycle
+	fundingSourcesLock sync.Mutex
+)
+
+type washSwap struct {
+	position  uint64 // block number or timestamp
+	isBuy     bool
+	remaining *big.Int
+}
+
+type washTradeFilter struct {
+	exchange   string
+	start      uint64
+	end        uint64
+	exclusions map[string]*mongodb.MgoRewardExclusion
+}
+
+// find account volumes of exchange in range [start, end),
+// apply account lists and wash trade filters, collect exclusions.
+func findAccountVolumes(exchange string, start, end uint64, useTimestamp bool, exclusions map[string]*mongodb.MgoRewardExclusion) (mongodb.AccountStatSlice, error) {
+	washCfg := params.GetConfig().WashTrade
+	if washCfg == nil {
+		washCfg = &params.WashTradeConfig{} // no filters
+	}
+
+	var histories []*mongodb.MgoVolumeHistory
+	err := mongodb.TryDoTimes("FindVolumeHistories "+exchange, func() (err error) {
+		histories, err = mongodb.FindVolumeHistories(exchange, start, end, useTimestamp)
+		return err
+	})
+	if err != nil {
+		log.Error("[washtrade] find volume histories failed", "exchange", exchange, "start", start, "end", end, "err", err)
+		return nil, err
+	}
+
+	filter := &washTradeFilter{
+		exchange:   strings.ToLower(exchange),
+		start:      start,
+		end:        end,
+		exclusions: make(map[string]*mongodb.MgoRewardExclusion),
+	}
+	minSwapAmount := washCfg.GetMinSwapAmount()
+	accountSwaps := make(map[common.Address][]*washSwap)
+	var lastBlock uint64
+	for _, mh := range histories {
+		if mh.BlockNumber > lastBlock {
+			lastBlock = mh.BlockNumber
+		}
+		volume, _ := tools.GetBigIntFromString(mh.CoinAmount)
+		if volume == nil || volume.Sign() <= 0 {
+			continue
+		}
+		account := common.HexToAddress(mh.Account)
+		if params.IsExcludedRewardAccount(account) {
+			continue
+		}
+		if reason, detail := mongodb.CheckRewardAccount(account, start); reason != "" {
+			filter.exclude(account, reason, volume, 1, detail)
+			continue
+		}
+		if minSwapAmount != nil && volume.Cmp(minSwapAmount) < 0 {
+			filter.exclude(account, ExcludeMinSwap, volume, 1, "")
+			continue
+		}
+		position := mh.BlockNumber
+		if useTimestamp {
+			position = mh.Timestamp
+		}
+		accountSwaps[account] = append(accountSwaps[account], &washSwap{
+			position:  position,
+			isBuy:     mh.LogType == "TokenPurchase",
+			remaining: volume,
+		})
+	}
+
+	maxAccountVolume := washCfg.GetMaxAccountVolume()
+	statMap := make(map[common.Address]*mongodb.AccountStat)
+	for account, swaps := range accountSwaps {
+		if washCfg.RoundTripWindow > 0 {
+			filter.netRoundTrips(account, swaps, washCfg.RoundTripWindow)
+		}
+		volume := big.NewInt(0)
+		var count uint64
+		for _, swap := range swaps {
+			if swap.remaining.Sign() > 0 {
+				volume.Add(volume, swap.remaining)
+				count++
+			}
+		}
+		if maxAccountVolume != nil && volume.Cmp(maxAccountVolume) > 0 {
+			filter.exclude(account, ExcludeVolumeCap, new(big.Int).Sub(volume, maxAccountVolume), 0, "")
+			volume.Set(maxAccountVolume)
+		}
+		if volume.Sign() <= 0 {
+			continue
+		}
+		statMap[account] = &mongodb.AccountStat{
+			Account: account,
+			Share:   volume,
+			Number:  count,
+		}
+	}
+
+	if washCfg.ClusterMinSize > 1 {
+		err = filter.flagClusters(statMap, washCfg.ClusterMinSize, washCfg.ExcludeClusters)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// contract accounts are checked at the last swap of cycle
+	err = applyContractPolicy(statMap, new(big.Int).SetUint64(lastBlock), func(account common.Address, reason string, amount *big.Int, detail string) {
+		filter.exclude(account, reason, amount, 0, detail)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// recalculation of the same cycle replaces previous exclusions
+	for key, exclusion := range filter.exclusions {
+		exclusions[key] = exclusion
+	}
+
+	result := mongodb.ConvertToSortedSlice(statMap)
+	for _, stat := range result {
+		log.Info("find volume result", "account", stat.Account.String(), "volume", stat.Share, "txcount", stat.Number, "start", start, "end", end)
+	}
+	return result, nil
+}
+
+// match each swap with earlier opposite swaps within window,
+// matched amount is excluded from both sides.
+func (f *washTradeFilter) netRoundTrips(account common.Address, swaps []*washSwap, window uint64) {
+	netted := big.NewInt(0)
+	var count int
+	for i, swap := range swaps {
+		for _, prev := range swaps[:i] {
+			if swap.remaining.Sign() <= 0 {
+				break
+			}
+			if prev.isBuy == swap.isBuy || prev.remaining.Sign() <= 0 || swap.position-prev.position > window {
+				continue
+			}
+			matched := prev.remaining
+			if swap.remaining.Cmp(matched) < 0 {
+				matched = swap.remaining
+			}
+			matched = new(big.Int).Set(matched)
+			prev.remaining.Sub(prev.remaining, matched)
+			swap.remaining.Sub(swap.remaining, matched)
+			netted.Add(netted, matched.Mul(matched, big.NewInt(2)))
+			count += 2
+		}
+	}
+	if netted.Sign() > 0 {
+		f.exclude(account, ExcludeRoundTrip, netted, count, fmt.Sprintf("window=%v", window))
+	}
+}
+
+// group accounts by funding source, flag groups reaching min size
+func (f *washTradeFilter) flagClusters(statMap map[common.Address]*mongodb.AccountStat, minSize uint64, exclude bool) error {
+	clusters := make(map[string][]common.Address)
+	for account := range statMap {
+		funder, err := getFundingSource(account, f.end)
+		if err != nil {
+			return err
+		}
+		if funder != "" {
+			clusters[funder] = append(clusters[funder], account)
+		}
+	}
+	for funder, accounts := range clusters {
+		if uint64(len(accounts)) < minSize {
+			continue
+		}
+		detail := fmt.Sprintf("funder=%v&&size=%v", funder, len(accounts))
+		for _, account := range accounts {
+			volume := big.NewInt(0)
+			if exclude {
+				volume = statMap[account].Share
+				delete(statMap, account)
+			}
+			f.exclude(account, ExcludeCluster, volume, 0, detail)
+		}
+	}
+	return nil
+}
+
+func (f *washTradeFilter) exclude(account common.Address, reason string, volume *big.Int, swaps int, detail string) {
+	addRewardExclusion(f.exclusions, f.exchange, account, f.start, f.end, reason, volume, swaps, detail)
+}
+
+func getFundingSource(account common.Address, cycleEnd uint64) (string, error) {
+	accountStr := strings.ToLower(account.String())
+	fundingSourcesLock.Lock()
+	defer fundingSourcesLock.Unlock()
+	if funder, exist := fundingSources[accountStr]; exist {
+		return funder, nil
+	}
+	if missEnd, exist := fundingMisses[accountStr]; exist && missEnd == cycleEnd {
+		return "", nil
+	}
+	funder, err := mongodb.FindFundingSource(accountStr)
+	if err != nil {
+		log.Warn("[washtrade] find funding source failed", "account", accountStr, "err", err)
+		return "", err
+	}
+	if funder != "" {
+		fundingSources[accountStr] = funder
+		delete(fundingMisses, accountStr)
+	} else {
+		fundingMisses[accountStr] = cycleEnd
+	}
+	return funder, nil
+}
@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DefaultLatencyBuckets default buckets of latency histogram (unit second)
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	registryLock sync.RWMutex
+	registry     []*metricVec
+)
+
+type series struct {
+	labelValues []string
+	value       float64
+
+	// histogram only
+	bucketCounts []uint64
+	sum          float64
+	count        uint64
+}
+
+type metricVec struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	buckets    []float64
+
+	lock   sync.Mutex
+	series map[string]*series
+}
+
+func newMetricVec(name, help, metricType string, buckets []float64, labelNames []string) *metricVec {
+	vec := &metricVec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	registryLock.Lock()
+	registry = append(registry, vec)
+	registryLock.Unlock()
+	return vec
+}
+
+// caller must hold the lock
+func (vec *metricVec) getSeries(labelValues []string) *series {
+	if len(labelValues) != len(vec.labelNames) {
+		panic(fmt.Sprintf("metric %v has %v labels, but given %v values", vec.name, len(vec.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, exist := vec.series[key]
+	if !exist {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if vec.metricType == typeHistogram {
+			s.bucketCounts = make([]uint64, len(vec.buckets))
+		}
+		vec.series[key] = s
+	}
+	return s
+}
+
+// Counter monotonically increasing metric
+type Counter struct {
+	vec *metricVec
+}
+
+// NewCounter new counter with label names
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{vec: newMetricVec(name, help, typeCounter, nil, labelNames)}
+}
+
+// Inc increase counter by 1
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increase counter by value (negative value is ignored)
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.vec.lock.Lock()
+	c.vec.getSeries(labelValues).value += value
+	c.vec.lock.Unlock()
+}
+
+// Gauge arbitrary up and down metric
+type Gauge struct {
+	vec *metricVec
+}
+
+// NewGauge new gauge with label names
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{vec: newMetricVec(name, help, typeGauge, nil, labelNames)}
+}
+
+// Set set gauge value
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.vec.lock.Lock()
+	g.vec.getSeries(labelValues).value = value
+	g.vec.lock.Unlock()
+}
+
+// SetBigInt set gauge value from big int
+func (g *Gauge) SetBigInt(value *big.Int, labelValues ...string) {
+	g.Set(BigToFloat(value), labelValues...)
+}
+
+// SetUint64 set gauge value from uint64
+func (g *Gauge) SetUint64(value uint64, labelValues ...string) {
+	g.Set(float64(value), labelValues...)
+}
+
+// Histogram samples observations in buckets
+type Histogram struct {
+	vec *metricVec
+}
+
+// NewHistogram new histogram with buckets and label names
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &Histogram{vec: newMetricVec(name, help, typeHistogram, sorted, labelNames)}
+}
+
+// Observe add a single observation
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.vec.lock.Lock()
+	s := h.vec.getSeries(labelValues)
+	for i, bound := range h.vec.buckets {
+		if value <= bound {
+			s.bucketCounts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+	h.vec.lock.Unlock()
+}
+
+// BigToFloat convert big int to float64 (nil is treated as zero)
+func BigToFloat(value *big.Int) float64 {
+	if value == nil {
+		return 0
+	}
+	f, _ := new(big.Float).SetInt(value).Float64()
+	return f
+}
+
+// WriteAll write all registered metrics in prometheus text exposition format
+func WriteAll(w io.Writer) error {
+	registryLock.RLock()
+	vecs := append([]*metricVec(nil), registry...)
+	registryLock.RUnlock()
+
+	sort.Slice(vecs, func(i, j int) bool { return vecs[i].name < vecs[j].name })
+
+	var sb strings.Builder
+	for _, vec := range vecs {
+		vec.write(&sb)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (vec *metricVec) write(sb *strings.Builder) {
+	vec.lock.Lock()
+	defer vec.lock.Unlock()
+
+	fmt.Fprintf(sb, "# HELP %s %s\n", vec.name, escapeHelp(vec.help))
+	fmt.Fprintf(sb, "# TYPE %s %s\n", vec.name, vec.metricType)
+
+	keys := make([]string, 0, len(vec.series))
+	for key := range vec.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := vec.series[key]
+		if vec.metricType != typeHistogram {
+			fmt.Fprintf(sb, "%s%s %s\n", vec.name, formatLabels(vec.labelNames, s.labelValues, "", ""), formatValue(s.value))
+			continue
+		}
+		for i, bound := range vec.buckets {
+			fmt.Fprintf(sb, "%s_bucket%s %d\n", vec.name, formatLabels(vec.labelNames, s.labelValues, "le", formatValue(bound)), s.bucketCounts[i])
+		}
+		fmt.Fprintf(sb, "%s_bucket%s %d\n", vec.name, formatLabels(vec.labelNames, s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(sb, "%s_sum%s %s\n", vec.name, formatLabels(vec.labelNames, s.labelValues, "", ""), formatValue(s.sum))
+		fmt.Fprintf(sb, "%s_count%s %d\n", vec.name, formatLabels(vec.labelNames, s.labelValues, "", ""), s.count)
+	}
+}
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	parts := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%q", name, values[i]))
+	}
+	if extraName != "" {
+		parts = append(parts, fmt.Sprintf("%s=%q", extraName, extraValue))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeHelp(help string) string {
+	help = strings.ReplaceAll(help, `\`, `\\`)
+	return strings.ReplaceAll(help, "\n", `\n`)
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/params"
+)
+
+const metricsPath = "/metrics"
+
+// Handler http handler serving all registered metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := WriteAll(w); err != nil {
+			log.Warn("[metrics] write metrics failed", "err", err)
+		}
+	})
+}
+
+// Start start metrics http server if enabled in config
+func Start() {
+	metricsCfg := params.GetConfig().Metrics
+	if metricsCfg == nil || !metricsCfg.Enable {
+		log.Info("[metrics] metrics server is disabled")
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, Handler())
+
+	server := &http.Server{
+		Addr:         metricsCfg.ListenAddress,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Info("[metrics] start metrics server", "address", metricsCfg.ListenAddress, "path", metricsPath)
+		if err := server.ListenAndServe(); err != nil {
+			log.Error("[metrics] metrics server stopped", "err", err)
+		}
+	}()
+}
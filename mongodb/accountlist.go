@@ -0,0 +1,108 @@
+package mongodb
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+// account list names
+const (
+	DenyList  = "deny"
+	AllowList = "allow"
+)
+
+// exclusion reasons of account lists
+const (
+	ExcludeDenyList   = "denylist"   // account is in active denylist
+	ExcludeNotAllowed = "notallowed" // allowlist is active but account is not in it
+	ExcludeNoLists    = "nolists"    // account lists can not be loaded, exclude all accounts
+)
+
+// ErrAccountListsUnavailable account lists are not loaded from database
+var ErrAccountListsUnavailable = errors.New("account lists are unavailable without database")
+
+// reload account lists from database after expired,
+// so changes by other processes take effect soon.
+const accountListsExpiration = time.Minute
+
+var (
+	accountListsLock     sync.Mutex
+	accountLists         []*MgoAccountListEntry
+	accountListsLoaded   bool
+	accountListsLoadTime time.Time
+)
+
+func invalidateAccountLists() {
+	accountListsLock.Lock()
+	accountListsLoadTime = time.Time{}
+	accountListsLock.Unlock()
+}
+
+func getAccountLists() ([]*MgoAccountListEntry, error) {
+	if !HasSession() {
+		return nil, ErrAccountListsUnavailable
+	}
+	accountListsLock.Lock()
+	defer accountListsLock.Unlock()
+	if time.Since(accountListsLoadTime) < accountListsExpiration {
+		return accountLists, nil
+	}
+	entries, err := FindAccountListEntries("")
+	if err != nil {
+		if !accountListsLoaded {
+			log.Warn("[mongodb] load account lists failed", "err", err)
+			return nil, err
+		}
+		log.Warn("[mongodb] load account lists failed, use last loaded", "err", err)
+		return accountLists, nil
+	}
+	accountLists = entries
+	accountListsLoaded = true
+	accountListsLoadTime = time.Now()
+	return accountLists, nil
+}
+
+// CheckAccountListsAvailable check account lists can be loaded,
+// call it before checking accounts to fail with error instead of excluding all accounts.
+func CheckAccountListsAvailable() error {
+	_, err := getAccountLists()
+	return err
+}
+
+// CheckRewardAccount check account with denylist and allowlist active at position (block height or timestamp),
+// return exclusion reason and detail, empty reason means account is not excluded.
+// denylist takes precedence over allowlist, and allowlist only takes effect if any entry is active.
+// all accounts are excluded if account lists can not be loaded.
+func CheckRewardAccount(account common.Address, position uint64) (reason, detail string) {
+	entries, err := getAccountLists()
+	if err != nil {
+		return ExcludeNoLists, err.Error()
+	}
+	accountStr := strings.ToLower(account.String())
+	hasAllowList, isAllowed := false, false
+	for _, entry := range entries {
+		if !entry.IsActiveAt(position) {
+			continue
+		}
+		switch entry.List {
+		case DenyList:
+			if entry.Account == accountStr {
+				return ExcludeDenyList, entry.Reason
+			}
+		case AllowList:
+			hasAllowList = true
+			if entry.Account == accountStr {
+				isAllowed = true
+			}
+		}
+	}
+	if hasAllowList && !isAllowed {
+		return ExcludeNotAllowed, ""
+	}
+	return "", ""
+}
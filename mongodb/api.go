@@ -21,13 +21,18 @@ const (
 
 // TryDoTimes try do again if meet error
 func TryDoTimes(name string, f func() error) (err error) {
+	operation := getOperationName(name)
 	for i := 0; i < retryDBCount; i++ {
+		if i > 0 {
+			writeRetryCounter.Inc(operation)
+		}
 		err = f()
 		if err == nil || mgo.IsDup(err) {
 			return nil
 		}
 		time.Sleep(retryDBInterval)
 	}
+	writeFailureCounter.Inc(operation)
 	log.Warn("[mongodb] TryDoTimes", "name", name, "times", retryDBCount, "err", err)
 	return err
 }
@@ -120,6 +125,47 @@ func AddAccount(ma *MgoAccount) error {
 	return err
 }
 
+// AddStaker add staker, keep the first seen if exist
+func AddStaker(ms *MgoStaker) error {
+	err := collectionStaker.Insert(ms)
+	switch {
+	case err == nil:
+		log.Info("[mongodb] AddStaker success", "staker", ms)
+	case mgo.IsDup(err):
+		return nil
+	default:
+		log.Warn("[mongodb] AddStaker failed", "staker", ms, "err", err)
+	}
+	return err
+}
+
+// AddReferral add referral registration, return false if referee is already registered
+func AddReferral(mr *MgoReferral) (bool, error) {
+	err := collectionReferral.Insert(mr)
+	switch {
+	case err == nil:
+		log.Info("[mongodb] AddReferral success", "referral", mr)
+	case mgo.IsDup(err):
+		return false, nil
+	default:
+		log.Warn("[mongodb] AddReferral failed", "referral", mr, "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// AddYield add or update yield
+func AddYield(my *MgoYield) error {
+	_, err := collectionYield.UpsertId(my.Key, my)
+	switch {
+	case err == nil:
+		log.Info("[mongodb] AddYield success", "yield", my)
+	default:
+		log.Warn("[mongodb] AddYield failed", "yield", my, "err", err)
+	}
+	return err
+}
+
 // AddTokenAccount add token account
 func AddTokenAccount(ma *MgoTokenAccount) error {
 	err := collectionTokenAccount.Insert(ma)
@@ -175,6 +221,9 @@ func getVolumeRewardUpdateItems(mr *MgoVolumeRewardResult) bson.M {
 	if mr.RewardTx != "" {
 		updates["rewardTx"] = mr.RewardTx
 	}
+	if mr.RewardUSD != "" {
+		updates["rewardUSD"] = mr.RewardUSD
+	}
 	return updates
 }
 
@@ -196,6 +245,44 @@ func AddVolumeRewardResult(mr *MgoVolumeRewardResult) (err error) {
 	return err
 }
 
+func getReferralRewardUpdateItems(mr *MgoReferralRewardResult) bson.M {
+	updates := bson.M{}
+	if mr.Reward != "" {
+		updates["reward"] = mr.Reward
+	}
+	if mr.RefereeReward != "" {
+		updates["refereeReward"] = mr.RefereeReward
+	}
+	if mr.Referees != 0 {
+		updates["referees"] = mr.Referees
+	}
+	if mr.RewardTx != "" {
+		updates["rewardTx"] = mr.RewardTx
+	}
+	if mr.RewardUSD != "" {
+		updates["rewardUSD"] = mr.RewardUSD
+	}
+	return updates
+}
+
+// AddReferralRewardResult add referral reward result
+func AddReferralRewardResult(mr *MgoReferralRewardResult) (err error) {
+	old, _ := FindReferralRewardResult(mr.Key)
+	if old == nil {
+		err = collectionReferralRewardResult.Insert(mr)
+	} else {
+		updates := getReferralRewardUpdateItems(mr)
+		err = collectionReferralRewardResult.UpdateId(mr.Key, bson.M{"$set": updates})
+	}
+	switch {
+	case err == nil:
+		log.Info("[mongodb] AddReferralRewardResult success", "reward", mr, "isUpdate", old != nil)
+	default:
+		log.Warn("[mongodb] AddReferralRewardResult failed", "reward", mr, "isUpdate", old != nil, "err", err)
+	}
+	return err
+}
+
 func getLiquidRewardUpdateItems(mr *MgoLiquidRewardResult) bson.M {
 	updates := bson.M{}
 	if mr.Reward != "" {
@@ -204,12 +291,18 @@ func getLiquidRewardUpdateItems(mr *MgoLiquidRewardResult) bson.M {
 	if mr.Liquidity != "" {
 		updates["liquidity"] = mr.Liquidity
 	}
+	if mr.Balance != "" {
+		updates["balance"] = mr.Balance
+	}
 	if mr.Height != 0 {
 		updates["height"] = mr.Height
 	}
 	if mr.RewardTx != "" {
 		updates["rewardTx"] = mr.RewardTx
 	}
+	if mr.RewardUSD != "" {
+		updates["rewardUSD"] = mr.RewardUSD
+	}
 	return updates
 }
 
@@ -231,6 +324,81 @@ func AddLiquidRewardResult(mr *MgoLiquidRewardResult) (err error) {
 	return err
 }
 
+// AddNonceRecord add nonce record
+func AddNonceRecord(mr *MgoNonceRecord) error {
+	_, err := collectionNonceRecord.UpsertId(mr.Key, mr)
+	switch {
+	case err == nil:
+		log.Info("[mongodb] AddNonceRecord success", "sender", mr.Sender, "nonce", mr.Nonce, "txhash", mr.TxHash)
+	default:
+		log.Warn("[mongodb] AddNonceRecord failed", "sender", mr.Sender, "nonce", mr.Nonce, "txhash", mr.TxHash, "err", err)
+	}
+	return err
+}
+
+// AddRewardExclusion add reward exclusion
+func AddRewardExclusion(me *MgoRewardExclusion) error {
+	_, err := collectionRewardExclusion.UpsertId(me.Key, me)
+	switch {
+	case err == nil:
+		log.Info("[mongodb] AddRewardExclusion success", "exclusion", me)
+	default:
+		log.Warn("[mongodb] AddRewardExclusion failed", "exclusion", me, "err", err)
+	}
+	return err
+}
+
+// AddAccountListEntry add or update account list entry
+func AddAccountListEntry(me *MgoAccountListEntry) error {
+	_, err := collectionAccountList.UpsertId(me.Key, me)
+	switch {
+	case err == nil:
+		log.Info("[mongodb] AddAccountListEntry success", "entry", me)
+		invalidateAccountLists()
+	default:
+		log.Warn("[mongodb] AddAccountListEntry failed", "entry", me, "err", err)
+	}
+	return err
+}
+
+// AddVestingGrant add vesting grant
+func AddVestingGrant(mg *MgoVestingGrant) error {
+	err := collectionVestingGrant.Insert(mg)
+	switch {
+	case err == nil:
+		log.Info("[mongodb] AddVestingGrant success", "grant", mg)
+	case mgo.IsDup(err):
+		log.Warn("[mongodb] AddVestingGrant duplicated", "key", mg.Key)
+	default:
+		log.Warn("[mongodb] AddVestingGrant failed", "grant", mg, "err", err)
+	}
+	return err
+}
+
+// AddUnspentReward add or update unspent reward
+func AddUnspentReward(mu *MgoUnspentReward) error {
+	_, err := collectionUnspentReward.UpsertId(mu.Key, mu)
+	switch {
+	case err == nil:
+		log.Info("[mongodb] AddUnspentReward success", "unspent", mu)
+	default:
+		log.Warn("[mongodb] AddUnspentReward failed", "unspent", mu, "err", err)
+	}
+	return err
+}
+
+// AddPendingReward add or update pending reward
+func AddPendingReward(mp *MgoPendingReward) error {
+	_, err := collectionPendingReward.UpsertId(mp.Key, mp)
+	switch {
+	case err == nil:
+		log.Info("[mongodb] AddPendingReward success", "pending", mp)
+	default:
+		log.Warn("[mongodb] AddPendingReward failed", "pending", mp, "err", err)
+	}
+	return err
+}
+
 // --------------- update ---------------------------------
 
 // UpdateSyncInfo update sync info
@@ -243,9 +411,9 @@ func UpdateSyncInfo(number uint64, hash string, timestamp uint64) error {
 		}})
 }
 
-// UpdateVolumeWithReceipt update volume
-func UpdateVolumeWithReceipt(exr *ExchangeReceipt, blockHash string, blockNumber, timestamp uint64) error {
-	key := GetKeyOfExchangeAndTimestamp(exr.Exchange, timestamp)
+// UpdateVolumeWithReceipt update volume of bucket of interval seconds begin at timestamp
+func UpdateVolumeWithReceipt(exr *ExchangeReceipt, blockHash string, blockNumber, timestamp, interval uint64) error {
+	key := GetKeyOfVolume(exr.Exchange, timestamp, interval)
 	curVol, err := FindVolume(key)
 
 	if curVol == nil && err != mgo.ErrNotFound {
@@ -285,11 +453,88 @@ func UpdateVolumeWithReceipt(exr *ExchangeReceipt, blockHash string, blockNumber
 		BlockNumber:    blockNumber,
 		BlockHash:      blockHash,
 		Timestamp:      timestamp,
+		Interval:       getVolumeInterval(timestamp, interval),
 	}, true)
 }
 
+// interval field of volume, empty for daily volume of UTC day,
+// days of other boundaries are stored apart with interval field.
+func getVolumeInterval(timestamp, interval uint64) uint64 {
+	if interval == 0 || (interval == params.SecondsPerDay && timestamp%interval == 0) {
+		return 0
+	}
+	return interval
+}
+
+// UpdateVolumeUSD update USD values of volume
+func UpdateVolumeUSD(key, coinUSD, volumeUSD string) error {
+	return collectionVolume.UpdateId(key,
+		bson.M{"$set": bson.M{
+			"coinUSD":   coinUSD,
+			"volumeUSD": volumeUSD,
+		}})
+}
+
+// UpdateVestingGrantClaimed update claimed rewards of vesting grant
+func UpdateVestingGrantClaimed(key, claimed string, finished bool, releaseTx string) error {
+	return collectionVestingGrant.UpdateId(key,
+		bson.M{"$set": bson.M{
+			"claimed":       claimed,
+			"finished":      finished,
+			"lastReleaseTx": releaseTx,
+			"timestamp":     uint64(time.Now().Unix()),
+		}})
+}
+
+// UpdateUnspentRewardApplied update carried unspent reward as applied to cycle
+func UpdateUnspentRewardApplied(key string, appliedStart uint64) error {
+	return collectionUnspentReward.UpdateId(key,
+		bson.M{"$set": bson.M{
+			"status":       UnspentStatusApplied,
+			"appliedStart": appliedStart,
+			"timestamp":    uint64(time.Now().Unix()),
+		}})
+}
+
+// --------------- delete ---------------------------------
+
+// DeleteNonceRecordsBelow delete nonce records of sender below nonce
+func DeleteNonceRecordsBelow(sender string, nonce uint64) error {
+	_, err := collectionNonceRecord.RemoveAll(bson.M{"sender": strings.ToLower(sender), "nonce": bson.M{"$lt": nonce}})
+	return err
+}
+
+// DeleteAccountListEntry delete account list entry
+func DeleteAccountListEntry(list, account string) error {
+	err := collectionAccountList.RemoveId(GetKeyOfAccountListEntry(list, account))
+	if err == nil {
+		invalidateAccountLists()
+	}
+	return err
+}
+
 // --------------- find ---------------------------------
 
+// FindFirstBlockSince find the first synced block of timestamp not before the given one
+func FindFirstBlockSince(timestamp uint64) (*MgoBlock, error) {
+	var res MgoBlock
+	err := collectionBlock.Find(bson.M{"timestamp": bson.M{"$gte": timestamp}}).Sort("timestamp").Limit(1).One(&res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// FindLatestBlock find the latest synced block
+func FindLatestBlock() (*MgoBlock, error) {
+	var res MgoBlock
+	err := collectionBlock.Find(nil).Sort("-number").Limit(1).One(&res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
 // FindBlocksInRange find blocks
 func FindBlocksInRange(start, end uint64) ([]*MgoBlock, error) {
 	count := int(end - start + 1)
@@ -322,6 +567,31 @@ func FindLatestLiquidity(exchange string) (*MgoLiquidity, error) {
 	return &res, nil
 }
 
+// FindLiquidityAtOrBefore find latest liquidity not after timestamp
+func FindLiquidityAtOrBefore(exchange string, timestamp uint64) (*MgoLiquidity, error) {
+	var res MgoLiquidity
+	err := collectionLiquidity.Find(bson.M{"exchange": exchange, "timestamp": bson.M{"$lte": timestamp}}).Sort("-timestamp").Limit(1).One(&res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// FindLiquiditiesInRange find liquidity snapshots in range [start, end),
+// only snapshots at bucket boundaries of interval seconds if interval is not zero
+func FindLiquiditiesInRange(exchange string, start, end, interval uint64) ([]*MgoLiquidity, error) {
+	timeQuery := bson.M{"$gte": start, "$lt": end}
+	if interval != 0 {
+		timeQuery["$mod"] = []uint64{interval, params.GetConfig().Sync.GetBucketRemainder(interval)}
+	}
+	var result []*MgoLiquidity
+	err := collectionLiquidity.Find(bson.M{"exchange": exchange, "timestamp": timeQuery}).Sort("timestamp").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // FindLiquidity find by key
 func FindLiquidity(key string) (*MgoLiquidity, error) {
 	var res MgoLiquidity
@@ -352,16 +622,52 @@ func FindLiquidRewardResult(key string) (*MgoLiquidRewardResult, error) {
 	return &res, nil
 }
 
-// FindLatestVolume find latest volume
+// FindReferralRewardResult find referral reward result
+func FindReferralRewardResult(key string) (*MgoReferralRewardResult, error) {
+	var res MgoReferralRewardResult
+	err := collectionReferralRewardResult.FindId(key).One(&res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// FindLatestVolume find latest daily volume
 func FindLatestVolume(exchange string) (*MgoVolume, error) {
 	var res MgoVolume
-	err := collectionVolume.Find(bson.M{"exchange": exchange}).Sort("-timestamp").Limit(1).One(&res)
+	err := collectionVolume.Find(bson.M{"exchange": exchange, "interval": nil}).Sort("-timestamp").Limit(1).One(&res)
 	if err != nil {
 		return nil, err
 	}
 	return &res, nil
 }
 
+// FindVolumesInRange find volumes of buckets of interval seconds in range [start, end),
+// buckets are aligned to the configed day boundary, zero interval means daily volumes of UTC days.
+func FindVolumesInRange(exchange string, start, end, interval uint64) ([]*MgoVolume, error) {
+	timeQuery := bson.M{"$gte": start, "$lt": end}
+	var remainder uint64
+	if interval != 0 {
+		remainder = params.GetConfig().Sync.GetBucketRemainder(interval)
+	}
+	var intervalQuery interface{}                    // nil for daily volumes of UTC days
+	if getVolumeInterval(remainder, interval) != 0 { // bucket begins are all of the remainder
+		intervalQuery = interval
+		timeQuery["$mod"] = []uint64{interval, remainder}
+	}
+	query := bson.M{
+		"exchange":  strings.ToLower(exchange),
+		"timestamp": timeQuery,
+		"interval":  intervalQuery,
+	}
+	var result []*MgoVolume
+	err := collectionVolume.Find(query).Sort("timestamp").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // FindVolume find by key
 func FindVolume(key string) (*MgoVolume, error) {
 	var res MgoVolume
@@ -422,6 +728,7 @@ func FindAccountVolumes(exchange string, startHeight, endHeight uint64, useTimes
 	iter := collectionVolumeHistory.Find(bson.M{"$and": queries}).Iter()
 
 	statMap := make(map[common.Address]*AccountStat)
+	excludedVolume := big.NewInt(0)
 
 	var mh MgoVolumeHistory
 	for iter.Next(&mh) {
@@ -434,6 +741,11 @@ func FindAccountVolumes(exchange string, startHeight, endHeight uint64, useTimes
 		if params.IsExcludedRewardAccount(account) {
 			continue
 		}
+		if reason, detail := CheckRewardAccount(account, startHeight); reason != "" {
+			log.Info("exclude listed account volume", "account", mh.Account, "volume", volume, "reason", reason, "detail", detail, "start", startHeight, "end", endHeight)
+			excludedVolume.Add(excludedVolume, volume)
+			continue
+		}
 		stat, exist := statMap[account]
 		if exist {
 			stat.Share.Add(stat.Share, volume)
@@ -450,5 +762,442 @@ func FindAccountVolumes(exchange string, startHeight, endHeight uint64, useTimes
 	for _, stat := range result {
 		log.Info("find volume result", "account", stat.Account.String(), "volume", stat.Share, "txcount", stat.Number, "start", startHeight, "end", endHeight)
 	}
+	if excludedVolume.Sign() > 0 {
+		log.Info("find volume excluded by account lists", "exchange", exchange, "volume", excludedVolume, "start", startHeight, "end", endHeight)
+	}
 	return result
 }
+
+// FindNonceRecords find nonce records of sender
+func FindNonceRecords(sender string) ([]*MgoNonceRecord, error) {
+	var result []*MgoNonceRecord
+	err := collectionNonceRecord.Find(bson.M{"sender": strings.ToLower(sender)}).Sort("nonce").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AddSpending add spending
+func AddSpending(ms *MgoSpending) error {
+	err := collectionSpending.Insert(ms)
+	switch {
+	case err == nil:
+		log.Info("[mongodb] AddSpending success", "spending", ms)
+	case mgo.IsDup(err):
+		log.Warn("[mongodb] AddSpending duplicated", "key", ms.Key)
+		return nil
+	default:
+		log.Warn("[mongodb] AddSpending failed", "spending", ms, "err", err)
+	}
+	return err
+}
+
+// SumRewardsSentSince sum rewards of token sent since timestamp in all reward results
+// and spendings, rewards granted as vesting are counted when they are released.
+func SumRewardsSentSince(program, rewardToken string, since uint64) (*big.Int, error) {
+	query := bson.M{
+		"program":     getProgramQuery(program),
+		"rewardToken": bson.RegEx{Pattern: "^" + rewardToken + "$", Options: "i"},
+		"rewardTx":    bson.M{"$nin": []string{"", VestingRewardTx}},
+		"timestamp":   bson.M{"$gte": since},
+	}
+	spendingQuery := bson.M{
+		"program":     query["program"],
+		"rewardToken": query["rewardToken"],
+		"timestamp":   query["timestamp"],
+	}
+	total := big.NewInt(0)
+	var res struct {
+		Reward string `bson:"reward"`
+	}
+	for _, coll := range []*mgo.Collection{collectionVolumeRewardResult, collectionLiquidRewardResult, collectionReferralRewardResult, collectionSpending} {
+		collQuery := query
+		if coll == collectionSpending {
+			collQuery = spendingQuery
+		}
+		iter := coll.Find(collQuery).Select(bson.M{"reward": 1}).Iter()
+		for iter.Next(&res) {
+			reward, err := tools.GetBigIntFromString(res.Reward)
+			if err == nil && reward != nil {
+				total.Add(total, reward)
+			}
+		}
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return total, nil
+}
+
+func getRewardResultsInRangeQuery(exchange string, start, end uint64) bson.M {
+	query := bson.M{"start": bson.M{"$gte": start, "$lt": end}}
+	if exchange != "" {
+		query["exchange"] = strings.ToLower(exchange)
+	}
+	return query
+}
+
+// FindVolumeRewardResultsInRange find volume reward results of cycles start in range [start, end)
+func FindVolumeRewardResultsInRange(exchange string, start, end uint64) ([]*MgoVolumeRewardResult, error) {
+	var result []*MgoVolumeRewardResult
+	err := collectionVolumeRewardResult.Find(getRewardResultsInRangeQuery(exchange, start, end)).Sort("start").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindLiquidRewardResultsInRange find liquid reward results of cycles start in range [start, end)
+func FindLiquidRewardResultsInRange(exchange string, start, end uint64) ([]*MgoLiquidRewardResult, error) {
+	var result []*MgoLiquidRewardResult
+	err := collectionLiquidRewardResult.Find(getRewardResultsInRangeQuery(exchange, start, end)).Sort("start").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindReferralRewardResultsInRange find referral reward results of cycles start in range [start, end)
+func FindReferralRewardResultsInRange(exchange string, start, end uint64) ([]*MgoReferralRewardResult, error) {
+	var result []*MgoReferralRewardResult
+	err := collectionReferralRewardResult.Find(getRewardResultsInRangeQuery(exchange, start, end)).Sort("start").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindAccountVolumeHistories find volume histories of account in range [start, end)
+func FindAccountVolumeHistories(exchange, account string, startHeight, endHeight uint64, useTimestamp bool) ([]*MgoVolumeHistory, error) {
+	rangeKey := "blockNumber"
+	if useTimestamp {
+		rangeKey = "timestamp"
+	}
+	query := bson.M{
+		"exchange": strings.ToLower(exchange),
+		"account":  strings.ToLower(account),
+		rangeKey:   bson.M{"$gte": startHeight, "$lt": endHeight},
+	}
+	var result []*MgoVolumeHistory
+	err := collectionVolumeHistory.Find(query).Sort("blockNumber", "logIndex").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindVolumeHistories find volume histories of exchange in range [start, end)
+func FindVolumeHistories(exchange string, startHeight, endHeight uint64, useTimestamp bool) ([]*MgoVolumeHistory, error) {
+	rangeKey := "blockNumber"
+	if useTimestamp {
+		rangeKey = "timestamp"
+	}
+	query := bson.M{
+		"exchange": strings.ToLower(exchange),
+		rangeKey:   bson.M{"$gte": startHeight, "$lt": endHeight},
+	}
+	var result []*MgoVolumeHistory
+	err := collectionVolumeHistory.Find(query).Sort("blockNumber", "logIndex").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindRewardExclusions find reward exclusions of account of cycles start in [start, end)
+func FindRewardExclusions(exchange, account string, start, end uint64) ([]*MgoRewardExclusion, error) {
+	query := bson.M{
+		"exchange": strings.ToLower(exchange),
+		"account":  strings.ToLower(account),
+		"start":    bson.M{"$gte": start, "$lt": end},
+	}
+	var result []*MgoRewardExclusion
+	err := collectionRewardExclusion.Find(query).Sort("start").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindFundingSource find sender of the earliest recorded token or coin transfer to account,
+// transfers from exchanges and zero address are not funding.
+func FindFundingSource(account string) (string, error) {
+	account = strings.ToLower(account)
+	query := bson.M{"$or": []bson.M{
+		{"erc20Receipts": bson.M{"$elemMatch": bson.M{"logType": "Transfer", "to": account}}},
+		{"to": account, "value": bson.M{"$ne": "0"}, "status": 1},
+	}}
+	iter := collectionTransaction.Find(query).Sort("blockNumber", "transactionIndex").Limit(100).Iter()
+	var mt MgoTransaction
+	for iter.Next(&mt) {
+		if mt.To == account && mt.Value != "0" && mt.Status == 1 {
+			from := common.HexToAddress(mt.From)
+			if !params.IsInAllExchanges(from) {
+				_ = iter.Close()
+				return mt.From, nil
+			}
+		}
+		for _, receipt := range mt.Erc20Receipts {
+			if receipt.LogType != "Transfer" || receipt.To != account {
+				continue
+			}
+			from := common.HexToAddress(receipt.From)
+			if from == (common.Address{}) || params.IsInAllExchanges(from) {
+				continue
+			}
+			_ = iter.Close()
+			return receipt.From, nil
+		}
+	}
+	return "", iter.Close()
+}
+
+// FindAccountListEntries find entries of list, find all lists if list is empty
+func FindAccountListEntries(list string) ([]*MgoAccountListEntry, error) {
+	query := bson.M{}
+	if list != "" {
+		query["list"] = strings.ToLower(list)
+	}
+	var result []*MgoAccountListEntry
+	err := collectionAccountList.Find(query).Sort("list", "account").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindUnfinishedVestingGrants find vesting grants of mode which are not fully released
+func FindUnfinishedVestingGrants(mode string) ([]*MgoVestingGrant, error) {
+	var result []*MgoVestingGrant
+	err := collectionVestingGrant.Find(bson.M{"mode": mode, "finished": false}).Sort("account", "grantTime").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindAccountVestingGrants find all vesting grants of account
+func FindAccountVestingGrants(account string) ([]*MgoVestingGrant, error) {
+	var result []*MgoVestingGrant
+	err := collectionVestingGrant.Find(bson.M{"account": strings.ToLower(account)}).Sort("grantTime").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindCarriedUnspentRewards find carried unspent rewards of cycles start before 'before'
+func FindCarriedUnspentRewards(program, byWhat, rewardToken string, before uint64) ([]*MgoUnspentReward, error) {
+	query := bson.M{
+		"program":     getProgramQuery(program),
+		"bywhat":      byWhat,
+		"rewardToken": strings.ToLower(rewardToken),
+		"status":      UnspentStatusCarried,
+		"start":       bson.M{"$lt": before},
+	}
+	var result []*MgoUnspentReward
+	err := collectionUnspentReward.Find(query).Sort("start").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindPendingRewards find pending rewards of reward token with positive balance
+func FindPendingRewards(program, rewardToken string) ([]*MgoPendingReward, error) {
+	query := bson.M{
+		"program":     getProgramQuery(program),
+		"rewardToken": strings.ToLower(rewardToken),
+		"amount":      bson.M{"$nin": []string{"", "0"}},
+	}
+	var result []*MgoPendingReward
+	err := collectionPendingReward.Find(query).All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindPendingReward find pending reward of account
+func FindPendingReward(program, rewardToken, account string) (*MgoPendingReward, error) {
+	var res MgoPendingReward
+	err := collectionPendingReward.FindId(GetKeyOfPendingReward(program, rewardToken, account)).One(&res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// FindStakers find stakers of contract first seen not after block number
+func FindStakers(contract string, blockNumber uint64) ([]*MgoStaker, error) {
+	query := bson.M{
+		"contract":    strings.ToLower(contract),
+		"blockNumber": bson.M{"$lte": blockNumber},
+	}
+	var result []*MgoStaker
+	err := collectionStaker.Find(query).All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindDistributeInfosInRange find distribute infos of exchange of cycles ended in time range [start, end)
+func FindDistributeInfosInRange(exchange string, start, end uint64) ([]*MgoDistributeInfo, error) {
+	query, err := getCycleEndedInRangeQuery(exchange, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var result []*MgoDistributeInfo
+	err = collectionDistributeInfo.Find(query).Sort("start").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindLatestYield find latest exchange yield
+func FindLatestYield(exchange string) (*MgoYield, error) {
+	var res MgoYield
+	query := bson.M{"exchange": strings.ToLower(exchange), "account": bson.M{"$in": []interface{}{"", nil}}}
+	err := collectionYield.Find(query).Sort("-timestamp").Limit(1).One(&res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// FindYields find yields of exchange in time range [start, end),
+// yields of exchange if account is empty, otherwise of account
+func FindYields(exchange, account string, start, end uint64) ([]*MgoYield, error) {
+	query := bson.M{
+		"exchange":  strings.ToLower(exchange),
+		"timestamp": bson.M{"$gte": start, "$lt": end},
+	}
+	if account == "" {
+		query["account"] = bson.M{"$in": []interface{}{"", nil}}
+	} else {
+		query["account"] = strings.ToLower(account)
+	}
+	var result []*MgoYield
+	err := collectionYield.Find(query).Sort("timestamp").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindLiquidRewardResultsByTime find liquid reward results of exchange of cycles ended in time range [start, end)
+func FindLiquidRewardResultsByTime(exchange string, start, end uint64) ([]*MgoLiquidRewardResult, error) {
+	query, err := getCycleEndedInRangeQuery(exchange, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var result []*MgoLiquidRewardResult
+	err = collectionLiquidRewardResult.Find(query).Sort("start").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindReferral find referral registration of referee
+func FindReferral(referee string) (*MgoReferral, error) {
+	var res MgoReferral
+	err := collectionReferral.FindId(strings.ToLower(referee)).One(&res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// FindReferrals find referral registrations of referees
+func FindReferrals(referees []string) ([]*MgoReferral, error) {
+	keys := make([]string, len(referees))
+	for i, referee := range referees {
+		keys[i] = strings.ToLower(referee)
+	}
+	var result []*MgoReferral
+	err := collectionReferral.Find(bson.M{"_id": bson.M{"$in": keys}}).All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindReferralsOfReferrer find referral registrations of referrer, all if referrer is empty
+func FindReferralsOfReferrer(referrer string) ([]*MgoReferral, error) {
+	query := bson.M{}
+	if referrer != "" {
+		query["referrer"] = strings.ToLower(referrer)
+	}
+	var result []*MgoReferral
+	err := collectionReferral.Find(query).Sort("blockNumber").All(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindDistributeInfo find latest distribute info of cycle
+func FindDistributeInfo(program, byWhat string, start uint64) (*MgoDistributeInfo, error) {
+	var res MgoDistributeInfo
+	err := collectionDistributeInfo.Find(bson.M{"program": getProgramQuery(program), "bywhat": byWhat, "start": start}).Sort("-timestamp").Limit(1).One(&res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// default program is recorded without program field
+func getProgramQuery(program string) interface{} {
+	if program == "" {
+		return bson.M{"$in": []interface{}{"", nil}}
+	}
+	return program
+}
+
+// query of cycles of exchange ended in time range [start, end),
+// cycle end is compared in heights of synced blocks if distribution does not use time measurement.
+func getCycleEndedInRangeQuery(exchange string, start, end uint64) (bson.M, error) {
+	distCfg := params.GetConfig().Distribute
+	if distCfg == nil || !distCfg.UseTimeMeasurement {
+		var err error
+		start, end, err = getHeightRangeOfTime(start, end)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bson.M{
+		"exchange": strings.ToLower(exchange),
+		"end":      bson.M{"$gt": start, "$lte": end},
+	}, nil
+}
+
+// convert time range [start, end) to height range [start, end) of synced blocks,
+// blocks not synced yet are treated as after the latest synced block.
+func getHeightRangeOfTime(start, end uint64) (startHeight, endHeight uint64, err error) {
+	latest, err := FindLatestBlock()
+	if err != nil {
+		return 0, 0, err
+	}
+	heightSince := func(timestamp uint64) (uint64, error) {
+		block, errf := FindFirstBlockSince(timestamp)
+		switch {
+		case errf == nil:
+			return block.Number, nil
+		case errf == mgo.ErrNotFound:
+			return latest.Number + 1, nil
+		default:
+			return 0, errf
+		}
+	}
+	if startHeight, err = heightSince(start); err != nil {
+		return 0, 0, err
+	}
+	if endHeight, err = heightSince(end); err != nil {
+		return 0, 0, err
+	}
+	return startHeight, endHeight, nil
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/notify"
 	"gopkg.in/mgo.v2"
 )
 
@@ -23,6 +24,11 @@ func MongoServerInit(addrs []string, dbname, user, pass string) {
 	go checkMongoSession()
 }
 
+// HasSession is database connected
+func HasSession() bool {
+	return session != nil
+}
+
 func initDialInfo(addrs []string, db, user, pass string) {
 	dialInfo = &mgo.DialInfo{
 		Addrs:    addrs,
@@ -60,6 +66,7 @@ func checkMongoSession() {
 		if err := ensureMongoConnected(); err != nil {
 			log.Warn("[mongodb] check session error", "err", err)
 			log.Info("[mongodb] reconnect database", "dbName", dialInfo.Database)
+			notify.Notify(notify.EventMongoReconnect, "reconnect database", "dbName", dialInfo.Database, "err", err)
 			mongoConnect()
 		}
 	}
@@ -0,0 +1,20 @@
+package mongodb
+
+import (
+	"strings"
+
+	"github.com/anyswap/ANYToken-distribution/metrics"
+)
+
+var (
+	writeRetryCounter   = metrics.NewCounter("distribute_mongodb_write_retries_total", "Number of retried database writes in TryDoTimes.", "operation")
+	writeFailureCounter = metrics.NewCounter("distribute_mongodb_write_failures_total", "Number of database writes failed after all retries in TryDoTimes.", "operation")
+)
+
+// name of TryDoTimes is '<operation> <key>', use operation as label
+func getOperationName(name string) string {
+	if idx := strings.IndexByte(name, ' '); idx > 0 {
+		return name[:idx]
+	}
+	return name
+}
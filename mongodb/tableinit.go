@@ -17,6 +17,18 @@ var (
 	collectionDistributeInfo     *mgo.Collection
 	collectionVolumeRewardResult *mgo.Collection
 	collectionLiquidRewardResult *mgo.Collection
+	collectionNonceRecord        *mgo.Collection
+	collectionRewardExclusion    *mgo.Collection
+	collectionAccountList        *mgo.Collection
+	collectionVestingGrant       *mgo.Collection
+	collectionUnspentReward      *mgo.Collection
+	collectionPendingReward      *mgo.Collection
+	collectionStaker             *mgo.Collection
+	collectionReferral           *mgo.Collection
+	collectionYield              *mgo.Collection
+	collectionSpending           *mgo.Collection
+
+	collectionReferralRewardResult *mgo.Collection
 )
 
 // do this when reconnect to the database
@@ -33,11 +45,25 @@ func deinintCollections() {
 	collectionDistributeInfo = database.C(tbDistributeInfo)
 	collectionVolumeRewardResult = database.C(tbVolumeRewardResult)
 	collectionLiquidRewardResult = database.C(tbLiquidRewardResult)
+	collectionNonceRecord = database.C(tbNonceRecords)
+	collectionRewardExclusion = database.C(tbRewardExclusions)
+	collectionAccountList = database.C(tbAccountLists)
+	collectionVestingGrant = database.C(tbVestingGrants)
+	collectionUnspentReward = database.C(tbUnspentRewards)
+	collectionPendingReward = database.C(tbPendingRewards)
+	collectionStaker = database.C(tbStakers)
+	collectionReferral = database.C(tbReferrals)
+	collectionYield = database.C(tbYields)
+	collectionSpending = database.C(tbSpendings)
+	collectionReferralRewardResult = database.C(tbReferralRewardResult)
 }
 
 func initCollections() {
 	initCollection(tbBlocks, &collectionBlock, "number")
+	_ = collectionBlock.EnsureIndexKey("timestamp")
 	initCollection(tbTransactions, &collectionTransaction, "blockNumber")
+	_ = collectionTransaction.EnsureIndexKey("erc20Receipts.to", "blockNumber")
+	_ = collectionTransaction.EnsureIndexKey("to", "blockNumber")
 	initCollection(tbSyncInfo, &collectionSyncInfo)
 	initCollection(tbLiquidity, &collectionLiquidity, "exchange", "timestamp")
 	initCollection(tbVolume, &collectionVolume, "exchange", "timestamp")
@@ -48,6 +74,17 @@ func initCollections() {
 	initCollection(tbDistributeInfo, &collectionDistributeInfo, "exchange", "bywhat")
 	initCollection(tbVolumeRewardResult, &collectionVolumeRewardResult, "exchange", "start")
 	initCollection(tbLiquidRewardResult, &collectionLiquidRewardResult, "exchange", "start")
+	initCollection(tbNonceRecords, &collectionNonceRecord, "sender", "nonce")
+	initCollection(tbRewardExclusions, &collectionRewardExclusion, "exchange", "account", "start")
+	initCollection(tbAccountLists, &collectionAccountList, "list")
+	initCollection(tbVestingGrants, &collectionVestingGrant, "account", "finished")
+	initCollection(tbUnspentRewards, &collectionUnspentReward, "bywhat", "status", "start")
+	initCollection(tbPendingRewards, &collectionPendingReward, "rewardToken", "account")
+	initCollection(tbStakers, &collectionStaker, "contract", "blockNumber")
+	initCollection(tbReferrals, &collectionReferral, "referrer")
+	initCollection(tbYields, &collectionYield, "exchange", "account", "timestamp")
+	initCollection(tbSpendings, &collectionSpending, "rewardToken", "timestamp")
+	initCollection(tbReferralRewardResult, &collectionReferralRewardResult, "exchange", "start")
 
 	_ = initLatestSyncInfo()
 }
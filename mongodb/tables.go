@@ -20,6 +20,18 @@ const (
 	tbDistributeInfo     string = "DistributeInfo"
 	tbVolumeRewardResult string = "VolumeRewardResult"
 	tbLiquidRewardResult string = "LiquidRewardResult"
+	tbNonceRecords       string = "NonceRecords"
+	tbRewardExclusions   string = "RewardExclusions"
+	tbAccountLists       string = "AccountLists"
+	tbVestingGrants      string = "VestingGrants"
+	tbUnspentRewards     string = "UnspentRewards"
+	tbPendingRewards     string = "PendingRewards"
+	tbStakers            string = "Stakers"
+	tbReferrals          string = "Referrals"
+	tbYields             string = "Yields"
+	tbSpendings          string = "Spendings"
+
+	tbReferralRewardResult string = "ReferralRewardResult"
 
 	// KeyOfLatestSyncInfo key
 	KeyOfLatestSyncInfo string = "latest"
@@ -114,11 +126,14 @@ type MgoLiquidity struct {
 	BlockNumber uint64 `bson:"blockNumber"`
 	BlockHash   string `bson:"blockHash"`
 	Timestamp   uint64 `bson:"timestamp"`
+	CoinUSD     string `bson:"coinUSD,omitempty"`    // coin price in USD
+	TokenPrice  string `bson:"tokenPrice,omitempty"` // coin per token (whole units)
+	TVLUSD      string `bson:"tvlUSD,omitempty"`
 }
 
 // MgoVolume volumn
 type MgoVolume struct {
-	Key            string `bson:"_id"` // exchange + timestamp
+	Key            string `bson:"_id"` // exchange + timestamp (+ interval if not daily)
 	Exchange       string `bson:"exchange"`
 	Pairs          string `bson:"pairs"`
 	CoinVolume24h  string `bson:"cvolume24h"`
@@ -126,6 +141,9 @@ type MgoVolume struct {
 	BlockNumber    uint64 `bson:"blockNumber"`
 	BlockHash      string `bson:"blockHash"`
 	Timestamp      uint64 `bson:"timestamp"`
+	CoinUSD        string `bson:"coinUSD,omitempty"` // coin price in USD
+	VolumeUSD      string `bson:"volumeUSD,omitempty"`
+	Interval       uint64 `bson:"interval,omitempty"` // seconds of bucket, empty for UTC day
 }
 
 // MgoAccount exchange account
@@ -136,6 +154,33 @@ type MgoAccount struct {
 	Account  string `bson:"account"`
 }
 
+// MgoStaker staker discovered from events of stake contract
+type MgoStaker struct {
+	Key         string `bson:"_id"` // contract + account
+	Contract    string `bson:"contract"`
+	Account     string `bson:"account"`
+	BlockNumber uint64 `bson:"blockNumber"` // first seen
+	TxHash      string `bson:"txhash"`
+}
+
+// referral registration sources
+const (
+	ReferralSourceFile  = "file"
+	ReferralSourceAPI   = "api"
+	ReferralSourceEvent = "event"
+)
+
+// MgoReferral referral registration of referee, the first registration wins
+type MgoReferral struct {
+	Key         string `bson:"_id"` // referee
+	Referee     string `bson:"referee"`
+	Referrer    string `bson:"referrer"`
+	Source      string `bson:"source"`      // file, api or event
+	BlockNumber uint64 `bson:"blockNumber"` // registered block, latest synced block if not from event
+	TxHash      string `bson:"txhash,omitempty"`
+	Timestamp   uint64 `bson:"timestamp"`
+}
+
 // MgoTokenAccount token account
 type MgoTokenAccount struct {
 	Key     string `bson:"_id"` // token + account
@@ -174,17 +219,20 @@ type MgoDistributeInfo struct {
 	Exchange     string        `bson:"exchange"`
 	Pairs        string        `bson:"pairs"`
 	ByWhat       string        `bson:"bywhat"`
+	Program      string        `bson:"program,omitempty"`
 	Start        uint64        `bson:"start"`
 	End          uint64        `bson:"end"`
 	RewardToken  string        `bson:"rewardToken"`
 	Rewards      string        `bson:"rewards"`
 	SampleHeight uint64        `bson:"sampleHeight,omitempty"`
+	RewardsUSD   string        `bson:"rewardsUSD,omitempty"`
 	Timestamp    uint64        `bson:"timestamp"`
 }
 
 // MgoVolumeRewardResult volume reward
 type MgoVolumeRewardResult struct {
 	Key         string `bson:"_id"` // exchange + account + start
+	Program     string `bson:"program,omitempty"`
 	Exchange    string `bson:"exchange"`
 	Pairs       string `bson:"pairs"`
 	Start       uint64 `bson:"start"`
@@ -195,12 +243,15 @@ type MgoVolumeRewardResult struct {
 	Volume      string `bson:"volume"`
 	TxCount     uint64 `bson:"txcount"`
 	RewardTx    string `bson:"rewardTx"`
+	Pending     string `bson:"pending,omitempty"` // paid pending rewards included in reward
+	RewardUSD   string `bson:"rewardUSD,omitempty"`
 	Timestamp   uint64 `bson:"timestamp"`
 }
 
 // MgoLiquidRewardResult liquidity reward
 type MgoLiquidRewardResult struct {
 	Key         string `bson:"_id"` // exchange + account + start
+	Program     string `bson:"program,omitempty"`
 	Exchange    string `bson:"exchange"`
 	Pairs       string `bson:"pairs"`
 	Start       uint64 `bson:"start"`
@@ -209,8 +260,167 @@ type MgoLiquidRewardResult struct {
 	Account     string `bson:"account"`
 	Reward      string `bson:"reward"`
 	Liquidity   string `bson:"liquidity"`
+	Balance     string `bson:"balance,omitempty"` // liquidity before stake boost
 	Height      uint64 `bson:"height"`
 	RewardTx    string `bson:"rewardTx"`
+	Pending     string `bson:"pending,omitempty"` // paid pending rewards included in reward
+	RewardUSD   string `bson:"rewardUSD,omitempty"`
+	Timestamp   uint64 `bson:"timestamp"`
+}
+
+// MgoReferralRewardResult referral reward of referrer from volume rewards of referees
+type MgoReferralRewardResult struct {
+	Key           string `bson:"_id"` // exchange + account + start
+	Program       string `bson:"program,omitempty"`
+	Exchange      string `bson:"exchange"`
+	Pairs         string `bson:"pairs"`
+	Start         uint64 `bson:"start"`
+	End           uint64 `bson:"end"`
+	RewardToken   string `bson:"rewardToken"`
+	Account       string `bson:"account"` // referrer
+	Reward        string `bson:"reward"`
+	RefereeReward string `bson:"refereeReward"` // volume rewards of referees
+	Referees      uint64 `bson:"referees"`
+	RewardTx      string `bson:"rewardTx"`
+	RewardUSD     string `bson:"rewardUSD,omitempty"`
+	Timestamp     uint64 `bson:"timestamp"`
+}
+
+// VestingRewardTx reward tx of reward results whose rewards are granted as vesting, not sent
+const VestingRewardTx = "vesting"
+
+// MgoSpending rewards sent but not recorded in reward results,
+// eg. custom program rewards and vesting releases
+type MgoSpending struct {
+	Key         string `bson:"_id"` // tx hash
+	ByWhat      string `bson:"bywhat"`
+	Program     string `bson:"program,omitempty"`
+	Account     string `bson:"account"`
+	RewardToken string `bson:"rewardToken"`
+	Reward      string `bson:"reward"`
+	Timestamp   uint64 `bson:"timestamp"`
+}
+
+// MgoYield daily yields of exchange, or realized yields of account,
+// in trailing window [Timestamp - WindowDays, Timestamp). reward values are in coin
+// by price of reward token's exchange, APR and APY are percentages.
+type MgoYield struct {
+	Key              string  `bson:"_id"` // exchange + account + timestamp
+	Exchange         string  `bson:"exchange"`
+	Pairs            string  `bson:"pairs"`
+	Account          string  `bson:"account,omitempty"` // empty for exchange yields
+	Timestamp        uint64  `bson:"timestamp"`         // day begin
+	WindowDays       uint64  `bson:"windowDays"`
+	RewardToken      string  `bson:"rewardToken"`
+	RewardTokenPrice string  `bson:"rewardTokenPrice,omitempty"` // coin per token
+	TVL              string  `bson:"tvl,omitempty"`              // average coin value of both sides, or of account's position
+	LiquidRewards    string  `bson:"liquidRewards,omitempty"`
+	LiquidAPR        float64 `bson:"liquidAPR"`
+	LiquidAPY        float64 `bson:"liquidAPY"`
+	Volume           string  `bson:"volume,omitempty"` // coin volume
+	VolumeRewards    string  `bson:"volumeRewards,omitempty"`
+	VolumeYield      float64 `bson:"volumeYield"` // reward value per unit of volume
+	UpdateTime       uint64  `bson:"updateTime"`
+}
+
+// MgoNonceRecord in-flight transaction of sender's nonce
+type MgoNonceRecord struct {
+	Key       string `bson:"_id"` // sender + nonce
+	Sender    string `bson:"sender"`
+	Nonce     uint64 `bson:"nonce"`
+	TxHash    string `bson:"txhash"`
+	RawTx     string `bson:"rawtx"`
+	Timestamp uint64 `bson:"timestamp"`
+}
+
+// MgoRewardExclusion volume excluded from rewards of account in a volume cycle
+type MgoRewardExclusion struct {
+	Key       string `bson:"_id"` // exchange + account + start + reason
+	Exchange  string `bson:"exchange"`
+	Account   string `bson:"account"`
+	Start     uint64 `bson:"start"`
+	End       uint64 `bson:"end"`
+	Reason    string `bson:"reason"`
+	Volume    string `bson:"volume"` // excluded volume or liquidity, zero if only flagged
+	Swaps     int    `bson:"swaps"`
+	Detail    string `bson:"detail,omitempty"`
+	Timestamp uint64 `bson:"timestamp"`
+}
+
+// MgoAccountListEntry denylist or allowlist entry of reward account
+type MgoAccountListEntry struct {
+	Key       string `bson:"_id"` // list + account
+	List      string `bson:"list"`
+	Account   string `bson:"account"`
+	Reason    string `bson:"reason"`
+	Start     uint64 `bson:"start"` // block height or timestamp, inclusive
+	End       uint64 `bson:"end"`   // block height or timestamp, exclusive, 0 means forever
+	Timestamp uint64 `bson:"timestamp"`
+}
+
+// IsActiveAt is entry active at block height or timestamp
+func (e *MgoAccountListEntry) IsActiveAt(position uint64) bool {
+	return position >= e.Start && (e.End == 0 || position < e.End)
+}
+
+// MgoVestingGrant vesting rewards of account in a cycle
+type MgoVestingGrant struct {
+	Key           string `bson:"_id"` // [program +] bywhat + exchange + account + start
+	ByWhat        string `bson:"bywhat"`
+	Program       string `bson:"program,omitempty"`
+	Exchange      string `bson:"exchange"`
+	Account       string `bson:"account"`
+	Start         uint64 `bson:"start"`
+	End           uint64 `bson:"end"`
+	RewardToken   string `bson:"rewardToken"`
+	Mode          string `bson:"mode"`
+	Total         string `bson:"total"`
+	Immediate     string `bson:"immediate"` // vested at grant time
+	GrantTime     uint64 `bson:"grantTime"`
+	CliffTime     uint64 `bson:"cliffTime"`
+	EndTime       uint64 `bson:"endTime"`
+	Claimed       string `bson:"claimed"` // released to account
+	Finished      bool   `bson:"finished"`
+	LastReleaseTx string `bson:"lastReleaseTx,omitempty"`
+	Timestamp     uint64 `bson:"timestamp"`
+}
+
+// unspent reward status
+const (
+	UnspentStatusDropped       = "dropped"
+	UnspentStatusCarried       = "carried" // to be added to the next cycle
+	UnspentStatusApplied       = "applied" // added to cycle of 'appliedStart'
+	UnspentStatusRedistributed = "redistributed"
+	UnspentStatusAccumulated   = "accumulated" // added to pending rewards of accounts
+)
+
+// MgoUnspentReward unspent rewards of cycle (dust, no volume steps, unallocated shares)
+type MgoUnspentReward struct {
+	Key          string `bson:"_id"` // [program +] bywhat + exchange + start + reason
+	Program      string `bson:"program,omitempty"`
+	ByWhat       string `bson:"bywhat"`
+	Exchange     string `bson:"exchange"` // empty if not of exchange
+	Start        uint64 `bson:"start"`
+	End          uint64 `bson:"end"`
+	RewardToken  string `bson:"rewardToken"`
+	Reason       string `bson:"reason"`
+	Amount       string `bson:"amount"`
+	Accounts     int    `bson:"accounts"`
+	Policy       string `bson:"policy"`
+	Status       string `bson:"status"`
+	AppliedStart uint64 `bson:"appliedStart,omitempty"`
+	Timestamp    uint64 `bson:"timestamp"`
+}
+
+// MgoPendingReward accumulated dust rewards of account, paid once passing dust threshold
+type MgoPendingReward struct {
+	Key         string `bson:"_id"` // [program +] rewardToken + account
+	Program     string `bson:"program,omitempty"`
+	RewardToken string `bson:"rewardToken"`
+	Account     string `bson:"account"`
+	Amount      string `bson:"amount"` // current balance
+	Paid        string `bson:"paid"`   // sum of paid balances
+	LastStart   uint64 `bson:"lastStart"`
 	Timestamp   uint64 `bson:"timestamp"`
 }
 
@@ -219,6 +429,66 @@ func GetKeyOfRewardResult(exchange, account string, start uint64) string {
 	return strings.ToLower(fmt.Sprintf("%s:%s:%d", exchange, account, start))
 }
 
+// GetKeyOfProgramRewardResult get key, the same as 'GetKeyOfRewardResult' if program is empty
+func GetKeyOfProgramRewardResult(program, exchange, account string, start uint64) string {
+	if program == "" {
+		return GetKeyOfRewardResult(exchange, account, start)
+	}
+	return strings.ToLower(fmt.Sprintf("%s/%s:%s:%d", program, exchange, account, start))
+}
+
+// GetKeyOfRewardExclusion get key
+func GetKeyOfRewardExclusion(exchange, account string, start uint64, reason string) string {
+	return strings.ToLower(fmt.Sprintf("%s:%s:%d:%s", exchange, account, start, reason))
+}
+
+// GetKeyOfAccountListEntry get key
+func GetKeyOfAccountListEntry(list, account string) string {
+	return strings.ToLower(fmt.Sprintf("%s:%s", list, account))
+}
+
+// GetKeyOfVestingGrant get key
+func GetKeyOfVestingGrant(program, byWhat, exchange, account string, start uint64) string {
+	if program != "" {
+		return strings.ToLower(fmt.Sprintf("%s/%s:%s:%s:%d", program, byWhat, exchange, account, start))
+	}
+	return strings.ToLower(fmt.Sprintf("%s:%s:%s:%d", byWhat, exchange, account, start))
+}
+
+// GetKeyOfUnspentReward get key
+func GetKeyOfUnspentReward(program, byWhat, exchange string, start uint64, reason string) string {
+	if program != "" {
+		return strings.ToLower(fmt.Sprintf("%s/%s:%s:%d:%s", program, byWhat, exchange, start, reason))
+	}
+	return strings.ToLower(fmt.Sprintf("%s:%s:%d:%s", byWhat, exchange, start, reason))
+}
+
+// GetKeyOfPendingReward get key
+func GetKeyOfPendingReward(program, rewardToken, account string) string {
+	if program != "" {
+		return strings.ToLower(fmt.Sprintf("%s/%s:%s", program, rewardToken, account))
+	}
+	return strings.ToLower(fmt.Sprintf("%s:%s", rewardToken, account))
+}
+
+// GetKeyOfStaker get key
+func GetKeyOfStaker(contract, account string) string {
+	return strings.ToLower(fmt.Sprintf("%s:%s", contract, account))
+}
+
+// GetKeyOfYield get key
+func GetKeyOfYield(exchange, account string, timestamp uint64) string {
+	if account == "" {
+		return strings.ToLower(fmt.Sprintf("%s:%d", exchange, timestamp))
+	}
+	return strings.ToLower(fmt.Sprintf("%s:%s:%d", exchange, account, timestamp))
+}
+
+// GetKeyOfNonceRecord get key
+func GetKeyOfNonceRecord(sender string, nonce uint64) string {
+	return strings.ToLower(fmt.Sprintf("%s:%d", sender, nonce))
+}
+
 // GetKeyOfExchangeAndAccount get key
 func GetKeyOfExchangeAndAccount(exchange, account string) string {
 	return strings.ToLower(fmt.Sprintf("%s:%s", exchange, account))
@@ -234,6 +504,14 @@ func GetKeyOfExchangeAndTimestamp(exchange string, timestamp uint64) string {
 	return strings.ToLower(fmt.Sprintf("%s:%d", exchange, timestamp))
 }
 
+// GetKeyOfVolume get key of volume bucket, daily volume of UTC day has no interval suffix
+func GetKeyOfVolume(exchange string, timestamp, interval uint64) string {
+	if getVolumeInterval(timestamp, interval) == 0 {
+		return GetKeyOfExchangeAndTimestamp(exchange, timestamp)
+	}
+	return strings.ToLower(fmt.Sprintf("%s:%d:%d", exchange, timestamp, interval))
+}
+
 // GetKeyOfLiquidityBalance get key
 func GetKeyOfLiquidityBalance(exchange, account string, blockNumber uint64) string {
 	return strings.ToLower(fmt.Sprintf("%s:%s:%d", exchange, account, blockNumber))
@@ -149,6 +149,19 @@ func CalcWeightedRewards(stats []AccountStatSlice, totalReward *big.Int, weights
 		}
 		return
 	}
+	rewards := DivideWeightedRewards(stats, totalReward, weights)
+	CalcRewardsInBatch(stats, rewards)
+}
+
+// DivideWeightedRewards divide total reward to stats by weighted shares
+func DivideWeightedRewards(stats []AccountStatSlice, totalReward *big.Int, weights []uint64) []*big.Int {
+	if weights != nil && len(weights) != len(stats) {
+		log.Error("divide weighted rewards with not equal number of stats and weights")
+		return nil
+	}
+	if len(stats) == 1 {
+		return []*big.Int{totalReward}
+	}
 	weight := uint64(1)
 	totalShareSlice := make([]*big.Int, len(stats))
 	for i, stat := range stats {
@@ -157,8 +170,7 @@ func CalcWeightedRewards(stats []AccountStatSlice, totalReward *big.Int, weights
 		}
 		totalShareSlice[i] = stat.SumWeightShares(weight)
 	}
-	rewards := DivideRewards(totalReward, totalShareSlice)
-	CalcRewardsInBatch(stats, rewards)
+	return DivideRewards(totalReward, totalShareSlice)
 }
 
 // DivideRewards divide rewards
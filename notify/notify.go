@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/params"
+)
+
+// notify event types
+const (
+	EventCycleStart     = "cycleStart"
+	EventCycleFinish    = "cycleFinish"
+	EventRewardsSent    = "rewardsSent"
+	EventDustSkipped    = "dustSkipped"
+	EventTransferFailed = "transferFailed"
+	EventLowBalance     = "lowBalance"
+	EventSyncBehind     = "syncBehind"
+	EventMongoReconnect = "mongoReconnect"
+	EventSpendingLimit  = "spendingLimit"
+	EventReleaseStopped = "releaseStopped"
+)
+
+// webhook payload formats
+const (
+	FormatJSON    = "json"
+	FormatSlack   = "slack"
+	FormatDiscord = "discord"
+)
+
+const (
+	defaultTimeout       = 10 * time.Second
+	defaultRetryInterval = 3 * time.Second
+	retryCount           = 3
+)
+
+// Event notify event
+type Event struct {
+	Type      string                 `json:"type"`
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+	Timestamp int64                  `json:"timestamp"`
+}
+
+// Text format event to human readable text
+func (e *Event) Text() string {
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%v] %v", e.Type, e.Message)
+	for _, key := range keys {
+		fmt.Fprintf(&sb, " %v=%v", key, e.Fields[key])
+	}
+	return sb.String()
+}
+
+// IsEnabled is any webhook configed
+func IsEnabled() bool {
+	notifyCfg := params.GetConfig().Notify
+	return notifyCfg != nil && len(notifyCfg.Webhooks) > 0
+}
+
+// Notify send event to all subscribed webhooks asynchronously,
+// ctx is key value pairs like the log package.
+func Notify(eventType, message string, ctx ...interface{}) {
+	if !IsEnabled() {
+		return
+	}
+	event := newEvent(eventType, message, ctx...)
+	for _, hook := range params.GetConfig().Notify.Webhooks {
+		if !hook.IsSubscribed(eventType) {
+			continue
+		}
+		go func(hook *params.WebhookConfig) {
+			if err := Send(hook, event); err != nil {
+				log.Warn("[notify] send event failed", "url", hook.URL, "event", eventType, "err", err)
+			}
+		}(hook)
+	}
+}
+
+func newEvent(eventType, message string, ctx ...interface{}) *Event {
+	fields := make(map[string]interface{}, len(ctx)/2)
+	for k := 0; k+2 <= len(ctx); k += 2 {
+		key, ok := ctx[k].(string)
+		if !ok {
+			key = fmt.Sprintf("%v", ctx[k])
+		}
+		fields[key] = fmt.Sprintf("%v", ctx[k+1])
+	}
+	return &Event{
+		Type:      eventType,
+		Message:   message,
+		Fields:    fields,
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// Send post event to webhook synchronously (with retries)
+func Send(hook *params.WebhookConfig, event *Event) (err error) {
+	payload, err := buildPayload(hook.Format, event)
+	if err != nil {
+		return err
+	}
+	timeout := defaultTimeout
+	if hook.Timeout > 0 {
+		timeout = time.Duration(hook.Timeout) * time.Second
+	}
+	retryInterval := defaultRetryInterval
+	if hook.RetryInterval > 0 {
+		retryInterval = time.Duration(hook.RetryInterval) * time.Millisecond
+	}
+	client := &http.Client{Timeout: timeout}
+	for i := 0; i < retryCount; i++ {
+		err = post(client, hook.URL, payload)
+		if err == nil {
+			log.Debug("[notify] send event success", "url", hook.URL, "event", event.Type)
+			return nil
+		}
+		time.Sleep(retryInterval)
+	}
+	return err
+}
+
+func buildPayload(format string, event *Event) ([]byte, error) {
+	switch format {
+	case "", FormatJSON:
+		return json.Marshal(event)
+	case FormatSlack:
+		return json.Marshal(map[string]string{"text": event.Text()})
+	case FormatDiscord:
+		return json.Marshal(map[string]string{"content": event.Text()})
+	default:
+		return nil, fmt.Errorf("unknown webhook format '%v'", format)
+	}
+}
+
+func post(client *http.Client, url string, payload []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook response status %v", resp.Status)
+	}
+	return nil
+}
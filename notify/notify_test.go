@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/params"
+)
+
+type webhookStandIn struct {
+	*httptest.Server
+
+	lock     sync.Mutex
+	bodies   [][]byte
+	failures int // respond error status to the first failures requests
+	received chan struct{}
+}
+
+func newWebhookStandIn(failures int) *webhookStandIn {
+	standIn := &webhookStandIn{
+		failures: failures,
+		received: make(chan struct{}, 16),
+	}
+	standIn.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		standIn.lock.Lock()
+		defer standIn.lock.Unlock()
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if standIn.failures > 0 {
+			standIn.failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		standIn.bodies = append(standIn.bodies, body)
+		standIn.received <- struct{}{}
+	}))
+	return standIn
+}
+
+func (standIn *webhookStandIn) getBodies() [][]byte {
+	standIn.lock.Lock()
+	defer standIn.lock.Unlock()
+	return standIn.bodies
+}
+
+func TestSendFormats(t *testing.T) {
+	standIn := newWebhookStandIn(0)
+	defer standIn.Close()
+
+	event := newEvent(EventTransferFailed, "send reward failed", "account", "0x01", "reward", 100)
+	for _, format := range []string{FormatJSON, FormatSlack, FormatDiscord} {
+		hook := &params.WebhookConfig{URL: standIn.URL, Format: format}
+		if err := Send(hook, event); err != nil {
+			t.Fatalf("send %v payload failed: %v", format, err)
+		}
+	}
+	bodies := standIn.getBodies()
+	if len(bodies) != 3 {
+		t.Fatalf("want 3 payloads, got %v", len(bodies))
+	}
+
+	var gotEvent Event
+	if err := json.Unmarshal(bodies[0], &gotEvent); err != nil {
+		t.Fatalf("unmarshal json payload failed: %v", err)
+	}
+	if gotEvent.Type != EventTransferFailed || gotEvent.Fields["reward"] != "100" {
+		t.Errorf("wrong json payload %s", bodies[0])
+	}
+
+	wantText := "[transferFailed] send reward failed account=0x01 reward=100"
+	for i, key := range []string{"text", "content"} {
+		var payload map[string]string
+		if err := json.Unmarshal(bodies[i+1], &payload); err != nil {
+			t.Fatalf("unmarshal payload failed: %v", err)
+		}
+		if payload[key] != wantText {
+			t.Errorf("want %v '%v', got '%v'", key, wantText, payload[key])
+		}
+	}
+}
+
+func TestSendRetry(t *testing.T) {
+	standIn := newWebhookStandIn(retryCount - 1)
+	defer standIn.Close()
+	hook := &params.WebhookConfig{URL: standIn.URL, RetryInterval: 1}
+	if err := Send(hook, newEvent(EventCycleStart, "start")); err != nil {
+		t.Fatalf("send with retries failed: %v", err)
+	}
+
+	failStandIn := newWebhookStandIn(retryCount)
+	defer failStandIn.Close()
+	hook = &params.WebhookConfig{URL: failStandIn.URL, RetryInterval: 1}
+	err := Send(hook, newEvent(EventCycleStart, "start"))
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("want error status 500, got %v", err)
+	}
+}
+
+func TestNotifySubscribedEvents(t *testing.T) {
+	standIn := newWebhookStandIn(0)
+	defer standIn.Close()
+
+	defer params.SetConfig(params.GetConfig())
+	params.SetConfig(&params.Config{
+		Notify: &params.NotifyConfig{
+			Webhooks: []*params.WebhookConfig{
+				{URL: standIn.URL, Events: []string{EventLowBalance}},
+			},
+		},
+	})
+
+	Notify(EventCycleStart, "not subscribed")
+	Notify(EventLowBalance, "sender balance is low", "balance", 1)
+	select {
+	case <-standIn.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for notification")
+	}
+	time.Sleep(100 * time.Millisecond)
+	bodies := standIn.getBodies()
+	if len(bodies) != 1 {
+		t.Fatalf("want 1 notification, got %v", len(bodies))
+	}
+	var event Event
+	if err := json.Unmarshal(bodies[0], &event); err != nil {
+		t.Fatalf("unmarshal payload failed: %v", err)
+	}
+	if event.Type != EventLowBalance {
+		t.Errorf("want event %v, got %v", EventLowBalance, event.Type)
+	}
+}
@@ -26,6 +26,10 @@ func CheckConfig() (err error) {
 	case config.Exchanges == nil:
 		return errors.New("must config Exchanges")
 	}
+	err = checkSyncConfig()
+	if err != nil {
+		return err
+	}
 	err = checkExchangeConfig()
 	if err != nil {
 		return err
@@ -48,6 +52,57 @@ func CheckConfig() (err error) {
 	if err != nil {
 		return err
 	}
+	err = checkMetricsConfig()
+	if err != nil {
+		return err
+	}
+	err = checkNotifyConfig()
+	if err != nil {
+		return err
+	}
+	err = checkSpendingLimitsConfig()
+	if err != nil {
+		return err
+	}
+	err = checkAPIConfig()
+	if err != nil {
+		return err
+	}
+	err = checkWashTradeConfig()
+	if err != nil {
+		return err
+	}
+	err = checkContractAccountsConfig()
+	if err != nil {
+		return err
+	}
+	err = checkVestingConfig()
+	if err != nil {
+		return err
+	}
+	err = checkProgramsConfig()
+	if err != nil {
+		return err
+	}
+	err = checkReferralConfig()
+	if err != nil {
+		return err
+	}
+	err = checkPriceConfig()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func checkSyncConfig() error {
+	syncCfg := config.Sync
+	if SecondsPerDay%syncCfg.GetSnapshotInterval() != 0 {
+		return fmt.Errorf("[check sync] snapshot interval %v does not divide a day", syncCfg.SnapshotInterval)
+	}
+	if syncCfg.DayBoundaryOffset <= -int64(SecondsPerDay) || syncCfg.DayBoundaryOffset >= int64(SecondsPerDay) {
+		return fmt.Errorf("[check sync] day boundary offset %v is not in range of a day", syncCfg.DayBoundaryOffset)
+	}
 	return nil
 }
 
@@ -76,12 +131,56 @@ func checkExchangeConfig() error {
 		exchangeMap[exchange] = struct{}{}
 		tokenMap[token] = struct{}{}
 		sumTradeWeight += ex.TradeWeight
+		if ex.TradeRewardsPerCycle != "" && ex.TradeWeight != 0 && config.Distribute.TradeWeightIsPercentage {
+			return fmt.Errorf("trade weight of exchange %v with fixed rewards must be zero in percentage mode", ex.Exchange)
+		}
 	}
 	if config.Distribute.TradeWeightIsPercentage {
 		if !(sumTradeWeight == 100 || sumTradeWeight == 0) {
 			return fmt.Errorf("sum of trade percentage weight is %v, not equal to 100 or 0", sumTradeWeight)
 		}
 	}
+	return checkExchangeBudgets()
+}
+
+// fixed rewards of exchanges can not exceed the configed cycle rewards,
+// rewards of emission schedule are checked at start of every step here,
+// and in every cycle before dividing rewards.
+func checkExchangeBudgets() error {
+	dist := config.Distribute
+	if dist.Emission == nil {
+		return checkExchangeBudgetsAt(dist.GetStart())
+	}
+	for _, step := range dist.Emission.Steps {
+		if err := checkExchangeBudgetsAt(step.From); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkExchangeBudgetsAt(start uint64) error {
+	dist := config.Distribute
+	sumLiquidRewards := big.NewInt(0)
+	sumTradeRewards := big.NewInt(0)
+	for _, ex := range config.Exchanges {
+		if rewards := ex.GetLiquidRewardsPerCycle(); rewards != nil {
+			sumLiquidRewards.Add(sumLiquidRewards, rewards)
+		}
+		if rewards := ex.GetTradeRewardsPerCycle(); rewards != nil {
+			sumTradeRewards.Add(sumTradeRewards, rewards)
+		}
+	}
+	if liquidRewards := dist.GetByLiquidRewardsAt(start); sumLiquidRewards.Cmp(liquidRewards) > 0 {
+		return fmt.Errorf("sum of fixed liquid rewards %v exceeds liquid cycle rewards %v at %v", sumLiquidRewards, liquidRewards, start)
+	}
+	if volumeCycleLen := dist.GetByVolumeCycleLen(); volumeCycleLen > 0 {
+		volumeCycles := dist.GetByLiquidCycleLen() / volumeCycleLen
+		totalVolumeRewards := new(big.Int).Mul(dist.GetByVolumeRewardsAt(start), new(big.Int).SetUint64(volumeCycles))
+		if sumTradeRewards.Cmp(totalVolumeRewards) > 0 {
+			return fmt.Errorf("sum of fixed trade rewards %v exceeds volume rewards %v of liquid cycle at %v", sumTradeRewards, totalVolumeRewards, start)
+		}
+	}
 	return nil
 }
 
@@ -108,6 +207,28 @@ func checkStakeConfig() error {
 			return fmt.Errorf("wrong staker address %v", staker)
 		}
 	}
+	for _, target := range config.Stake.ApplyTo {
+		if target != StakeBoostLiquidity && target != StakeBoostVolume {
+			return fmt.Errorf("unknown stake boost target '%v'", target)
+		}
+	}
+	for _, topic := range config.Stake.StakerTopics {
+		if len(common.FromHex(topic)) != common.HashLength {
+			return fmt.Errorf("wrong staker topic %v", topic)
+		}
+	}
+	switch config.Stake.GetCurve() {
+	case StakeCurveTiers:
+	case StakeCurveLinear:
+		if config.Stake.FullStake == 0 || config.Stake.MaxPercent == 0 {
+			return fmt.Errorf("must config full stake and max percent of linear stake curve")
+		}
+		return nil
+	case StakeCurveVeBoost:
+		return nil
+	default:
+		return fmt.Errorf("unknown stake curve '%v'", config.Stake.Curve)
+	}
 	if len(config.Stake.Points) != len(config.Stake.Percents) {
 		return fmt.Errorf("count of points and percents not equal")
 	}
@@ -128,6 +249,265 @@ func checkStakeConfig() error {
 	return nil
 }
 
+func checkMetricsConfig() error {
+	if config.Metrics == nil || !config.Metrics.Enable {
+		return nil
+	}
+	if config.Metrics.ListenAddress == "" {
+		return fmt.Errorf("must config metrics listen address if enabled")
+	}
+	return nil
+}
+
+func checkAPIConfig() error {
+	if config.API == nil || !config.API.Enable {
+		return nil
+	}
+	if config.API.ListenAddress == "" {
+		return fmt.Errorf("must config API listen address if enabled")
+	}
+	return nil
+}
+
+func checkNotifyConfig() error {
+	notifyCfg := config.Notify
+	if notifyCfg == nil {
+		return nil
+	}
+	for _, hook := range notifyCfg.Webhooks {
+		if hook.URL == "" {
+			return fmt.Errorf("[check notify] empty webhook url")
+		}
+		switch hook.Format {
+		case "", "json", "slack", "discord":
+		default:
+			return fmt.Errorf("[check notify] unknown webhook format '%v' (url %v)", hook.Format, hook.URL)
+		}
+	}
+	if notifyCfg.LowTokenBalance != "" && notifyCfg.GetLowTokenBalance() == nil {
+		return fmt.Errorf("[check notify] wrong low token balance %v", notifyCfg.LowTokenBalance)
+	}
+	if notifyCfg.LowCoinBalance != "" && notifyCfg.GetLowCoinBalance() == nil {
+		return fmt.Errorf("[check notify] wrong low coin balance %v", notifyCfg.LowCoinBalance)
+	}
+	return nil
+}
+
+func checkSpendingLimitsConfig() error {
+	limits := config.Limits
+	if limits == nil {
+		return nil
+	}
+	if limits.MaxPerCycle != "" && limits.GetMaxPerCycle() == nil {
+		return fmt.Errorf("[check limits] wrong max per cycle %v", limits.MaxPerCycle)
+	}
+	if limits.MaxPerDay != "" && limits.GetMaxPerDay() == nil {
+		return fmt.Errorf("[check limits] wrong max per day %v", limits.MaxPerDay)
+	}
+	if limits.MaxPerRecipient != "" && limits.GetMaxPerRecipient() == nil {
+		return fmt.Errorf("[check limits] wrong max per recipient %v", limits.MaxPerRecipient)
+	}
+	if limits.MaxRecipientShare > 100 {
+		return fmt.Errorf("[check limits] max recipient share %v is larger than 100 percent", limits.MaxRecipientShare)
+	}
+	return nil
+}
+
+func checkWashTradeConfig() error {
+	washCfg := config.WashTrade
+	if washCfg == nil {
+		return nil
+	}
+	if washCfg.MinSwapAmount != "" && washCfg.GetMinSwapAmount() == nil {
+		return fmt.Errorf("[check wash trade] wrong min swap amount %v", washCfg.MinSwapAmount)
+	}
+	if washCfg.MaxAccountVolume != "" && washCfg.GetMaxAccountVolume() == nil {
+		return fmt.Errorf("[check wash trade] wrong max account volume %v", washCfg.MaxAccountVolume)
+	}
+	if washCfg.ClusterMinSize == 1 {
+		return fmt.Errorf("[check wash trade] cluster min size must be larger than 1")
+	}
+	return nil
+}
+
+func checkContractAccountsConfig() error {
+	contractsCfg := config.Contracts
+	if contractsCfg == nil {
+		return nil
+	}
+	switch contractsCfg.Policy {
+	case "", ContractPolicyExclude, ContractPolicyAllowlist:
+	case ContractPolicyRedirect:
+		if !common.IsHexAddress(contractsCfg.Beneficiary) {
+			return fmt.Errorf("[check contracts] wrong beneficiary '%v' of redirect policy", contractsCfg.Beneficiary)
+		}
+	default:
+		return fmt.Errorf("[check contracts] unknown policy '%v'", contractsCfg.Policy)
+	}
+	for _, allowed := range contractsCfg.Allowed {
+		if !common.IsHexAddress(allowed) {
+			return fmt.Errorf("[check contracts] wrong allowed contract '%v'", allowed)
+		}
+	}
+	for from, to := range contractsCfg.Redirects {
+		if !common.IsHexAddress(from) || !common.IsHexAddress(to) {
+			return fmt.Errorf("[check contracts] wrong redirect from '%v' to '%v'", from, to)
+		}
+	}
+	return nil
+}
+
+func checkVestingConfig() error {
+	vestCfg := config.Vesting
+	if vestCfg == nil || !vestCfg.Enable {
+		return nil
+	}
+	switch vestCfg.Mode {
+	case VestingModeRelease:
+		if !common.IsHexAddress(vestCfg.Sender) {
+			return fmt.Errorf("[check vesting] wrong releaser sender '%v'", vestCfg.Sender)
+		}
+		signerType := ""
+		if config.Distribute != nil {
+			signerType = config.Distribute.SignerType
+		}
+		if (signerType == "" || signerType == "keystore") && (vestCfg.KeystoreFile == "" || vestCfg.PasswordFile == "") {
+			return fmt.Errorf("[check vesting] must config keystore and password file of releaser sender")
+		}
+	case VestingModeContract:
+		if vestCfg.DepositsDir == "" {
+			return fmt.Errorf("[check vesting] must config deposits dir of contract mode")
+		}
+	default:
+		return fmt.Errorf("[check vesting] unknown mode '%v'", vestCfg.Mode)
+	}
+	if vestCfg.ImmediatePercent > 100 {
+		return fmt.Errorf("[check vesting] immediate percent %v is larger than 100", vestCfg.ImmediatePercent)
+	}
+	if vestCfg.ImmediatePercent < 100 && vestCfg.CliffDays == 0 && vestCfg.LinearDays == 0 {
+		return fmt.Errorf("[check vesting] must config cliff days or linear days")
+	}
+	return nil
+}
+
+func checkReferralConfig() error {
+	refCfg := config.Referral
+	if refCfg == nil || !refCfg.Enable {
+		return nil
+	}
+	if refCfg.Percent == 0 || refCfg.Percent > 100 {
+		return fmt.Errorf("[check referral] percent %v is not in range (0, 100]", refCfg.Percent)
+	}
+	if refCfg.BudgetPerCycle != "" && refCfg.GetBudgetPerCycle() == nil {
+		return fmt.Errorf("[check referral] wrong budget per cycle %v", refCfg.BudgetPerCycle)
+	}
+	if refCfg.RegistryContract != "" {
+		if !common.IsHexAddress(refCfg.RegistryContract) {
+			return fmt.Errorf("[check referral] wrong registry contract %v", refCfg.RegistryContract)
+		}
+		if len(common.FromHex(refCfg.RegistryTopic)) != common.HashLength {
+			return fmt.Errorf("[check referral] wrong registry topic %v", refCfg.RegistryTopic)
+		}
+	}
+	return nil
+}
+
+func checkPriceConfig() error {
+	priceCfg := config.Price
+	if priceCfg == nil {
+		return nil
+	}
+	if priceCfg.CoinUSD != "" {
+		if _, ok := new(big.Rat).SetString(priceCfg.CoinUSD); !ok {
+			return fmt.Errorf("[check price] wrong coin USD price %v", priceCfg.CoinUSD)
+		}
+	}
+	if priceCfg.FeedURL != "" && !strings.HasPrefix(priceCfg.FeedURL, "http") {
+		return fmt.Errorf("[check price] wrong feed url %v", priceCfg.FeedURL)
+	}
+	return nil
+}
+
+func checkProgramsConfig() error {
+	idMap := make(map[string]struct{})
+	for _, prog := range config.Programs {
+		if err := prog.check(); err != nil {
+			return err
+		}
+		id := strings.ToLower(prog.ID)
+		if _, exist := idMap[id]; exist {
+			return fmt.Errorf("[check program] duplicate program id %v", prog.ID)
+		}
+		idMap[id] = struct{}{}
+	}
+	return nil
+}
+
+func (prog *ProgramConfig) check() error {
+	if prog.ID == "" || strings.ContainsAny(prog.ID, ":/ ") {
+		return fmt.Errorf("[check program] wrong program id '%v'", prog.ID)
+	}
+	if !common.IsHexAddress(prog.RewardToken) {
+		return fmt.Errorf("[check program] wrong reward token '%v' (program %v)", prog.RewardToken, prog.ID)
+	}
+	if prog.Sender != "" && !common.IsHexAddress(prog.Sender) {
+		return fmt.Errorf("[check program] wrong sender '%v' (program %v)", prog.Sender, prog.ID)
+	}
+	if prog.CycleLen == 0 {
+		return fmt.Errorf("[check program] zero cycle length (program %v)", prog.ID)
+	}
+	if !IsValidUnspentPolicy(prog.UnspentPolicy) {
+		return fmt.Errorf("[check program] unknown unspent policy '%v' (program %v)", prog.UnspentPolicy, prog.ID)
+	}
+	if prog.End != 0 && (prog.End <= prog.Start || (prog.End-prog.Start)%prog.CycleLen != 0) {
+		return fmt.Errorf("[check program] range [%v, %v) is not intergral multiple of cycle length %v (program %v)", prog.Start, prog.End, prog.CycleLen, prog.ID)
+	}
+	switch prog.Method {
+	case ProgramMethodCustom:
+		if prog.InputDir == "" {
+			return fmt.Errorf("[check program] must config input dir of custom method (program %v)", prog.ID)
+		}
+		if !common.IsHexAddress(prog.Sender) {
+			return fmt.Errorf("[check program] must config sender of custom method (program %v)", prog.ID)
+		}
+		signerType := ""
+		if config.Distribute != nil {
+			signerType = config.Distribute.SignerType
+		}
+		if (signerType == "" || signerType == "keystore") && (prog.KeystoreFile == "" || prog.PasswordFile == "") {
+			return fmt.Errorf("[check program] must config keystore and password file of custom method sender (program %v)", prog.ID)
+		}
+		return nil
+	case ProgramMethodLiquidity:
+	case ProgramMethodVolume:
+		if prog.CycleLen%prog.GetStepLen() != 0 {
+			return fmt.Errorf("[check program] cycle length %v is not multiple intergral of step length %v (program %v)", prog.CycleLen, prog.StepLen, prog.ID)
+		}
+	default:
+		return fmt.Errorf("[check program] unknown method '%v' (program %v)", prog.Method, prog.ID)
+	}
+	if prog.GetRewards().Sign() <= 0 {
+		return fmt.Errorf("[check program] wrong rewards '%v' (program %v)", prog.Rewards, prog.ID)
+	}
+	if len(prog.Exchanges) == 0 || len(prog.Exchanges) != len(prog.Weights) {
+		return fmt.Errorf("[check program] count of exchanges %v and weights %v mismatch (program %v)", len(prog.Exchanges), len(prog.Weights), prog.ID)
+	}
+	sumWeight := uint64(0)
+	for i, exchange := range prog.Exchanges {
+		if !IsConfigedExchange(exchange) {
+			return fmt.Errorf("[check program] exchange %v is not configed (program %v)", exchange, prog.ID)
+		}
+		if prog.Weights[i] == 0 {
+			return fmt.Errorf("[check program] zero weight of exchange %v (program %v)", exchange, prog.ID)
+		}
+		sumWeight += prog.Weights[i]
+	}
+	if prog.WeightIsPercentage && sumWeight != 100 {
+		return fmt.Errorf("[check program] sum of weights %v is not 100 percentage (program %v)", sumWeight, prog.ID)
+	}
+	return nil
+}
+
 func (ex *ExchangeConfig) check() error {
 	if !common.IsHexAddress(ex.Exchange) {
 		return fmt.Errorf("[check exchange] wrong exchange address '%v'", ex.Exchange)
@@ -141,6 +521,20 @@ func (ex *ExchangeConfig) check() error {
 	if ex.CreationHeight == 0 {
 		return fmt.Errorf("[check exchange] wrong exchange creation height '%v' (exchange %v)", ex.CreationHeight, ex.Exchange)
 	}
+	if ex.EndHeight != 0 && ex.EndHeight <= ex.StartHeight {
+		return fmt.Errorf("[check exchange] end height %v is not larger than start height %v (exchange %v)", ex.EndHeight, ex.StartHeight, ex.Exchange)
+	}
+	if ex.EndTimestamp != 0 && ex.EndTimestamp <= ex.StartTimestamp {
+		return fmt.Errorf("[check exchange] end timestamp %v is not larger than start timestamp %v (exchange %v)", ex.EndTimestamp, ex.StartTimestamp, ex.Exchange)
+	}
+	for _, rewardsStr := range []string{ex.LiquidRewardsPerCycle, ex.TradeRewardsPerCycle} {
+		if rewardsStr == "" {
+			continue
+		}
+		if rewards, err := tools.GetBigIntFromString(rewardsStr); err != nil || rewards.Sign() <= 0 {
+			return fmt.Errorf("[check exchange] wrong rewards per cycle '%v' (exchange %v)", rewardsStr, ex.Exchange)
+		}
+	}
 	return nil
 }
 
@@ -154,6 +548,15 @@ func (dist *DistributeConfig) check() error {
 	if err := dist.checkCycle(); err != nil {
 		return err
 	}
+	if err := dist.checkEmission(); err != nil {
+		return err
+	}
+	if !IsValidUnspentPolicy(dist.UnspentPolicy) {
+		return fmt.Errorf("[check distribute] unknown unspent policy '%v'", dist.UnspentPolicy)
+	}
+	if err := dist.checkSigner(); err != nil {
+		return err
+	}
 	// for security reason, if has distribute job, then
 	// must sync with at least the distribute job's stable height
 	// to prevent blockchain short forks
@@ -171,6 +574,19 @@ func (dist *DistributeConfig) checkAddress() error {
 	return nil
 }
 
+func (dist *DistributeConfig) checkSigner() error {
+	switch dist.SignerType {
+	case "", "keystore":
+	case "clef", "http":
+		if dist.SignerURL == "" {
+			return fmt.Errorf("[check distribute] empty signer URL of signer type '%v'", dist.SignerType)
+		}
+	default:
+		return fmt.Errorf("[check distribute] unknown signer type '%v'", dist.SignerType)
+	}
+	return nil
+}
+
 func (dist *DistributeConfig) checkBigIntStringValue(name, value string) error {
 	if value == "" {
 		return nil
@@ -221,6 +637,107 @@ func (dist *DistributeConfig) checkCycle() error {
 	return nil
 }
 
+func (dist *DistributeConfig) checkEmission() error {
+	emission := dist.Emission
+	if emission == nil {
+		return nil
+	}
+	start := dist.GetStart()
+	cycleLen := dist.GetByLiquidCycleLen()
+	isAligned := func(pos uint64) bool {
+		return pos >= start && (pos-start)%cycleLen == 0
+	}
+	prev := uint64(0)
+	for i, step := range emission.Steps {
+		if i > 0 && step.From <= prev {
+			return fmt.Errorf("[check emission] steps are not ascending sorted by from")
+		}
+		prev = step.From
+		if !isAligned(step.From) {
+			return fmt.Errorf("[check emission] step from %v is not aligned to liquid cycles of start %v and length %v", step.From, start, cycleLen)
+		}
+		if err := dist.checkBigIntStringValue("step by liquid rewards", step.ByLiquidRewards); err != nil {
+			return err
+		}
+		if err := dist.checkBigIntStringValue("step by volume rewards", step.ByVolumeRewards); err != nil {
+			return err
+		}
+	}
+	if emission.End != 0 && (emission.End <= start || !isAligned(emission.End)) {
+		return fmt.Errorf("[check emission] end %v is not aligned to liquid cycles of start %v and length %v", emission.End, start, cycleLen)
+	}
+	return nil
+}
+
+// GetStart get start height, or start timestamp if use time measurement
+func (dist *DistributeConfig) GetStart() uint64 {
+	if dist.UseTimeMeasurement {
+		return dist.StartTimestamp
+	}
+	return dist.StartHeight
+}
+
+// GetByLiquidCycleLen get liquid cycle length of blocks or seconds
+func (dist *DistributeConfig) GetByLiquidCycleLen() uint64 {
+	if dist.UseTimeMeasurement {
+		return dist.ByLiquidCycleDuration
+	}
+	return dist.ByLiquidCycle
+}
+
+// GetByVolumeCycleLen get volume cycle length of blocks or seconds
+func (dist *DistributeConfig) GetByVolumeCycleLen() uint64 {
+	if dist.UseTimeMeasurement {
+		return dist.ByVolumeCycleDuration
+	}
+	return dist.ByVolumeCycle
+}
+
+// GetByLiquidRewardsAt get liquid rewards of cycle starting at start by emission schedule
+func (dist *DistributeConfig) GetByLiquidRewardsAt(start uint64) *big.Int {
+	return dist.getEmissionRewards(start, dist.ByLiquidRewards, func(step *EmissionStep) string {
+		return step.ByLiquidRewards
+	})
+}
+
+// GetByVolumeRewardsAt get volume rewards of step starting at start by emission schedule
+func (dist *DistributeConfig) GetByVolumeRewardsAt(start uint64) *big.Int {
+	return dist.getEmissionRewards(start, dist.ByVolumeRewards, func(step *EmissionStep) string {
+		return step.ByVolumeRewards
+	})
+}
+
+func (dist *DistributeConfig) getEmissionRewards(start uint64, rewardsStr string, getStepRewards func(*EmissionStep) string) *big.Int {
+	emission := dist.Emission
+	if emission != nil {
+		if emission.End != 0 && start >= emission.End {
+			return big.NewInt(0)
+		}
+		for _, step := range emission.Steps {
+			if step.From > start {
+				break
+			}
+			if stepRewards := getStepRewards(step); stepRewards != "" {
+				rewardsStr = stepRewards
+			}
+		}
+	}
+	rewards, _ := tools.GetBigIntFromString(rewardsStr)
+	if rewards == nil {
+		return big.NewInt(0)
+	}
+	distStart := dist.GetStart()
+	cycleLen := dist.GetByLiquidCycleLen()
+	if emission != nil && emission.HalvingCycles > 0 && cycleLen > 0 && start > distStart {
+		halvings := (start - distStart) / cycleLen / emission.HalvingCycles
+		if halvings >= 256 {
+			return big.NewInt(0)
+		}
+		rewards.Rsh(rewards, uint(halvings))
+	}
+	return rewards
+}
+
 // GetByVolumeCycleRewards get non nil big int from string
 func (dist *DistributeConfig) GetByVolumeCycleRewards() *big.Int {
 	byVolumeRewards, _ := tools.GetBigIntFromString(dist.ByVolumeRewards)
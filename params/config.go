@@ -36,6 +36,16 @@ type Config struct {
 	Factories  []string
 	Stake      *StakeConfig
 	Routers    []string // for exchange v2
+	Metrics    *MetricsConfig
+	Notify     *NotifyConfig
+	Limits     *SpendingLimitsConfig
+	API        *APIConfig
+	WashTrade  *WashTradeConfig
+	Contracts  *ContractAccountsConfig
+	Vesting    *VestingConfig
+	Programs   []*ProgramConfig
+	Referral   *ReferralConfig
+	Price      *PriceConfig
 }
 
 // MongoDBConfig mongodb config
@@ -52,16 +62,448 @@ type GatewayConfig struct {
 	AverageBlockTime uint64
 }
 
+// MetricsConfig metrics config
+type MetricsConfig struct {
+	Enable        bool
+	ListenAddress string
+}
+
+// NotifyConfig notify config
+type NotifyConfig struct {
+	Webhooks []*WebhookConfig
+
+	LowTokenBalance  string // reward token threshold, unit Wei
+	LowCoinBalance   string // coin threshold, unit Wei
+	SyncBehindBlocks uint64
+}
+
+// WebhookConfig webhook config
+type WebhookConfig struct {
+	URL     string
+	Format  string   // json, slack, discord
+	Events  []string // empty means subscribe all events
+	Timeout uint64   // unit of seconds
+
+	RetryInterval uint64 // unit of milliseconds, default 3000
+}
+
+// IsSubscribed is webhook subscribed event
+func (hook *WebhookConfig) IsSubscribed(event string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, item := range hook.Events {
+		if strings.EqualFold(item, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLowTokenBalance get low reward token balance threshold, nil if not configed
+func (c *NotifyConfig) GetLowTokenBalance() *big.Int {
+	threshold, _ := tools.GetBigIntFromString(c.LowTokenBalance)
+	return threshold
+}
+
+// GetLowCoinBalance get low coin balance threshold, nil if not configed
+func (c *NotifyConfig) GetLowCoinBalance() *big.Int {
+	threshold, _ := tools.GetBigIntFromString(c.LowCoinBalance)
+	return threshold
+}
+
+// APIConfig http API server config
+type APIConfig struct {
+	Enable        bool
+	ListenAddress string
+
+	// explain recomputes a whole cycle, it requires admin token if configed,
+	// and is limited to ExplainPerMinute requests (default 10).
+	AdminToken       string
+	ExplainPerMinute uint64
+}
+
+// default explain requests per minute
+const defaultExplainPerMinute = 10
+
+// GetExplainPerMinute get max explain requests per minute
+func (c *APIConfig) GetExplainPerMinute() uint64 {
+	if c.ExplainPerMinute == 0 {
+		return defaultExplainPerMinute
+	}
+	return c.ExplainPerMinute
+}
+
+// SpendingLimitsConfig hard spending limits of reward sender
+type SpendingLimitsConfig struct {
+	MaxPerCycle       string // unit Wei
+	MaxPerDay         string // unit Wei, of latest 24 hours
+	MaxPerRecipient   string // unit Wei
+	MaxRecipientShare uint64 // percentage of cycle total
+}
+
+// GetMaxPerCycle get max rewards per cycle, nil if not configed
+func (c *SpendingLimitsConfig) GetMaxPerCycle() *big.Int {
+	limit, _ := tools.GetBigIntFromString(c.MaxPerCycle)
+	return limit
+}
+
+// GetMaxPerDay get max rewards per day, nil if not configed
+func (c *SpendingLimitsConfig) GetMaxPerDay() *big.Int {
+	limit, _ := tools.GetBigIntFromString(c.MaxPerDay)
+	return limit
+}
+
+// GetMaxPerRecipient get max rewards per recipient, nil if not configed
+func (c *SpendingLimitsConfig) GetMaxPerRecipient() *big.Int {
+	limit, _ := tools.GetBigIntFromString(c.MaxPerRecipient)
+	return limit
+}
+
+// WashTradeConfig wash trade filters of volume rewards
+type WashTradeConfig struct {
+	MinSwapAmount    string // unit Wei, ignore swaps of smaller coin amount
+	MaxAccountVolume string // unit Wei, cap of counted volume per account per volume cycle
+	RoundTripWindow  uint64 // blocks or seconds, net out opposite swaps of account within window
+	ClusterMinSize   uint64 // flag accounts funded from the same source if count reaches, 0 to disable
+	ExcludeClusters  bool   // exclude volumes of flagged clusters, otherwise only record
+}
+
+// GetMinSwapAmount get min swap amount, nil if not configed
+func (c *WashTradeConfig) GetMinSwapAmount() *big.Int {
+	amount, _ := tools.GetBigIntFromString(c.MinSwapAmount)
+	return amount
+}
+
+// GetMaxAccountVolume get max account volume, nil if not configed
+func (c *WashTradeConfig) GetMaxAccountVolume() *big.Int {
+	volume, _ := tools.GetBigIntFromString(c.MaxAccountVolume)
+	return volume
+}
+
+// contract accounts policies
+const (
+	ContractPolicyExclude   = "exclude"   // exclude all contract accounts
+	ContractPolicyRedirect  = "redirect"  // redirect rewards of contract accounts to beneficiary
+	ContractPolicyAllowlist = "allowlist" // only include contract accounts in allowed list
+)
+
+// ContractAccountsConfig contract accounts detection config
+type ContractAccountsConfig struct {
+	Policy      string            // empty means no detection
+	Allowed     []string          // contracts keep their rewards in redirect and allowlist policy
+	Beneficiary string            // default beneficiary of redirected rewards
+	Redirects   map[string]string // contract to beneficiary, override default beneficiary
+}
+
+// IsAllowedContract is contract in allowed list
+func (c *ContractAccountsConfig) IsAllowedContract(contract common.Address) bool {
+	for _, allowed := range c.Allowed {
+		if common.HexToAddress(allowed) == contract {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBeneficiary get beneficiary of redirected rewards of contract
+func (c *ContractAccountsConfig) GetBeneficiary(contract common.Address) common.Address {
+	for from, to := range c.Redirects {
+		if common.HexToAddress(from) == contract {
+			return common.HexToAddress(to)
+		}
+	}
+	return common.HexToAddress(c.Beneficiary)
+}
+
+// vesting modes
+const (
+	VestingModeRelease  = "release"  // releaser job sends newly vested rewards
+	VestingModeContract = "contract" // write deposits of vesting contract
+)
+
+const defaultVestingReleaseInterval uint64 = 3600
+
+// VestingConfig reward vesting config,
+// ImmediatePercent of rewards is released immediately,
+// the rest is locked until cliff and then released linearly.
+type VestingConfig struct {
+	Enable           bool
+	Mode             string
+	ImmediatePercent uint64
+	CliffDays        uint64
+	LinearDays       uint64 // 0 means release all at cliff
+	ReleaseInterval  uint64 // unit of seconds, interval of releaser job
+	DepositsDir      string // output directory of vesting contract deposits
+
+	// sender of releaser job, signed by the signer of distribute config,
+	// or by the keystore below if signer type is 'keystore'
+	Sender       string
+	KeystoreFile string `json:"-"`
+	PasswordFile string `json:"-"`
+}
+
+// GetReleaseInterval get release interval
+func (c *VestingConfig) GetReleaseInterval() uint64 {
+	if c.ReleaseInterval == 0 {
+		return defaultVestingReleaseInterval
+	}
+	return c.ReleaseInterval
+}
+
+// IsVestingEnabled is vesting enabled
+func IsVestingEnabled() bool {
+	return config.Vesting != nil && config.Vesting.Enable
+}
+
+// unspent rewards policies
+const (
+	UnspentPolicyDrop         = "drop"         // unspent rewards are recorded and dropped
+	UnspentPolicyCarryOver    = "carryover"    // unspent rewards are added to the next cycle
+	UnspentPolicyRedistribute = "redistribute" // unspent rewards are redistributed pro rata in the same cycle
+	UnspentPolicyAccumulate   = "accumulate"   // dust rewards are accumulated until passing dust threshold
+)
+
+// IsValidUnspentPolicy is valid unspent policy, empty means drop
+func IsValidUnspentPolicy(policy string) bool {
+	switch policy {
+	case "", UnspentPolicyDrop, UnspentPolicyCarryOver, UnspentPolicyRedistribute, UnspentPolicyAccumulate:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetUnspentPolicy get unspent policy of program, empty program means the default distribution
+func GetUnspentPolicy(program string) string {
+	var policy string
+	if prog := GetProgramConfig(program); program != "" && prog != nil {
+		policy = prog.UnspentPolicy
+	}
+	if policy == "" && config.Distribute != nil {
+		policy = config.Distribute.UnspentPolicy
+	}
+	if policy == "" {
+		policy = UnspentPolicyDrop
+	}
+	return policy
+}
+
+// reward program methods
+const (
+	ProgramMethodLiquidity = "liquidity"
+	ProgramMethodVolume    = "volume"
+	ProgramMethodCustom    = "custom"
+)
+
+// ProgramConfig reward program which runs independently of the default distribution.
+// cycle lengths, start and end are block heights, or timestamps if use time measurement.
+type ProgramConfig struct {
+	ID                 string
+	Enable             bool
+	Method             string // liquidity, volume or custom
+	RewardToken        string
+	Sender             string
+	Exchanges          []string
+	Weights            []uint64
+	WeightIsPercentage bool
+	CycleLen           uint64
+	StepLen            uint64 // volume step length, 0 means the whole cycle
+	QuickSettle        bool   // settle volume rewards of every step
+	Start              uint64
+	End                uint64 // exclusive, 0 means no end
+	Rewards            string // unit Wei, rewards of every cycle
+	InputDir           string // custom method sends '<ID>-<start>-<end>.csv' of every cycle
+	UnspentPolicy      string // policy of unspent rewards, empty means the same as distribute
+
+	// custom method sends from Sender, signed by the signer of distribute config,
+	// or by the keystore below if signer type is 'keystore'
+	KeystoreFile string `json:"-"`
+	PasswordFile string `json:"-"`
+}
+
+// GetRewards get non nil big int from string
+func (c *ProgramConfig) GetRewards() *big.Int {
+	rewards, _ := tools.GetBigIntFromString(c.Rewards)
+	if rewards == nil {
+		rewards = big.NewInt(0)
+	}
+	return rewards
+}
+
+// GetStepLen get volume step length
+func (c *ProgramConfig) GetStepLen() uint64 {
+	if c.StepLen == 0 {
+		return c.CycleLen
+	}
+	return c.StepLen
+}
+
+// GetProgramConfig get program config of id
+func GetProgramConfig(id string) *ProgramConfig {
+	for _, prog := range config.Programs {
+		if strings.EqualFold(prog.ID, id) {
+			return prog
+		}
+	}
+	return nil
+}
+
+// stake boost curves
+const (
+	StakeCurveTiers   = "tiers"   // percent of the highest reached point
+	StakeCurveLinear  = "linear"  // linear to MaxPercent at FullStake
+	StakeCurveVeBoost = "veboost" // stake ratio over share ratio, capped at MaxPercent
+)
+
+// stake boost targets
+const (
+	StakeBoostLiquidity = "liquidity"
+	StakeBoostVolume    = "volume"
+)
+
+const defaultVeBoostMaxPercent uint64 = 150 // boost up to 2.5x
+
 // StakeConfig struct
 type StakeConfig struct {
-	Contract string
-	Points   []uint64 // whole unit
-	Percents []uint64
-	Stakers  []string
+	Contract   string
+	Curve      string   // tiers (default), linear or veboost
+	ApplyTo    []string // boost shares of liquidity and/or volume, default volume
+	Points     []uint64 // whole unit, of tiers curve
+	Percents   []uint64 // of tiers curve
+	FullStake  uint64   // whole unit, of linear curve
+	MaxPercent uint64   // of linear and veboost curve
+	Stakers    []string
+
+	// discover stakers from events of stake contract which have indexed account at topics[1],
+	// filtered by event topics if not empty.
+	DiscoverStakers bool
+	StakerTopics    []string
 
 	stakersMap map[common.Address]struct{}
 }
 
+// GetCurve get stake boost curve
+func (s *StakeConfig) GetCurve() string {
+	if s.Curve == "" {
+		return StakeCurveTiers
+	}
+	return s.Curve
+}
+
+// GetMaxPercent get max boost percent
+func (s *StakeConfig) GetMaxPercent() uint64 {
+	if s.MaxPercent == 0 && s.GetCurve() == StakeCurveVeBoost {
+		return defaultVeBoostMaxPercent
+	}
+	return s.MaxPercent
+}
+
+// IsStakeBoosted is shares of byWhat (liquidity or volume) boosted by stake
+func IsStakeBoosted(byWhat string) bool {
+	if config.Stake == nil {
+		return false
+	}
+	if len(config.Stake.ApplyTo) == 0 {
+		return byWhat == StakeBoostVolume
+	}
+	for _, target := range config.Stake.ApplyTo {
+		if target == byWhat {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStakerEvent is event log of stake contract used to discover stakers
+func IsStakerEvent(contract common.Address, topic0 common.Hash) bool {
+	if config.Stake == nil || !config.Stake.DiscoverStakers {
+		return false
+	}
+	if contract != common.HexToAddress(config.Stake.Contract) {
+		return false
+	}
+	if len(config.Stake.StakerTopics) == 0 {
+		return true
+	}
+	for _, topic := range config.Stake.StakerTopics {
+		if common.HexToHash(topic) == topic0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ReferralConfig referral rewards of volume rewards.
+// Percent of every referee's volume reward is granted to the referrer,
+// deducted from the referee's reward, or from a separate budget if SeparateBudget is true.
+type ReferralConfig struct {
+	Enable         bool
+	Percent        uint64 // percentage of referee's volume reward
+	SeparateBudget bool   // grant from separate budget instead of referee's reward
+	BudgetPerCycle string // unit Wei, cap of separate budget per volume cycle, empty means no cap
+
+	// registry contract event with indexed referee at topics[1] and referrer at topics[2]
+	RegistryContract string
+	RegistryTopic    string
+
+	EnableAPIRegistration bool // allow registration by http API
+}
+
+// GetBudgetPerCycle get separate budget per volume cycle, nil if not configed
+func (c *ReferralConfig) GetBudgetPerCycle() *big.Int {
+	budget, _ := tools.GetBigIntFromString(c.BudgetPerCycle)
+	return budget
+}
+
+// IsFundingSourceTracked is funding sources of accounts needed by wash trade cluster filter
+func IsFundingSourceTracked() bool {
+	return config.WashTrade != nil && config.WashTrade.ClusterMinSize > 1
+}
+
+// IsReferralEnabled is referral rewards enabled
+func IsReferralEnabled() bool {
+	return config.Referral != nil && config.Referral.Enable
+}
+
+// IsReferralRegistryEvent is event log of referral registry contract
+func IsReferralRegistryEvent(contract common.Address, topic0 common.Hash) bool {
+	refCfg := config.Referral
+	if refCfg == nil || refCfg.RegistryContract == "" {
+		return false
+	}
+	return contract == common.HexToAddress(refCfg.RegistryContract) &&
+		topic0 == common.HexToHash(refCfg.RegistryTopic)
+}
+
+const defaultPriceFeedInterval uint64 = 300
+
+// PriceConfig coin/USD price feed, sources are used in order of
+// FeedFile, FeedURL and CoinUSD, token prices are derived from exchange reserves.
+type PriceConfig struct {
+	CoinUSD      string // static coin price in USD
+	FeedFile     string // lines of '<unix timestamp> <price>', price at a time is the latest one not after it
+	FeedURL      string // returns JSON with price at FeedField, or a plain number
+	FeedField    string // default 'price'
+	FeedInterval uint64 // unit of seconds, cache duration of FeedURL price
+}
+
+// GetFeedField get JSON field of price feed
+func (c *PriceConfig) GetFeedField() string {
+	if c.FeedField == "" {
+		return "price"
+	}
+	return c.FeedField
+}
+
+// GetFeedInterval get cache duration of price feed
+func (c *PriceConfig) GetFeedInterval() uint64 {
+	if c.FeedInterval == 0 {
+		return defaultPriceFeedInterval
+	}
+	return c.FeedInterval
+}
+
 // GetAverageBlockTime average block time
 func GetAverageBlockTime() uint64 {
 	avg := config.Gateway.AverageBlockTime
@@ -80,6 +522,70 @@ type SyncConfig struct {
 	UpdateVolume       bool
 	ScanAllExchange    bool
 	RecordTokenAccount bool
+	UpdateYields       bool   // switch to update yields per day, requires UpdateLiquidity
+	YieldWindowDays    uint64 // trailing window of yields, default 7 days
+
+	// seconds between liquidity snapshots and of volume buckets, must divide a day, default a day.
+	// daily volumes are always aggregated besides the finer buckets.
+	SnapshotInterval uint64
+	// seconds added to UTC to get the day boundary, eg. 28800 for UTC+8.
+	// days of non UTC boundary are stored apart, daily volumes of UTC days are kept as before.
+	DayBoundaryOffset int64
+}
+
+const (
+	defaultYieldWindowDays uint64 = 7
+
+	// SecondsPerDay seconds per day
+	SecondsPerDay uint64 = 24 * 3600
+)
+
+// GetSnapshotInterval get seconds between liquidity snapshots and of volume buckets
+func (c *SyncConfig) GetSnapshotInterval() uint64 {
+	if c.SnapshotInterval == 0 {
+		return SecondsPerDay
+	}
+	return c.SnapshotInterval
+}
+
+// GetBucketBegin get begin of bucket of interval seconds containing timestamp,
+// buckets are aligned to day boundary of DayBoundaryOffset
+func (c *SyncConfig) GetBucketBegin(timestamp, interval uint64) uint64 {
+	return timestamp - (timestamp+interval-c.GetBucketRemainder(interval))%interval
+}
+
+// GetBucketRemainder get remainder of bucket begins of interval seconds divided by interval
+func (c *SyncConfig) GetBucketRemainder(interval uint64) uint64 {
+	offset := uint64(c.DayBoundaryOffset%int64(interval)+int64(interval)) % interval
+	return (interval - offset) % interval
+}
+
+// GetSnapshotBegin get begin of snapshot bucket containing timestamp
+func (c *SyncConfig) GetSnapshotBegin(timestamp uint64) uint64 {
+	return c.GetBucketBegin(timestamp, c.GetSnapshotInterval())
+}
+
+// GetDayBegin get begin of day containing timestamp, aligned to day boundary of DayBoundaryOffset
+func (c *SyncConfig) GetDayBegin(timestamp uint64) uint64 {
+	return c.GetBucketBegin(timestamp, SecondsPerDay)
+}
+
+// IsUTCDayBoundary is day boundary the same as UTC
+func (c *SyncConfig) IsUTCDayBoundary() bool {
+	return c.DayBoundaryOffset%int64(SecondsPerDay) == 0
+}
+
+// GetUTCDayBegin get begin of UTC day containing timestamp
+func GetUTCDayBegin(timestamp uint64) uint64 {
+	return timestamp - timestamp%SecondsPerDay
+}
+
+// GetYieldWindowDays get trailing window days of yields
+func (c *SyncConfig) GetYieldWindowDays() uint64 {
+	if c.YieldWindowDays == 0 {
+		return defaultYieldWindowDays
+	}
+	return c.YieldWindowDays
 }
 
 // ExchangeConfig exchange config
@@ -90,6 +596,53 @@ type ExchangeConfig struct {
 	CreationHeight uint64
 	LiquidWeight   uint64
 	TradeWeight    uint64
+
+	// active range, exchange takes part in cycles overlapping it, 0 means unlimited
+	StartHeight    uint64
+	EndHeight      uint64 // exclusive
+	StartTimestamp uint64 // used if use time measurement
+	EndTimestamp   uint64 // exclusive, used if use time measurement
+
+	// fixed rewards of every liquid cycle instead of sharing by weight, unit Wei
+	LiquidRewardsPerCycle string
+	TradeRewardsPerCycle  string
+}
+
+// IsActiveIn is exchange active in range [start, end)
+func (ex *ExchangeConfig) IsActiveIn(start, end uint64, useTimeMeasurement bool) bool {
+	activeStart, activeEnd := ex.StartHeight, ex.EndHeight
+	if useTimeMeasurement {
+		activeStart, activeEnd = ex.StartTimestamp, ex.EndTimestamp
+	}
+	if activeStart != 0 && activeStart >= end {
+		return false
+	}
+	if activeEnd != 0 && activeEnd <= start {
+		return false
+	}
+	return true
+}
+
+// GetLiquidRewardsPerCycle get fixed liquid rewards, nil if not configed
+func (ex *ExchangeConfig) GetLiquidRewardsPerCycle() *big.Int {
+	rewards, _ := tools.GetBigIntFromString(ex.LiquidRewardsPerCycle)
+	return rewards
+}
+
+// GetTradeRewardsPerCycle get fixed trade rewards, nil if not configed
+func (ex *ExchangeConfig) GetTradeRewardsPerCycle() *big.Int {
+	rewards, _ := tools.GetBigIntFromString(ex.TradeRewardsPerCycle)
+	return rewards
+}
+
+// GetExchangeConfig get exchange config
+func GetExchangeConfig(exchange string) *ExchangeConfig {
+	for _, ex := range config.Exchanges {
+		if strings.EqualFold(ex.Exchange, exchange) {
+			return ex
+		}
+	}
+	return nil
 }
 
 // DistributeConfig distribute config
@@ -102,6 +655,11 @@ type DistributeConfig struct {
 	StableHeight uint64
 	GasLimit     uint64
 	GasPrice     string
+	MaxInflight  uint64 // max pipelined in-flight transactions, 0 means no limit
+
+	// external signer of reward transactions, type is 'keystore' (default), 'clef' or 'http'
+	SignerType string
+	SignerURL  string
 
 	ByLiquidCycle   uint64
 	ByLiquidRewards string // unit Wei
@@ -120,6 +678,28 @@ type DistributeConfig struct {
 	ByVolumeCycleDuration uint64 // unit of seconds
 
 	TradeWeightIsPercentage bool
+
+	// policy of unspent rewards (dust, no volume steps, unallocated shares)
+	UnspentPolicy string
+
+	// emission schedule of ByLiquidRewards and ByVolumeRewards
+	Emission *EmissionConfig
+}
+
+// EmissionConfig reward emission schedule, rewards of a cycle are resolved by its start.
+// halving is applied on top of the rewards of steps.
+type EmissionConfig struct {
+	Steps         []*EmissionStep
+	HalvingCycles uint64 // halve rewards every N liquid cycles since start, 0 means no halving
+	End           uint64 // exclusive, no rewards since end, 0 means no end
+}
+
+// EmissionStep rewards of every cycle since From (height or timestamp, aligned to liquid cycles),
+// empty rewards keep the rewards of previous step.
+type EmissionStep struct {
+	From            uint64
+	ByLiquidRewards string // unit Wei
+	ByVolumeRewards string // unit Wei
 }
 
 // IsScanAllExchange is scan all exchange
@@ -326,6 +906,15 @@ func SetDustRewardThreshold(dustThreshold string) {
 	config.Distribute.DustRewardThreshold = dustThreshold
 }
 
+// SetStakePoints set stake points and percents, ignored if stake is not configed
+func SetStakePoints(points, percents []uint64) {
+	if config.Stake == nil {
+		return
+	}
+	config.Stake.Points = points
+	config.Stake.Percents = percents
+}
+
 // IsInStakerList in in staker list
 func IsInStakerList(account common.Address) bool {
 	if config.Stake == nil {
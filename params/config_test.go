@@ -0,0 +1,50 @@
+package params
+
+import "testing"
+
+const testUTCDay = 1600992000 // 2020-09-25 00:00:00 UTC
+
+func TestDayBeginWithOffset(t *testing.T) {
+	cases := []struct {
+		offset    int64
+		timestamp uint64
+		want      uint64
+	}{
+		{0, testUTCDay + 3600, testUTCDay},
+		{0, testUTCDay, testUTCDay},
+		{28800, testUTCDay + 3600, testUTCDay - 28800},                  // 09:00 UTC+8
+		{28800, testUTCDay + 57600, testUTCDay + 57600},                 // 00:00 UTC+8 of next day
+		{28800, testUTCDay + 57599, testUTCDay - 28800},                 // 23:59:59 UTC+8
+		{-18000, testUTCDay + 3600, testUTCDay - SecondsPerDay + 18000}, // 20:00 UTC-5 of last day
+		{-18000, testUTCDay + 18000, testUTCDay + 18000},                // 00:00 UTC-5
+		{86400, testUTCDay + 3600, testUTCDay},
+	}
+	for _, c := range cases {
+		cfg := &SyncConfig{DayBoundaryOffset: c.offset}
+		if got := cfg.GetDayBegin(c.timestamp); got != c.want {
+			t.Errorf("offset %v: day begin of %v is %v, want %v", c.offset, c.timestamp, got, c.want)
+		}
+		if got := cfg.GetBucketBegin(c.timestamp, SecondsPerDay); got != c.want {
+			t.Errorf("offset %v: daily bucket begin of %v is %v, want %v", c.offset, c.timestamp, got, c.want)
+		}
+		if isUTC := cfg.IsUTCDayBoundary(); isUTC != (c.want%SecondsPerDay == 0) {
+			t.Errorf("offset %v: wrong UTC day boundary %v", c.offset, isUTC)
+		}
+	}
+}
+
+func TestBucketBeginWithOffset(t *testing.T) {
+	cfg := &SyncConfig{DayBoundaryOffset: 19800} // UTC+5:30
+	if got, want := cfg.GetBucketBegin(testUTCDay+1000, 3600), uint64(testUTCDay-1800); got != want {
+		t.Errorf("hourly bucket begin is %v, want %v", got, want)
+	}
+	if got, want := cfg.GetBucketBegin(testUTCDay+1800, 3600), uint64(testUTCDay+1800); got != want {
+		t.Errorf("hourly bucket begin is %v, want %v", got, want)
+	}
+	if got, want := cfg.GetDayBegin(testUTCDay+1000), uint64(testUTCDay-19800); got != want {
+		t.Errorf("day begin is %v, want %v", got, want)
+	}
+	if got, want := GetUTCDayBegin(testUTCDay+1000), uint64(testUTCDay); got != want {
+		t.Errorf("UTC day begin is %v, want %v", got, want)
+	}
+}
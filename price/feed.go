@@ -0,0 +1,189 @@
+package price
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/params"
+)
+
+const (
+	feedTimeout      = 10 * time.Second
+	maxFeedBodyBytes = 1 << 20
+)
+
+type feedEntry struct {
+	timestamp uint64
+	price     *big.Rat
+}
+
+var (
+	feedLock sync.Mutex
+
+	fileEntries []*feedEntry
+	fileModTime time.Time
+
+	urlPrice     *big.Rat
+	urlFetchTime time.Time
+)
+
+// GetCoinUSD get coin price in USD at timestamp, nil if unknown.
+// FeedURL and CoinUSD are current prices whatever the timestamp is.
+func GetCoinUSD(timestamp uint64) *big.Rat {
+	priceCfg := params.GetConfig().Price
+	if priceCfg == nil {
+		return nil
+	}
+	var price *big.Rat
+	if priceCfg.FeedFile != "" {
+		feedLock.Lock()
+		price = getFilePrice(priceCfg.FeedFile, timestamp)
+		feedLock.Unlock()
+	}
+	if price == nil && priceCfg.FeedURL != "" {
+		price = getURLPrice(priceCfg)
+	}
+	if price == nil && priceCfg.CoinUSD != "" {
+		price, _ = new(big.Rat).SetString(priceCfg.CoinUSD)
+	}
+	if price == nil {
+		return nil
+	}
+	return new(big.Rat).Set(price)
+}
+
+// latest price not after timestamp, reload file if modified
+func getFilePrice(feedFile string, timestamp uint64) *big.Rat {
+	info, err := os.Stat(feedFile)
+	if err != nil {
+		log.Warn("[price] stat feed file failed", "file", feedFile, "err", err)
+		return nil
+	}
+	if !info.ModTime().Equal(fileModTime) {
+		entries, err := loadFeedFile(feedFile)
+		if err != nil {
+			log.Warn("[price] load feed file failed", "file", feedFile, "err", err)
+			return nil
+		}
+		fileEntries, fileModTime = entries, info.ModTime()
+		log.Info("[price] load feed file success", "file", feedFile, "entries", len(entries))
+	}
+	idx := sort.Search(len(fileEntries), func(i int) bool {
+		return fileEntries[i].timestamp > timestamp
+	})
+	if idx == 0 {
+		return nil
+	}
+	return fileEntries[idx-1].price
+}
+
+func loadFeedFile(feedFile string) ([]*feedEntry, error) {
+	file, err := os.Open(feedFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []*feedEntry
+	reader := bufio.NewReader(file)
+	for {
+		lineData, _, errf := reader.ReadLine()
+		if errf == io.EOF {
+			break
+		}
+		line := strings.TrimSpace(string(lineData))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("wrong feed line '%v'", line)
+		}
+		timestamp, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("wrong timestamp in feed line '%v'", line)
+		}
+		price, ok := new(big.Rat).SetString(parts[1])
+		if !ok || price.Sign() <= 0 {
+			return nil, fmt.Errorf("wrong price in feed line '%v'", line)
+		}
+		entries = append(entries, &feedEntry{timestamp: timestamp, price: price})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].timestamp < entries[j].timestamp
+	})
+	return entries, nil
+}
+
+// cached price of feed url, keep the stale price if fetch failed.
+// fetching is done without lock, others use the cached price meanwhile.
+func getURLPrice(priceCfg *params.PriceConfig) *big.Rat {
+	interval := time.Duration(priceCfg.GetFeedInterval()) * time.Second
+	feedLock.Lock()
+	cached := urlPrice
+	if !urlFetchTime.IsZero() && time.Since(urlFetchTime) < interval {
+		feedLock.Unlock()
+		return cached
+	}
+	urlFetchTime = time.Now()
+	feedLock.Unlock()
+
+	price, err := fetchURLPrice(priceCfg.FeedURL, priceCfg.GetFeedField())
+	if err != nil {
+		log.Warn("[price] fetch feed url failed", "url", priceCfg.FeedURL, "err", err)
+		return cached
+	}
+	log.Info("[price] fetch feed url success", "url", priceCfg.FeedURL, "price", price.FloatString(pricePrecision))
+	feedLock.Lock()
+	urlPrice = price
+	feedLock.Unlock()
+	return price
+}
+
+func fetchURLPrice(url, field string) (*big.Rat, error) {
+	client := &http.Client{Timeout: feedTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("response status %v", resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxFeedBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	body = bytes.TrimSpace(body)
+	if price, ok := new(big.Rat).SetString(string(body)); ok && price.Sign() > 0 {
+		return price, nil
+	}
+	var result map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err = decoder.Decode(&result); err != nil {
+		return nil, err
+	}
+	value, exist := result[field]
+	if !exist {
+		return nil, fmt.Errorf("no field '%v' in response", field)
+	}
+	price, ok := new(big.Rat).SetString(fmt.Sprint(value))
+	if !ok || price.Sign() <= 0 {
+		return nil, fmt.Errorf("wrong price '%v' in response", value)
+	}
+	return price, nil
+}
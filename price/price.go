@@ -0,0 +1,280 @@
+package price
+
+import (
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/callapi"
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/tools"
+	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
+)
+
+const (
+	usdPrecision   = 2
+	pricePrecision = 8
+
+	coinDecimals         = 18
+	defaultTokenDecimals = 18
+)
+
+var (
+	capi *callapi.APICaller
+
+	decimalsLock sync.Mutex
+	decimalsMap  = make(map[string]uint8)
+)
+
+// SetAPICaller set API caller used to query token decimals
+func SetAPICaller(apiCaller *callapi.APICaller) {
+	capi = apiCaller
+}
+
+// FormatUSD format USD value, empty if nil
+func FormatUSD(value *big.Rat) string {
+	if value == nil {
+		return ""
+	}
+	return value.FloatString(usdPrecision)
+}
+
+// FormatPrice format price, empty if nil
+func FormatPrice(price *big.Rat) string {
+	if price == nil {
+		return ""
+	}
+	return price.FloatString(pricePrecision)
+}
+
+func pow10(decimals uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
+
+func getTokenDecimals(token string) uint8 {
+	token = strings.ToLower(token)
+	decimalsLock.Lock()
+	defer decimalsLock.Unlock()
+	if decimals, exist := decimalsMap[token]; exist {
+		return decimals
+	}
+	if capi == nil {
+		return defaultTokenDecimals
+	}
+	decimals, err := capi.GetErc20Decimals(common.HexToAddress(token))
+	if err != nil {
+		log.Warn("[price] get token decimals failed", "token", token, "err", err)
+		return defaultTokenDecimals
+	}
+	decimalsMap[token] = decimals
+	return decimals
+}
+
+// CoinToUSD value of coin amount (unit Wei) in USD, nil if unknown
+func CoinToUSD(coinAmount *big.Int, coinUSD *big.Rat) *big.Rat {
+	if coinAmount == nil || coinUSD == nil {
+		return nil
+	}
+	value := new(big.Rat).SetFrac(coinAmount, pow10(coinDecimals))
+	return value.Mul(value, coinUSD)
+}
+
+// TokenToUSD value of token amount (unit Wei) in USD, nil if unknown
+func TokenToUSD(tokenAmount *big.Int, tokenUSD *big.Rat) *big.Rat {
+	if tokenAmount == nil || tokenUSD == nil {
+		return nil
+	}
+	value := new(big.Rat).SetInt(tokenAmount)
+	return value.Mul(value, tokenUSD)
+}
+
+// coin Wei per token Wei from reserves, nil if no reserves
+func getReserveRatio(coinReserve, tokenReserve string) *big.Rat {
+	coins, _ := tools.GetBigIntFromString(coinReserve)
+	tokens, _ := tools.GetBigIntFromString(tokenReserve)
+	if coins == nil || tokens == nil || coins.Sign() <= 0 || tokens.Sign() <= 0 {
+		return nil
+	}
+	return new(big.Rat).SetFrac(coins, tokens)
+}
+
+// GetTokenPriceInCoin coin per token in whole units from reserves, nil if no reserves
+func GetTokenPriceInCoin(token, coinReserve, tokenReserve string) *big.Rat {
+	return RatioToPrice(token, getReserveRatio(coinReserve, tokenReserve))
+}
+
+// RatioToPrice convert coin Wei per Wei of token to coin per token in whole units, nil if ratio is nil
+func RatioToPrice(token string, ratio *big.Rat) *big.Rat {
+	if ratio == nil {
+		return nil
+	}
+	price := new(big.Rat).SetFrac(pow10(getTokenDecimals(token)), pow10(coinDecimals))
+	return price.Mul(price, ratio)
+}
+
+// GetTokenCoinRatio coin Wei per Wei of token at timestamp, derived from the latest daily
+// reserves of its configed exchange not after timestamp, nil if unknown
+func GetTokenCoinRatio(token string, timestamp uint64) *big.Rat {
+	exCfg := params.GetExchangeConfig(params.GetConfigedExchange(token))
+	if exCfg == nil || !mongodb.HasSession() {
+		return nil
+	}
+	liq, err := mongodb.FindLiquidityAtOrBefore(exCfg.Exchange, timestamp)
+	if err != nil {
+		return nil
+	}
+	return getReserveRatio(liq.Coin, liq.Token)
+}
+
+// GetTokenUSD USD price of one Wei of token at timestamp, nil if unknown
+func GetTokenUSD(token string, timestamp uint64) *big.Rat {
+	ratio := GetTokenCoinRatio(token, timestamp)
+	if ratio == nil {
+		return nil
+	}
+	coinUSD := GetCoinUSD(timestamp)
+	if coinUSD == nil {
+		return nil
+	}
+	ratio.Mul(ratio, coinUSD)
+	return ratio.Quo(ratio, new(big.Rat).SetInt(pow10(coinDecimals)))
+}
+
+// AnnotateLiquidity set coin price, token price and TVL (both sides of reserves) of daily liquidity
+func AnnotateLiquidity(ml *mongodb.MgoLiquidity, token string) {
+	ml.TokenPrice = FormatPrice(GetTokenPriceInCoin(token, ml.Coin, ml.Token))
+	coinUSD := GetCoinUSD(ml.Timestamp)
+	if coinUSD == nil {
+		return
+	}
+	ml.CoinUSD = FormatPrice(coinUSD)
+	coins, _ := tools.GetBigIntFromString(ml.Coin)
+	if coins != nil {
+		ml.TVLUSD = FormatUSD(CoinToUSD(new(big.Int).Mul(coins, big.NewInt(2)), coinUSD))
+	}
+}
+
+// AnnotateVolume set coin price and USD value of daily coin volume, return false if unknown
+func AnnotateVolume(mv *mongodb.MgoVolume) bool {
+	coinUSD := GetCoinUSD(mv.Timestamp)
+	volume, _ := tools.GetBigIntFromString(mv.CoinVolume24h)
+	if coinUSD == nil || volume == nil {
+		return false
+	}
+	mv.CoinUSD = FormatPrice(coinUSD)
+	mv.VolumeUSD = FormatUSD(CoinToUSD(volume, coinUSD))
+	return true
+}
+
+// ExchangePrice latest prices and TVL of exchange
+type ExchangePrice struct {
+	Exchange     string
+	Pairs        string
+	Token        string
+	Timestamp    uint64 // of the latest daily liquidity
+	CoinReserve  string
+	TokenReserve string
+	CoinUSD      string `json:",omitempty"`
+	TokenPrice   string `json:",omitempty"` // coin per token
+	TokenUSD     string `json:",omitempty"`
+	TVLUSD       string `json:",omitempty"`
+}
+
+// GetExchangePrices get latest prices of configed exchanges
+func GetExchangePrices() []*ExchangePrice {
+	now := uint64(time.Now().Unix())
+	var prices []*ExchangePrice
+	for _, ex := range params.GetConfig().Exchanges {
+		liq, err := mongodb.FindLatestLiquidity(ex.Exchange)
+		if err != nil {
+			continue
+		}
+		AnnotateLiquidity(liq, ex.Token)
+		exPrice := &ExchangePrice{
+			Exchange:     strings.ToLower(ex.Exchange),
+			Pairs:        ex.Pairs,
+			Token:        strings.ToLower(ex.Token),
+			Timestamp:    liq.Timestamp,
+			CoinReserve:  liq.Coin,
+			TokenReserve: liq.Token,
+			CoinUSD:      FormatPrice(GetCoinUSD(now)),
+			TokenPrice:   liq.TokenPrice,
+			TVLUSD:       liq.TVLUSD,
+		}
+		if tokenUSD := GetTokenUSD(ex.Token, now); tokenUSD != nil {
+			oneToken := new(big.Rat).SetInt(pow10(getTokenDecimals(ex.Token)))
+			exPrice.TokenUSD = FormatPrice(oneToken.Mul(oneToken, tokenUSD))
+		}
+		prices = append(prices, exPrice)
+	}
+	return prices
+}
+
+// ExchangeStats TVL and volume in USD of buckets of interval seconds, and rewards in USD of cycles
+type ExchangeStats struct {
+	Exchange        string
+	Pairs           string
+	Interval        uint64
+	Liquidities     []*mongodb.MgoLiquidity
+	Volumes         []*mongodb.MgoVolume
+	Cycles          []*mongodb.MgoDistributeInfo
+	TotalRewardsUSD string `json:",omitempty"`
+}
+
+// GetExchangeStats get stats of exchange in time range [start, end) of buckets of interval seconds,
+// USD values which are not recorded are derived by current price sources.
+func GetExchangeStats(exchange string, start, end, interval uint64) (*ExchangeStats, error) {
+	exCfg := params.GetExchangeConfig(exchange)
+	if exCfg == nil {
+		exCfg = &params.ExchangeConfig{Exchange: exchange}
+	}
+	liquidities, err := mongodb.FindLiquiditiesInRange(exCfg.Exchange, start, end, interval)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := mongodb.FindVolumesInRange(exchange, start, end, interval)
+	if err != nil {
+		return nil, err
+	}
+	cycles, err := mongodb.FindDistributeInfosInRange(exchange, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, liq := range liquidities {
+		if liq.TVLUSD == "" {
+			AnnotateLiquidity(liq, exCfg.Token)
+		}
+	}
+	for _, vol := range volumes {
+		if vol.VolumeUSD == "" {
+			_ = AnnotateVolume(vol)
+		}
+	}
+	totalRewardsUSD := new(big.Rat)
+	hasRewardsUSD := false
+	for _, cycle := range cycles {
+		if cycle.RewardsUSD == "" {
+			rewards, _ := tools.GetBigIntFromString(cycle.Rewards)
+			cycle.RewardsUSD = FormatUSD(TokenToUSD(rewards, GetTokenUSD(cycle.RewardToken, cycle.Timestamp)))
+		}
+		if rewardsUSD, ok := new(big.Rat).SetString(cycle.RewardsUSD); ok {
+			totalRewardsUSD.Add(totalRewardsUSD, rewardsUSD)
+			hasRewardsUSD = true
+		}
+	}
+	stats := &ExchangeStats{
+		Exchange:    strings.ToLower(exchange),
+		Pairs:       exCfg.Pairs,
+		Interval:    interval,
+		Liquidities: liquidities,
+		Volumes:     volumes,
+		Cycles:      cycles,
+	}
+	if hasRewardsUSD {
+		stats.TotalRewardsUSD = FormatUSD(totalRewardsUSD)
+	}
+	return stats, nil
+}
@@ -0,0 +1,30 @@
+package syncer
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/metrics"
+)
+
+var (
+	syncHeightGauge    = metrics.NewGauge("distribute_sync_height", "Latest synced block height of sync worker.", "worker")
+	syncHeadGauge      = metrics.NewGauge("distribute_sync_head_height", "Latest chain head height seen by sync worker.", "worker")
+	syncBlocksCounter  = metrics.NewCounter("distribute_sync_blocks_total", "Number of synced blocks.", "worker")
+	syncReceiptCounter = metrics.NewCounter("distribute_sync_receipts_total", "Number of synced receipts.", "worker")
+	syncBlocksRate     = metrics.NewGauge("distribute_sync_blocks_per_second", "Blocks per second of the last sync range.", "worker")
+	syncReceiptsRate   = metrics.NewGauge("distribute_sync_receipts_per_second", "Receipts per second of the last sync range.", "worker")
+)
+
+func (w *worker) label() string {
+	return strconv.Itoa(w.id)
+}
+
+func (w *worker) observeSyncRate(blocks, receipts int, since time.Time) {
+	elapsed := time.Since(since).Seconds()
+	if blocks == 0 || elapsed <= 0 {
+		return
+	}
+	syncBlocksRate.Set(float64(blocks)/elapsed, w.label())
+	syncReceiptsRate.Set(float64(receipts)/elapsed, w.label())
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
 	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
 	"github.com/fsn-dev/fsn-go-sdk/efsn/core/types"
 )
@@ -124,6 +125,13 @@ func (w *worker) parseTx(i int, tx *types.Transaction, block *types.Block, recei
 		savedb = parseReceipt(mt, receipt)
 	}
 
+	// coin transfers are funding sources of accounts
+	if !savedb && params.IsFundingSourceTracked() &&
+		tx.To() != nil && txValue.Sign() > 0 &&
+		receipt != nil && receipt.Status == types.ReceiptStatusSuccessful {
+		savedb = true
+	}
+
 	if savedb {
 		_ = mongodb.TryDoTimes("AddTransaction "+mt.Key, func() error {
 			return mongodb.AddTransaction(mt, overwrite)
@@ -26,12 +26,6 @@ var (
 	topicSwap = common.HexToHash("0xd78ad95fa46c994b6551d0da85fc275fe613ce37657fb8d5e3d130840159d822")
 )
 
-const secondsPerDay = 24 * 3600
-
-func getDayBegin(timestamp uint64) uint64 {
-	return timestamp - timestamp%secondsPerDay
-}
-
 func timestampToDate(timestamp uint64) string {
 	return time.Unix(int64(timestamp), 0).Format("2006-01-02 15:04:05")
 }
@@ -50,6 +44,14 @@ func parseReceipt(mt *mongodb.MgoTransaction, receipt *types.Receipt) (savedb bo
 			continue
 		}
 
+		if params.IsStakerEvent(rlog.Address, rlog.Topics[0]) {
+			recordStaker(mt, rlog)
+		}
+
+		if params.IsReferralRegistryEvent(rlog.Address, rlog.Topics[0]) {
+			recordReferral(mt, rlog)
+		}
+
 		switch rlog.Topics[0] {
 		case topicAddLiquidity:
 			save = addExchangeReceipt(mt, rlog, idx, "AddLiquidity")
@@ -166,6 +168,51 @@ func recordAccounts(exchange, pairs, account string) {
 	})
 }
 
+func recordStaker(mt *mongodb.MgoTransaction, rlog *types.Log) {
+	if len(rlog.Topics) < 2 {
+		return
+	}
+	contract := strings.ToLower(rlog.Address.String())
+	account := strings.ToLower(common.BytesToAddress(rlog.Topics[1].Bytes()).String())
+	ms := &mongodb.MgoStaker{
+		Key:         mongodb.GetKeyOfStaker(contract, account),
+		Contract:    contract,
+		Account:     account,
+		BlockNumber: mt.BlockNumber,
+		TxHash:      mt.Hash,
+	}
+	_ = mongodb.TryDoTimes("AddStaker "+ms.Key, func() error {
+		return mongodb.AddStaker(ms)
+	})
+}
+
+// referral registry event has indexed referee at topics[1] and referrer at topics[2]
+func recordReferral(mt *mongodb.MgoTransaction, rlog *types.Log) {
+	if len(rlog.Topics) < 3 {
+		return
+	}
+	referee := common.BytesToAddress(rlog.Topics[1].Bytes())
+	referrer := common.BytesToAddress(rlog.Topics[2].Bytes())
+	if referee == referrer || referee == (common.Address{}) || referrer == (common.Address{}) {
+		log.Warn("ignore invalid referral registration", "referee", referee.String(), "referrer", referrer.String(), "txhash", mt.Hash)
+		return
+	}
+	refereeStr := strings.ToLower(referee.String())
+	mr := &mongodb.MgoReferral{
+		Key:         refereeStr,
+		Referee:     refereeStr,
+		Referrer:    strings.ToLower(referrer.String()),
+		Source:      mongodb.ReferralSourceEvent,
+		BlockNumber: mt.BlockNumber,
+		TxHash:      mt.Hash,
+		Timestamp:   mt.Timestamp,
+	}
+	_ = mongodb.TryDoTimes("AddReferral "+mr.Key, func() error {
+		_, err := mongodb.AddReferral(mr)
+		return err
+	})
+}
+
 func recordTokenAccounts(token, account string) {
 	if params.IsConfigedExchange(token) ||
 		(params.IsScanAllExchange() && params.IsInAllExchanges(common.HexToAddress(token))) {
@@ -238,7 +285,6 @@ func updateVolumes(mt *mongodb.MgoTransaction, exReceipt *mongodb.ExchangeReceip
 		return
 	}
 
-	timestamp := getDayBegin(mt.Timestamp)
 	log.Debug("[parse] update volume", "txHash", mt.Hash,
 		"logIndex", exReceipt.LogIndex, "logType", exReceipt.LogType,
 		"exchange", exReceipt.Exchange, "pairs", exReceipt.Pairs,
@@ -246,9 +292,25 @@ func updateVolumes(mt *mongodb.MgoTransaction, exReceipt *mongodb.ExchangeReceip
 		"tokenToAmount", exReceipt.TokenToAmount,
 		"timestamp", timestampToDate(mt.Timestamp))
 
-	_ = mongodb.TryDoTimes("UpdateVolume "+mt.Hash, func() error {
-		return mongodb.UpdateVolumeWithReceipt(exReceipt, mt.BlockHash, mt.BlockNumber, timestamp)
-	})
+	// daily volumes of UTC days are always aggregated,
+	// days of the configed boundary and finer buckets are aggregated apart if configed
+	syncCfg := params.GetConfig().Sync
+	timestamps := []uint64{params.GetUTCDayBegin(mt.Timestamp)}
+	intervals := []uint64{params.SecondsPerDay}
+	if !syncCfg.IsUTCDayBoundary() {
+		timestamps = append(timestamps, syncCfg.GetDayBegin(mt.Timestamp))
+		intervals = append(intervals, params.SecondsPerDay)
+	}
+	if interval := syncCfg.GetSnapshotInterval(); interval != params.SecondsPerDay {
+		timestamps = append(timestamps, syncCfg.GetBucketBegin(mt.Timestamp, interval))
+		intervals = append(intervals, interval)
+	}
+	for i, timestamp := range timestamps {
+		interval := intervals[i]
+		_ = mongodb.TryDoTimes("UpdateVolume "+mt.Hash, func() error {
+			return mongodb.UpdateVolumeWithReceipt(exReceipt, mt.BlockHash, mt.BlockNumber, timestamp, interval)
+		})
+	}
 }
 
 func addExchangeV2Receipt(mt *mongodb.MgoTransaction, rlog *types.Log, logIdx int, logType string) bool {
@@ -12,6 +12,7 @@ import (
 	"github.com/anyswap/ANYToken-distribution/cmd/utils"
 	"github.com/anyswap/ANYToken-distribution/log"
 	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/notify"
 	"github.com/anyswap/ANYToken-distribution/params"
 	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
 	"github.com/fsn-dev/fsn-go-sdk/efsn/core/types"
@@ -57,6 +58,8 @@ type worker struct {
 	start  uint64
 	end    uint64
 
+	isBehind bool // notified sync behind, reset when catch up
+
 	messageChan chan *message
 }
 
@@ -342,6 +345,8 @@ func (w *worker) doSync(wg *sync.WaitGroup) {
 				continue
 			}
 			latest = latestHeader.Number.Uint64()
+			syncHeadGauge.SetUint64(latest, w.label())
+			w.checkSyncBehind(height, latest)
 			if height+w.stable > latest {
 				time.Sleep(waitDuration)
 				continue
@@ -395,12 +400,15 @@ func (w *worker) syncRange(start, end uint64) {
 		}
 		if !overwrite && len(mblocks) == int(to-from+1) {
 			log.Info("[syncer] syncRange already synced", "id", w.id, "from", from, "to", to)
+			syncHeightGauge.SetUint64(to, w.label())
 			height = to + 1
 			continue
 		}
 		if w.end != 0 {
 			log.Info("[syncer] syncRange", "id", w.id, "from", from, "to", to, "exist", len(mblocks))
 		}
+		rangeStart := time.Now()
+		blockCount, receiptCount := 0, 0
 		for height <= to {
 			mb := getSynced(mblocks, height)
 			if overwrite || mb == nil {
@@ -413,14 +421,20 @@ func (w *worker) syncRange(start, end uint64) {
 				txs := block.Transactions()
 				receipts := getReceipts(txs)
 				w.Parse(block, receipts)
+				blockCount++
+				receiptCount += len(receipts)
+				syncBlocksCounter.Inc(w.label())
+				syncReceiptCounter.Add(float64(len(receipts)), w.label())
 				if w.end == 0 {
 					log.Info("[syncer] sync block completed", "id", w.id, "number", height)
 				} else if height%blockInterval == 0 {
 					log.Info("[syncer] syncRange in process", "id", w.id, "number", height, "percentage", w.calcSyncPercentage(height))
 				}
 			}
+			syncHeightGauge.SetUint64(height, w.label())
 			height++
 		}
+		w.observeSyncRate(blockCount, receiptCount, rangeStart)
 		if w.end != 0 {
 			log.Info("[syncer] syncRange completed", "id", w.id, "from", from, "to", to)
 		}
@@ -451,3 +465,16 @@ func getReceipts(txs []*types.Transaction) types.Receipts {
 	wg.Wait()
 	return receipts
 }
+
+func (w *worker) checkSyncBehind(height, latest uint64) {
+	notifyCfg := params.GetConfig().Notify
+	if w.end != 0 || notifyCfg == nil || notifyCfg.SyncBehindBlocks == 0 {
+		return
+	}
+	behind := height+notifyCfg.SyncBehindBlocks < latest
+	if behind && !w.isBehind {
+		notify.Notify(notify.EventSyncBehind, "syncer is behind latest block",
+			"id", w.id, "height", height, "latest", latest, "threshold", notifyCfg.SyncBehindBlocks)
+	}
+	w.isBehind = behind
+}
@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/price"
+	"github.com/anyswap/ANYToken-distribution/tools"
+)
+
+// BackfillLiquidities take liquidity snapshots of exchange at snapshot times in [start, end)
+// from historical state, which requires 'archive' node. exist snapshots are kept if not overwrite.
+func BackfillLiquidities(ex *params.ExchangeConfig, start, end uint64, overwrite bool) (added int, err error) {
+	syncCfg := params.GetConfig().Sync
+	if now := uint64(time.Now().Unix()); end > now {
+		end = now
+	}
+	interval := syncCfg.GetSnapshotInterval()
+	timestamp := syncCfg.GetSnapshotBegin(start)
+	if timestamp < start {
+		timestamp += interval
+	}
+	log.Info("[backfill] start backfill liquidities", "exchange", ex.Exchange, "start", start, "end", end, "interval", interval, "overwrite", overwrite)
+	for ; timestamp < end; timestamp += interval {
+		if !overwrite {
+			if _, errf := mongodb.FindLiquidity(mongodb.GetKeyOfExchangeAndTimestamp(ex.Exchange, timestamp)); errf == nil {
+				continue
+			}
+		}
+		err = updateSnapshotLiquidity(ex, timestamp)
+		if err != nil {
+			if isMissingTrieNode(err) {
+				log.Error("[backfill] backfill liquidities must query 'archive' node", "err", err)
+			}
+			return added, fmt.Errorf("backfill liquidity of %v at %v failed. %v", ex.Exchange, timestampToDate(timestamp), err)
+		}
+		added++
+	}
+	log.Info("[backfill] backfill liquidities success", "exchange", ex.Exchange, "added", added)
+	return added, nil
+}
+
+// BackfillVolumes rebuild volumes of snapshot interval and days of day boundary offset
+// of exchange from volume histories, [start, end) is extended to day boundaries to rebuild whole buckets.
+// daily volumes of UTC days are never rebuilt, exist buckets are kept if not overwrite,
+// and buckets without volume history are kept as they are.
+func BackfillVolumes(ex *params.ExchangeConfig, start, end uint64, overwrite bool) (rebuilt int, err error) {
+	syncCfg := params.GetConfig().Sync
+	var intervals []uint64
+	if interval := syncCfg.GetSnapshotInterval(); interval != secondsPerDay {
+		intervals = append(intervals, interval)
+	}
+	if !syncCfg.IsUTCDayBoundary() {
+		intervals = append(intervals, secondsPerDay)
+	}
+	if len(intervals) == 0 {
+		log.Info("[backfill] ignore backfill volumes as only UTC daily volumes are configed", "exchange", ex.Exchange)
+		return 0, nil
+	}
+	start = getDayBegin(start)
+	if dayBegin := getDayBegin(end); dayBegin < end {
+		end = dayBegin + secondsPerDay
+	}
+	histories, err := mongodb.FindVolumeHistories(ex.Exchange, start, end, true)
+	if err != nil {
+		return 0, err
+	}
+	log.Info("[backfill] start backfill volumes", "exchange", ex.Exchange, "start", start, "end", end, "histories", len(histories), "intervals", intervals, "overwrite", overwrite)
+	var buckets []*mongodb.MgoVolume
+	bucketsMap := make(map[string]*mongodb.MgoVolume)
+	for _, hist := range histories {
+		coins, _ := tools.GetBigIntFromString(hist.CoinAmount)
+		tokens, _ := tools.GetBigIntFromString(hist.TokenAmount)
+		if coins == nil || tokens == nil {
+			log.Warn("[backfill] ignore wrong volume history", "key", hist.Key)
+			continue
+		}
+		for _, interval := range intervals {
+			timestamp := syncCfg.GetBucketBegin(hist.Timestamp, interval)
+			key := mongodb.GetKeyOfVolume(ex.Exchange, timestamp, interval)
+			mv, exist := bucketsMap[key]
+			if !exist {
+				mv = &mongodb.MgoVolume{
+					Key:            key,
+					Exchange:       strings.ToLower(ex.Exchange),
+					Pairs:          ex.Pairs,
+					CoinVolume24h:  "0",
+					TokenVolume24h: "0",
+					Timestamp:      timestamp,
+					Interval:       interval,
+				}
+				bucketsMap[key] = mv
+				buckets = append(buckets, mv)
+			}
+			oldCoins, _ := tools.GetBigIntFromString(mv.CoinVolume24h)
+			oldTokens, _ := tools.GetBigIntFromString(mv.TokenVolume24h)
+			mv.CoinVolume24h = new(big.Int).Add(oldCoins, coins).String()
+			mv.TokenVolume24h = new(big.Int).Add(oldTokens, tokens).String()
+			if hist.BlockNumber > mv.BlockNumber {
+				mv.BlockNumber = hist.BlockNumber
+			}
+		}
+	}
+	for _, mv := range buckets {
+		if mv.Key == mongodb.GetKeyOfExchangeAndTimestamp(mv.Exchange, mv.Timestamp) {
+			continue // daily volumes of UTC days are never rebuilt
+		}
+		if !overwrite {
+			if _, errf := mongodb.FindVolume(mv.Key); errf == nil {
+				continue
+			}
+		}
+		// block hash is not recorded in volume history
+		mv.BlockHash = capi.LoopGetBlockHeader(new(big.Int).SetUint64(mv.BlockNumber)).Hash().String()
+		_ = price.AnnotateVolume(mv)
+		err = mongodb.TryDoTimes("AddVolume "+mv.Key, func() error {
+			return mongodb.AddVolume(mv, true)
+		})
+		if err != nil {
+			return rebuilt, err
+		}
+		rebuilt++
+	}
+	log.Info("[backfill] backfill volumes success", "exchange", ex.Exchange, "rebuilt", rebuilt)
+	return rebuilt, nil
+}
@@ -9,72 +9,77 @@ import (
 	"github.com/anyswap/ANYToken-distribution/log"
 	"github.com/anyswap/ANYToken-distribution/mongodb"
 	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/price"
 	"github.com/anyswap/ANYToken-distribution/syncer"
 	"github.com/fsn-dev/fsn-go-sdk/efsn/common"
 )
 
 const (
-	secondsPerDay = 24 * 3600
+	secondsPerDay = params.SecondsPerDay
 )
 
 func getDayBegin(timestamp uint64) uint64 {
-	return timestamp - timestamp%secondsPerDay
+	return params.GetConfig().Sync.GetDayBegin(timestamp)
 }
 
 func timestampToDate(timestamp uint64) string {
 	return time.Unix(int64(timestamp), 0).Format("2006-01-02 15:04:05")
 }
 
-func updateLiquidityDaily() {
+func updateLiquiditySnapshots() {
 	if !params.GetConfig().Sync.UpdateLiquidity {
 		return
 	}
 	if !syncer.IsEndlessLoop() {
 		return
 	}
-	go updateLiquidityDailyLoop()
+	go updateLiquiditySnapshotsLoop()
 }
 
-func updateLiquidityDailyLoop() {
+func updateLiquiditySnapshotsLoop() {
+	interval := params.GetConfig().Sync.GetSnapshotInterval()
 	for {
 		now := uint64(time.Now().Unix())
-		todayBegin := getDayBegin(now)
+		snapshotBegin := params.GetConfig().Sync.GetSnapshotBegin(now)
 
-		updateLiquidityDailyOnce(todayBegin)
+		updateLiquiditySnapshotsOnce(snapshotBegin)
+		updateYieldsDailyOnce(getDayBegin(now))
 
 		now = uint64(time.Now().Unix())
-		if now < todayBegin+secondsPerDay {
-			time.Sleep(time.Duration(todayBegin+secondsPerDay-now) * time.Second)
+		if now < snapshotBegin+interval {
+			time.Sleep(time.Duration(snapshotBegin+interval-now) * time.Second)
 		}
 	}
 }
 
-func updateLiquidityDailyOnce(todayBegin uint64) {
+func updateLiquiditySnapshotsOnce(snapshotBegin uint64) {
+	syncCfg := params.GetConfig().Sync
+	interval := syncCfg.GetSnapshotInterval()
 	for _, ex := range params.GetConfig().Exchanges {
 		var fromTime uint64
 		latest, _ := mongodb.FindLatestLiquidity(ex.Exchange)
 		if latest != nil {
-			lasttime := getDayBegin(latest.Timestamp)
-			fromTime = lasttime + secondsPerDay
+			lasttime := syncCfg.GetSnapshotBegin(latest.Timestamp)
+			fromTime = lasttime + interval
 		} else {
 			header := capi.LoopGetBlockHeader(new(big.Int).SetUint64(ex.CreationHeight))
-			fromTime = getDayBegin(header.Time.Uint64())
+			fromTime = syncCfg.GetSnapshotBegin(header.Time.Uint64())
 		}
-		if fromTime > todayBegin {
+		if fromTime > snapshotBegin {
 			continue
 		}
 
 		timestamp := fromTime
-		log.Info("[worker] start updateLiquidityDaily", "exchange", ex, "fromTime", fromTime)
+		log.Info("[worker] start updateLiquiditySnapshots", "exchange", ex, "fromTime", fromTime, "interval", interval)
 
-		for timestamp <= todayBegin {
-			err := updateDateLiquidity(ex, timestamp)
+		for timestamp <= snapshotBegin {
+			err := updateSnapshotLiquidity(ex, timestamp)
 			if err == nil {
-				timestamp += secondsPerDay
+				timestamp += interval
 				continue
 			}
-			if strings.HasPrefix(err.Error(), "missing trie node") {
-				log.Error("[worker] updateLiquidityDaily must query 'archive' node", "err", err)
+			if isMissingTrieNode(err) {
+				log.Error("[worker] updateLiquiditySnapshots must query 'archive' node", "err", err)
 				break
 			}
 			time.Sleep(time.Second)
@@ -82,7 +87,11 @@ func updateLiquidityDailyOnce(todayBegin uint64) {
 	}
 }
 
-func updateDateLiquidity(ex *params.ExchangeConfig, timestamp uint64) error {
+func isMissingTrieNode(err error) bool {
+	return strings.HasPrefix(err.Error(), "missing trie node")
+}
+
+func updateSnapshotLiquidity(ex *params.ExchangeConfig, timestamp uint64) error {
 	exchangeAddr := common.HexToAddress(ex.Exchange)
 	tokenAddr := common.HexToAddress(ex.Token)
 
@@ -92,19 +101,19 @@ func updateDateLiquidity(ex *params.ExchangeConfig, timestamp uint64) error {
 
 	liquidity, err := capi.GetExchangeLiquidity(exchangeAddr, blockNumber)
 	if err != nil {
-		log.Warn("[worker] updateDateLiquidity error", "err", err)
+		log.Warn("[worker] updateSnapshotLiquidity error", "err", err)
 		return err
 	}
 
 	coins, err := capi.GetCoinBalance(exchangeAddr, blockNumber)
 	if err != nil {
-		log.Warn("[worker] updateDateLiquidity error", "err", err)
+		log.Warn("[worker] updateSnapshotLiquidity error", "err", err)
 		return err
 	}
 
 	tokens, err := capi.GetExchangeTokenBalance(exchangeAddr, tokenAddr, blockNumber)
 	if err != nil {
-		log.Warn("[worker] updateDateLiquidity error", "err", err)
+		log.Warn("[worker] updateSnapshotLiquidity error", "err", err)
 		return err
 	}
 
@@ -118,16 +127,47 @@ func updateDateLiquidity(ex *params.ExchangeConfig, timestamp uint64) error {
 	mliq.BlockNumber = blockNumber.Uint64()
 	mliq.BlockHash = blockHash.String()
 	mliq.Timestamp = timestamp
+	price.AnnotateLiquidity(mliq, ex.Token)
 
 	err = mongodb.TryDoTimes("AddLiquidity "+mliq.Key, func() error {
 		return mongodb.AddLiquidity(mliq, true)
 	})
 
 	if err != nil {
-		log.Warn("[worker] updateDateLiquidity error", "err", err)
+		log.Warn("[worker] updateSnapshotLiquidity error", "err", err)
 		return err
 	}
 
-	log.Info("[worker] updateDateLiquidity success", "liquidity", mliq, "timestamp", timestampToDate(timestamp))
+	log.Info("[worker] updateSnapshotLiquidity success", "liquidity", mliq, "timestamp", timestampToDate(timestamp))
+	annotateFinishedVolumes(ex, timestamp)
 	return nil
 }
+
+// annotate USD values of volumes of the snapshot bucket and day finished at timestamp,
+// UTC day of other day boundary is annotated at the first snapshot of next UTC day.
+func annotateFinishedVolumes(ex *params.ExchangeConfig, timestamp uint64) {
+	syncCfg := params.GetConfig().Sync
+	interval := syncCfg.GetSnapshotInterval()
+	if interval != secondsPerDay {
+		annotateVolume(ex, timestamp-interval, interval)
+	}
+	if getDayBegin(timestamp) == timestamp {
+		annotateVolume(ex, timestamp-secondsPerDay, secondsPerDay)
+	}
+	if utcDayBegin := params.GetUTCDayBegin(timestamp); !syncCfg.IsUTCDayBoundary() && timestamp < utcDayBegin+interval {
+		annotateVolume(ex, utcDayBegin-secondsPerDay, secondsPerDay)
+	}
+}
+
+func annotateVolume(ex *params.ExchangeConfig, timestamp, interval uint64) {
+	mvol, err := mongodb.FindVolume(mongodb.GetKeyOfVolume(ex.Exchange, timestamp, interval))
+	if err != nil || !price.AnnotateVolume(mvol) {
+		return
+	}
+	err = mongodb.UpdateVolumeUSD(mvol.Key, mvol.CoinUSD, mvol.VolumeUSD)
+	if err != nil {
+		log.Warn("[worker] annotate volume USD error", "key", mvol.Key, "err", err)
+		return
+	}
+	log.Info("[worker] annotate volume USD success", "key", mvol.Key, "volumeUSD", mvol.VolumeUSD, "timestamp", timestampToDate(timestamp))
+}
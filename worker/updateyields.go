@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/yield"
+)
+
+// update yields of days since the latest recorded, after daily liquidity is updated
+func updateYieldsDailyOnce(todayBegin uint64) {
+	if !params.GetConfig().Sync.UpdateYields {
+		return
+	}
+	for _, ex := range params.GetConfig().Exchanges {
+		fromTime := todayBegin
+		latest, _ := mongodb.FindLatestYield(ex.Exchange)
+		if latest != nil {
+			fromTime = getDayBegin(latest.Timestamp) + secondsPerDay
+		}
+		for timestamp := fromTime; timestamp <= todayBegin; timestamp += secondsPerDay {
+			err := yield.UpdateExchangeYields(ex, timestamp)
+			if err != nil {
+				log.Warn("[worker] updateYieldsDaily error", "exchange", ex.Exchange, "timestamp", timestampToDate(timestamp), "err", err)
+				break
+			}
+		}
+	}
+}
@@ -1,16 +1,27 @@
 package worker
 
 import (
+	"github.com/anyswap/ANYToken-distribution/api"
 	"github.com/anyswap/ANYToken-distribution/callapi"
 	"github.com/anyswap/ANYToken-distribution/distributer"
+	"github.com/anyswap/ANYToken-distribution/metrics"
+	"github.com/anyswap/ANYToken-distribution/price"
 	"github.com/anyswap/ANYToken-distribution/syncer"
 )
 
 var capi *callapi.APICaller
 
+// SetAPICaller set API caller
+func SetAPICaller(apiCaller *callapi.APICaller) {
+	capi = apiCaller
+}
+
 // StartWork start all work
 func StartWork(apiCaller *callapi.APICaller, onlySyncAccount bool) {
 	capi = apiCaller
+	price.SetAPICaller(capi)
+
+	metrics.Start()
 
 	syncer.Start(capi, onlySyncAccount)
 
@@ -18,10 +29,12 @@ func StartWork(apiCaller *callapi.APICaller, onlySyncAccount bool) {
 		return
 	}
 
-	updateLiquidityDaily()
+	updateLiquiditySnapshots()
 
 	distributer.Start(capi)
 
+	api.Start()
+
 	exitCh := make(chan struct{})
 	<-exitCh
 }
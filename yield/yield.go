@@ -0,0 +1,228 @@
+package yield
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/anyswap/ANYToken-distribution/log"
+	"github.com/anyswap/ANYToken-distribution/mongodb"
+	"github.com/anyswap/ANYToken-distribution/params"
+	"github.com/anyswap/ANYToken-distribution/price"
+	"github.com/anyswap/ANYToken-distribution/tools"
+)
+
+const (
+	secondsPerDay = params.SecondsPerDay
+	daysPerYear   = 365
+)
+
+// UpdateExchangeYields calc and store yields of exchange and its liquidity providers,
+// of trailing window ended at day begin.
+func UpdateExchangeYields(ex *params.ExchangeConfig, day uint64) error {
+	windowDays := params.GetConfig().Sync.GetYieldWindowDays()
+	if day < windowDays*secondsPerDay {
+		return fmt.Errorf("day %v is earlier than window of %v days", day, windowDays)
+	}
+	rewardToken := strings.ToLower(params.GetConfig().Distribute.RewardToken)
+	ratio := price.GetTokenCoinRatio(rewardToken, day)
+	if ratio == nil {
+		log.Warn("[yield] no price of reward token, APR is not calced", "rewardToken", rewardToken, "day", day)
+	}
+
+	exYield, err := calcExchangeYield(ex, day, windowDays, rewardToken, ratio)
+	if err != nil {
+		return err
+	}
+	if exYield == nil {
+		return nil
+	}
+	accountYields, err := calcAccountYields(ex, day, windowDays, rewardToken, ratio)
+	if err != nil {
+		return err
+	}
+	for _, my := range append([]*mongodb.MgoYield{exYield}, accountYields...) {
+		err = mongodb.TryDoTimes("AddYield "+my.Key, func() error {
+			return mongodb.AddYield(my)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.Info("[yield] update exchange yields success", "exchange", ex.Exchange, "day", day,
+		"liquidAPR", exYield.LiquidAPR, "volumeYield", exYield.VolumeYield, "accounts", len(accountYields))
+	return nil
+}
+
+func newYield(ex *params.ExchangeConfig, account string, day, windowDays uint64, rewardToken string, ratio *big.Rat) *mongodb.MgoYield {
+	return &mongodb.MgoYield{
+		Key:              mongodb.GetKeyOfYield(ex.Exchange, account, day),
+		Exchange:         strings.ToLower(ex.Exchange),
+		Pairs:            ex.Pairs,
+		Account:          account,
+		Timestamp:        day,
+		WindowDays:       windowDays,
+		RewardToken:      rewardToken,
+		RewardTokenPrice: price.FormatPrice(price.RatioToPrice(rewardToken, ratio)),
+		UpdateTime:       uint64(time.Now().Unix()),
+	}
+}
+
+// calc exchange yields from daily liquidity snapshots, daily volumes and distributed rewards in window,
+// return nil if there is no liquidity in window.
+func calcExchangeYield(ex *params.ExchangeConfig, day, windowDays uint64, rewardToken string, ratio *big.Rat) (*mongodb.MgoYield, error) {
+	windowStart := day - windowDays*secondsPerDay
+	liquidities, err := mongodb.FindLiquiditiesInRange(ex.Exchange, windowStart, day+1, secondsPerDay)
+	if err != nil {
+		return nil, err
+	}
+	tvl := big.NewInt(0)
+	for _, liq := range liquidities {
+		coins, _ := tools.GetBigIntFromString(liq.Coin)
+		if coins != nil {
+			tvl.Add(tvl, coins)
+		}
+	}
+	if len(liquidities) == 0 || tvl.Sign() <= 0 {
+		log.Info("[yield] no liquidity in window", "exchange", ex.Exchange, "day", day)
+		return nil, nil
+	}
+	tvl.Mul(tvl, big.NewInt(2)) // both sides
+	tvl.Div(tvl, big.NewInt(int64(len(liquidities))))
+
+	liquidRewards, volumeRewards, err := sumDistributedRewards(ex.Exchange, windowStart, day, rewardToken)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, err := mongodb.FindVolumesInRange(ex.Exchange, windowStart, day, secondsPerDay)
+	if err != nil {
+		return nil, err
+	}
+	volume := big.NewInt(0)
+	for _, vol := range volumes {
+		coins, _ := tools.GetBigIntFromString(vol.CoinVolume24h)
+		if coins != nil {
+			volume.Add(volume, coins)
+		}
+	}
+
+	my := newYield(ex, "", day, windowDays, rewardToken, ratio)
+	my.TVL = tvl.String()
+	my.LiquidRewards = liquidRewards.String()
+	my.Volume = volume.String()
+	my.VolumeRewards = volumeRewards.String()
+	my.LiquidAPR = calcAPR(liquidRewards, ratio, tvl, windowDays)
+	my.LiquidAPY = aprToAPY(my.LiquidAPR)
+	my.VolumeYield = calcPercent(volumeRewards, ratio, volume)
+	return my, nil
+}
+
+// sum distributed rewards of the default distribution of cycles ended in [start, end),
+// the latest record is used if a cycle is distributed more than once.
+func sumDistributedRewards(exchange string, start, end uint64, rewardToken string) (liquidRewards, volumeRewards *big.Int, err error) {
+	infos, err := mongodb.FindDistributeInfosInRange(exchange, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	cycles := make(map[string]*mongodb.MgoDistributeInfo)
+	for _, info := range infos {
+		if info.Program != "" || !strings.EqualFold(info.RewardToken, rewardToken) {
+			continue
+		}
+		cycles[fmt.Sprintf("%s:%d", info.ByWhat, info.Start)] = info
+	}
+	liquidRewards, volumeRewards = big.NewInt(0), big.NewInt(0)
+	for _, info := range cycles {
+		rewards, _ := tools.GetBigIntFromString(info.Rewards)
+		if rewards == nil {
+			continue
+		}
+		switch info.ByWhat {
+		case params.ProgramMethodLiquidity:
+			liquidRewards.Add(liquidRewards, rewards)
+		case params.ProgramMethodVolume:
+			volumeRewards.Add(volumeRewards, rewards)
+		}
+	}
+	return liquidRewards, volumeRewards, nil
+}
+
+// calc realized APR of accounts from their liquid reward results of cycles ended in window,
+// position of account is the average of both sides of its recorded coin balances before stake boost.
+func calcAccountYields(ex *params.ExchangeConfig, day, windowDays uint64, rewardToken string, ratio *big.Rat) ([]*mongodb.MgoYield, error) {
+	windowStart := day - windowDays*secondsPerDay
+	results, err := mongodb.FindLiquidRewardResultsByTime(ex.Exchange, windowStart, day)
+	if err != nil {
+		return nil, err
+	}
+	type accountRewards struct {
+		rewards *big.Int
+		shares  *big.Int
+		count   int64
+	}
+	stakeBoosted := params.IsStakeBoosted(params.StakeBoostLiquidity)
+	var accounts []string
+	rewardsMap := make(map[string]*accountRewards)
+	for _, res := range results {
+		if res.Program != "" || !strings.EqualFold(res.RewardToken, rewardToken) {
+			continue
+		}
+		reward, _ := tools.GetBigIntFromString(res.Reward)
+		balance := res.Balance
+		if balance == "" && !stakeBoosted {
+			balance = res.Liquidity
+		}
+		share, _ := tools.GetBigIntFromString(balance)
+		if reward == nil || share == nil {
+			continue // results without balance before stake boost are skipped
+		}
+		item, exist := rewardsMap[res.Account]
+		if !exist {
+			item = &accountRewards{rewards: big.NewInt(0), shares: big.NewInt(0)}
+			rewardsMap[res.Account] = item
+			accounts = append(accounts, res.Account)
+		}
+		item.rewards.Add(item.rewards, reward)
+		item.shares.Add(item.shares, share)
+		item.count++
+	}
+	yields := make([]*mongodb.MgoYield, 0, len(accounts))
+	for _, account := range accounts {
+		item := rewardsMap[account]
+		position := new(big.Int).Mul(item.shares, big.NewInt(2))
+		position.Div(position, big.NewInt(item.count))
+		my := newYield(ex, account, day, windowDays, rewardToken, ratio)
+		my.TVL = position.String()
+		my.LiquidRewards = item.rewards.String()
+		my.LiquidAPR = calcAPR(item.rewards, ratio, position, windowDays)
+		my.LiquidAPY = aprToAPY(my.LiquidAPR)
+		yields = append(yields, my)
+	}
+	return yields, nil
+}
+
+// percentage of rewards value (by coin ratio) over base
+func calcPercent(rewards *big.Int, ratio *big.Rat, base *big.Int) float64 {
+	if ratio == nil || base.Sign() <= 0 || rewards.Sign() <= 0 {
+		return 0
+	}
+	percent := new(big.Rat).SetInt(rewards)
+	percent.Mul(percent, ratio)
+	percent.Quo(percent, new(big.Rat).SetInt(base))
+	percent.Mul(percent, big.NewRat(100, 1))
+	value, _ := percent.Float64()
+	return value
+}
+
+// yearly percentage of rewards in window days over value
+func calcAPR(rewards *big.Int, ratio *big.Rat, value *big.Int, windowDays uint64) float64 {
+	return calcPercent(rewards, ratio, value) * daysPerYear / float64(windowDays)
+}
+
+// compound daily
+func aprToAPY(apr float64) float64 {
+	return (math.Pow(1+apr/100/daysPerYear, daysPerYear) - 1) * 100
+}